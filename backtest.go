@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"apex-arbitrage/pkg/backtest"
+	"apex-arbitrage/pkg/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runBacktest implements the backtest command, which replays recorded order books through
+// the detector and prints a report. It returns the process exit code.
+func runBacktest(cfg *config.Config, args []string) int {
+	flags := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	dir := flags.String("dir", cfg.RecorderDir, "directory of the recorded order books")
+	from := flags.String("from", "", "start of the replay window (YYYY-MM-DD or RFC3339)")
+	to := flags.String("to", "", "end of the replay window (YYYY-MM-DD or RFC3339)")
+	exchangeList := flags.String("exchanges", "", "comma separated exchanges to replay (default: all enabled)")
+	threshold := flags.Float64("threshold", cfg.MinProfitThreshold, "minimum profit threshold as a decimal")
+	cycles := flags.Bool("cycles", cfg.CycleSearch, "enable the multi-leg cycle search")
+	jsonPath := flags.String("json", "", "also write the report as JSON to this file")
+	verbose := flags.Bool("verbose", false, "log every detected opportunity")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Detected opportunities are summarized in the report, so keep the log quiet unless asked
+	if !*verbose && log.GetLevel() > log.WarnLevel {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	fromTime, err := parseBacktestTime(*from, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -from: %v\n", err)
+		return 2
+	}
+	toTime, err := parseBacktestTime(*to, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -to: %v\n", err)
+		return 2
+	}
+
+	// Fees come from the configuration, as they would for a live run
+	fees := make(map[string]float64)
+	var names []string
+	for _, exchange := range enabledExchanges(cfg) {
+		fees[exchange.name] = exchange.cfg.TakerFee
+		names = append(names, exchange.name)
+	}
+	if *exchangeList != "" {
+		names = strings.Split(*exchangeList, ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+	}
+
+	report, err := backtest.Run(backtest.Config{
+		Dir:                *dir,
+		Exchanges:          names,
+		From:               fromTime,
+		To:                 toTime,
+		MinProfitThreshold: *threshold,
+		ExchangeFees:       fees,
+		CostModel:          loadCostModel(cfg),
+		Slippage:           loadSlippage(cfg),
+		TargetNotional:     cfg.TargetNotional,
+		TradeSizes:         tradeSizes(cfg),
+		CycleSearch:        *cycles,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backtest failed: %v\n", err)
+		return 1
+	}
+
+	report.Print(os.Stdout)
+
+	if *jsonPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*jsonPath, data, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write JSON report: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseBacktestTime parses a replay window bound given as a date or an RFC3339 time. A date used
+// as the end of the window includes the whole day.
+func parseBacktestTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC3339, got %q", value)
+	}
+	if endOfDay {
+		return day.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return day, nil
+}
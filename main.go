@@ -2,18 +2,29 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"apex-arbitrage/pkg/balances"
+	"apex-arbitrage/pkg/clock"
 	"apex-arbitrage/pkg/config"
+	"apex-arbitrage/pkg/costs"
 	"apex-arbitrage/pkg/detector"
 	"apex-arbitrage/pkg/exchanges"
+	"apex-arbitrage/pkg/execution"
 	"apex-arbitrage/pkg/models"
+	"apex-arbitrage/pkg/paper"
+	"apex-arbitrage/pkg/rebalance"
+	"apex-arbitrage/pkg/recorder"
+	"apex-arbitrage/pkg/risk"
 	"apex-arbitrage/pkg/server"
+	"apex-arbitrage/pkg/simulator"
 	"apex-arbitrage/pkg/util"
 
 	log "github.com/sirupsen/logrus"
@@ -23,21 +34,39 @@ import (
 var Version = "dev"
 
 func main() {
+	// Check the configuration without starting anything when asked to
+	if len(os.Args) > 1 && os.Args[1] == "validate-config" {
+		os.Exit(runValidateConfig(os.Args[2:]))
+	}
+
+	// Load config, refusing to start with any problem in it
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	// Initialize logger
-	util.InitLogger()
+	if err := util.InitLogger(cfg.LogLevel, cfg.LogFile, cfg.LogFormat); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
 
 	// Print version
 	log.Infof("APEX Version: %s", Version)
 
-	// Load config
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	// Replay recorded market data instead of streaming when asked to
+	if len(os.Args) > 1 && os.Args[1] == "backtest" {
+		os.Exit(runBacktest(cfg, os.Args[2:]))
 	}
 
-	// Initialize order book map to store data from exchanges
-	orderBooks := make(map[string]*models.OrderBook)
-	orderBookMutex := &sync.RWMutex{}
+	// Every component reads time from the same clock
+	clk := clock.Real()
+
+	// Initialize order book store to hold data from exchanges
+	orderBooks := models.NewOrderBookStore()
 
 	// Create context with cancellation for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -50,32 +79,58 @@ func main() {
 	// Create a waitgroup to coordinate goroutines
 	var wg sync.WaitGroup
 
-	// Initialize exchanges
+	// Initialize exchanges along with the taker fee configured for each
 	exchangeClients := []exchanges.Exchange{}
+	exchangeFees := make(map[string]float64)
+
+	// Every exchange streams all configured trading pairs
+	tradingPairs := modelPairs(cfg)
 
-	// Default trading pair if needed
-	defaultPair := "BTCUSDT"
-	if len(cfg.TradingPairs) > 0 {
-		// Use the first trading pair by default
-		defaultPair = cfg.TradingPairs[0].BaseCurrency + cfg.TradingPairs[0].QuoteCurrency
+	// In simulation mode every enabled exchange is replaced by a simulated venue of the
+	// same name, so live runs never mix synthetic and real order books
+	var market *simulator.Simulator
+	if cfg.SimulationMode {
+		scenario := simulator.DefaultScenario()
+		if cfg.SimulationScenario != "" {
+			scenario, err = simulator.LoadScenario(cfg.SimulationScenario)
+			if err != nil {
+				log.Fatalf("Failed to load simulation scenario: %v", err)
+			}
+		}
+		log.Warnf("Simulation mode enabled: order books are synthetic (scenario %s), no live exchange is connected", scenario.Name)
+		market = simulator.New(scenario, clk.Now())
 	}
 
-	// Add binance
-	if cfg.Exchanges.Binance.Enabled {
-		binance, err := exchanges.NewBinance(defaultPair)
+	// Add every enabled exchange
+	for _, exchangeCfg := range enabledExchanges(cfg) {
+		client, err := newExchangeClient(exchangeCfg.name, tradingPairs, exchangeCfg.cfg.TakerFee, market)
 		if err != nil {
-			log.Fatalf("Failed to initialize Binance: %v", err)
+			log.Fatalf("Failed to initialize %s: %v", exchangeCfg.name, err)
 		}
-		exchangeClients = append(exchangeClients, binance)
+		client.SetClock(clk)
+		client.SetTakerFee(exchangeCfg.cfg.TakerFee)
+		exchangeClients = append(exchangeClients, client)
+		exchangeFees[client.Name()] = exchangeCfg.cfg.TakerFee
 	}
 
-	// Add kraken
-	if cfg.Exchanges.Kraken.Enabled {
-		kraken, err := exchanges.NewKraken(defaultPair)
+	// Record every order book update, and optionally the raw frames, for later analysis
+	if cfg.RecorderEnabled {
+		rec, err := recorder.New(cfg.RecorderDir, cfg.RecorderFrames)
 		if err != nil {
-			log.Fatalf("Failed to initialize Kraken: %v", err)
+			log.Fatalf("Failed to initialize recorder: %v", err)
 		}
-		exchangeClients = append(exchangeClients, kraken)
+		rec.SetClock(clk)
+		orderBooks.Subscribe(rec.RecordBook)
+		if rec.RecordFrames() {
+			for _, exchange := range exchangeClients {
+				exchange.SetFrameRecorder(rec)
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec.Start(ctx)
+		}()
 	}
 
 	// Start exchange websocket connections
@@ -83,19 +138,55 @@ func main() {
 		wg.Add(1)
 		go func(e exchanges.Exchange) {
 			defer wg.Done()
-			e.Connect(ctx, orderBooks, orderBookMutex)
+			e.Connect(ctx, orderBooks)
 		}(exchange)
 	}
 
 	// Initialize apex
 	arb := detector.NewAPEX(
 		orderBooks,
-		orderBookMutex,
 		cfg.MinProfitThreshold,
-		cfg.Exchanges.Binance.TakerFee,
-		cfg.Exchanges.Kraken.TakerFee,
+		exchangeFees,
 	)
 
+	arb.SetClock(clk)
+	if cfg.OpportunityLogFile != "" {
+		if err := arb.SetOpportunityFile(cfg.OpportunityLogFile, cfg.OpportunityLogFormat); err != nil {
+			log.Fatalf("Failed to set up the opportunity log: %v", err)
+		}
+	}
+	costModel := loadCostModel(cfg)
+	arb.SetCostModel(costModel)
+	arb.SetSlippage(loadSlippage(cfg))
+	arb.SetTradeSizes(cfg.TargetNotional, tradeSizes(cfg))
+
+	// Flag opportunities found on synthetic data
+	if cfg.SimulationMode {
+		arb.EnableSimulationMode()
+	}
+
+	// Check opportunities against the real account balances, never on simulated order books
+	var balanceCache *balances.Cache
+	if cfg.BalanceTracking && cfg.SimulationMode {
+		log.Warn("Balance tracking is ignored in simulation mode")
+	} else if cfg.BalanceTracking {
+		balanceCache = balances.NewCache()
+		balanceCache.SetClock(clk)
+		for _, source := range balanceSources(cfg) {
+			wg.Add(1)
+			go func(s balances.Source) {
+				defer wg.Done()
+				s.Run(ctx, balanceCache)
+			}(source)
+		}
+		arb.SetBalances(balanceCache)
+	}
+
+	// Enable the multi-leg cycle search across exchanges and assets if configured
+	if cfg.CycleSearch {
+		arb.EnableCycleSearch()
+	}
+
 	// Start arbitrage detection loop
 	wg.Add(1)
 	go func() {
@@ -118,12 +209,129 @@ func main() {
 	log.Info("--------------------------------------")
 
 	// Initialize and start web server (on port 8080)
-	webServer := server.NewWebServer("8080", orderBooks, orderBookMutex)
+	webServer := server.NewWebServer("8080", orderBooks)
+	webServer.SetClock(clk)
+
+	// Register the opportunity handler to receive opportunity lifecycle events
+	arb.RegisterOpportunityHandler(webServer.HandleOpportunityEvent)
 
-	// Register the opportunity handler to receive detected opportunities
-	arb.RegisterOpportunityHandler(func(opp models.ArbitrageOpportunity) {
-		webServer.AddOpportunity(opp)
+	// Execution engines only receive the opportunities that pass the pre-trade risk checks
+	riskManager := risk.New(risk.Limits{
+		MaxTradeNotional:   cfg.RiskMaxTradeNotional,
+		MaxExposure:        cfg.RiskMaxExposure,
+		DailyLossLimit:     cfg.RiskDailyLossLimit,
+		MaxTradesPerMinute: cfg.RiskMaxTradesPerMinute,
 	})
+	riskManager.SetClock(clk)
+	arb.RegisterOpportunityHandler(riskManager.HandleOpportunityEvent)
+	webServer.SetRiskManager(riskManager, cfg.KillSwitchToken)
+
+	// SIGUSR1 toggles the kill switch
+	killChan := make(chan os.Signal, 1)
+	signal.Notify(killChan, syscall.SIGUSR1)
+	go func() {
+		for range killChan {
+			riskManager.ToggleKillSwitch("SIGUSR1")
+		}
+	}()
+
+	// Simulate executing every opportunity against virtual balances
+	var trader *paper.Engine
+	if cfg.PaperTrading {
+		trader = paper.New(orderBooks, exchangeFees, cfg.PaperBalances, cfg.PaperLatency)
+		trader.SetClock(clk)
+		trader.SetPnLHandler(riskManager.RecordPnL)
+		trader.SetDeclineHandler(riskManager.Decline)
+		riskManager.RegisterHandler(trader.HandleOpportunityEvent)
+		webServer.SetPaperTrader(trader)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			trader.Start(ctx)
+		}()
+		log.Infof("Paper trading enabled with %s latency", cfg.PaperLatency)
+	}
+
+	// Place real orders on the exchanges, never for opportunities on simulated order books
+	var executor *execution.Executor
+	if cfg.ExecutionEnabled && cfg.SimulationMode {
+		log.Warn("Live execution is ignored in simulation mode")
+	} else if cfg.ExecutionEnabled {
+		venues := executionVenues(cfg)
+		executor = execution.NewExecutor(venues, execution.Config{
+			LegTimeout:     cfg.ExecutionLegTimeout,
+			PollInterval:   cfg.ExecutionPollInterval,
+			MaxSlippageBps: cfg.ExecutionMaxSlippageBps,
+			Fees:           exchangeFees,
+		})
+		executor.SetClock(clk)
+		executor.SetPnLHandler(riskManager.RecordPnL)
+		executor.SetFailureHandler(func(exec execution.Execution) {
+			// A position may be open: stop trading until someone looks at it
+			riskManager.SetKillSwitch(true, fmt.Sprintf("execution %d failed with open position %s", exec.ID, formatPosition(exec)))
+		})
+		executor.SetDeclineHandler(riskManager.Decline)
+		riskManager.RegisterHandler(executor.HandleOpportunityEvent)
+		webServer.SetExecutor(executor)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			executor.Start(ctx)
+		}()
+		log.Warnf("Live execution enabled on %d exchanges: real orders will be placed", len(venues))
+	}
+
+	// Recommend transfers that restore the target allocations, from the real balances when tracked
+	var balanceSource rebalance.BalanceSource
+	sourceName := ""
+	if balanceCache != nil {
+		balanceSource, sourceName = balanceCache.Balances, "accounts"
+	} else if trader != nil {
+		balanceSource, sourceName = func() map[string]map[string]float64 { return trader.Ledger().Balances }, "paper"
+	}
+	if balanceSource != nil {
+		planner := rebalance.New(balanceSource, sourceName, orderBooks, costModel, rebalance.Config{
+			Targets:       cfg.RebalanceTargets,
+			Threshold:     cfg.RebalanceThreshold,
+			MaxCost:       cfg.RebalanceMaxCost,
+			QuoteCurrency: cfg.TradingPairs[0].QuoteCurrency,
+		})
+		planner.SetClock(clk)
+		webServer.SetRebalancer(planner)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			planner.Start(ctx, cfg.RebalanceInterval)
+		}()
+	}
+
+	// Apply changes to the threshold, fees and trading pairs without a restart, on SIGHUP and
+	// whenever the configuration or .env file changes
+	configReloader := &reloader{
+		cfg:      cfg,
+		clients:  exchangeClients,
+		arb:      arb,
+		trader:   trader,
+		executor: executor,
+		web:      webServer,
+		clock:    clk,
+	}
+	configReloader.publish([]config.Change{}, []string{})
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			configReloader.reload("SIGHUP")
+		}
+	}()
+	if cfg.ConfigWatch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			configReloader.watch(ctx, cfg.ConfigWatchInterval)
+		}()
+		log.Infof("Watching %s and .env for configuration changes", cfg.ConfigFile)
+	}
 
 	// Start web server in a goroutine
 	wg.Add(1)
@@ -157,3 +365,138 @@ func main() {
 		log.Warn("Shutdown timed out, forcing exit")
 	}
 }
+
+// loadCostModel returns the transfer cost model from the configured file, or the built-in one
+func loadCostModel(cfg *config.Config) *costs.Model {
+	if cfg.CostModelFile == "" {
+		return costs.DefaultModel()
+	}
+	model, err := costs.Load(cfg.CostModelFile)
+	if err != nil {
+		log.Fatalf("Failed to load cost model: %v", err)
+	}
+	return model
+}
+
+// loadSlippage returns the configured slippage estimator, or nil to evaluate at the top of book
+func loadSlippage(cfg *config.Config) detector.SlippageEstimator {
+	estimator, err := detector.NewSlippageEstimator(cfg.SlippageModel, cfg.SlippageBps, cfg.SlippageImpactBps)
+	if err != nil {
+		log.Fatalf("Invalid slippage configuration: %v", err)
+	}
+	return estimator
+}
+
+// tradeSizes converts the configured per-pair trade sizes to the detector's form
+func tradeSizes(cfg *config.Config) map[models.TradingPair]models.TradeSize {
+	sizes := make(map[models.TradingPair]models.TradeSize, len(cfg.TradeSizes))
+	for pair, size := range cfg.TradeSizes {
+		base, quote, _ := strings.Cut(pair, "/")
+		sizes[models.TradingPair{BaseCurrency: base, QuoteCurrency: quote}] = models.TradeSize{
+			Amount:   size.Amount,
+			Currency: size.Currency,
+		}
+	}
+	return sizes
+}
+
+// executionVenues creates an order placement venue for every enabled exchange with API credentials
+func executionVenues(cfg *config.Config) []execution.Venue {
+	venues := []execution.Venue{}
+	for _, exchange := range enabledExchanges(cfg) {
+		if exchange.cfg.APIKey == "" || exchange.cfg.APISecret == "" {
+			log.Warnf("No API credentials for %s, its opportunities will not be executed", exchange.name)
+			continue
+		}
+		switch exchange.name {
+		case "Binance":
+			venues = append(venues, execution.NewBinance(exchange.cfg.RESTURL, exchange.cfg.APIKey, exchange.cfg.APISecret))
+		case "Kraken":
+			venues = append(venues, execution.NewKraken(exchange.cfg.RESTURL, exchange.cfg.APIKey, exchange.cfg.APISecret))
+		case "Coinbase":
+			venues = append(venues, execution.NewCoinbase(exchange.cfg.RESTURL, exchange.cfg.APIKey, exchange.cfg.APISecret, exchange.cfg.Passphrase))
+		}
+	}
+	return venues
+}
+
+// balanceSources creates an account balance source for every enabled exchange with API credentials
+func balanceSources(cfg *config.Config) []balances.Source {
+	sources := []balances.Source{}
+	for _, exchange := range enabledExchanges(cfg) {
+		if exchange.cfg.APIKey == "" || exchange.cfg.APISecret == "" {
+			log.Warnf("No API credentials for %s, its balances will not be tracked", exchange.name)
+			continue
+		}
+		switch exchange.name {
+		case "Binance":
+			client := execution.NewBinance(exchange.cfg.RESTURL, exchange.cfg.APIKey, exchange.cfg.APISecret)
+			sources = append(sources, balances.NewBinanceStream(client, exchange.cfg.AccountStreamURL))
+		case "Kraken":
+			client := execution.NewKraken(exchange.cfg.RESTURL, exchange.cfg.APIKey, exchange.cfg.APISecret)
+			sources = append(sources, balances.NewKrakenStream(client, exchange.cfg.AccountStreamURL))
+		case "Coinbase":
+			client := execution.NewCoinbase(exchange.cfg.RESTURL, exchange.cfg.APIKey, exchange.cfg.APISecret, exchange.cfg.Passphrase)
+			sources = append(sources, balances.NewCoinbasePoller(client, cfg.BalancePollInterval))
+		}
+	}
+	return sources
+}
+
+// exchangeSettings pairs an exchange name with its configuration
+type exchangeSettings struct {
+	name string
+	cfg  config.ExchangeConfig
+}
+
+// enabledExchanges lists the exchanges enabled in the configuration
+func enabledExchanges(cfg *config.Config) []exchangeSettings {
+	all := []exchangeSettings{
+		{"Binance", cfg.Exchanges.Binance},
+		{"Kraken", cfg.Exchanges.Kraken},
+		{"Coinbase", cfg.Exchanges.Coinbase},
+	}
+	enabled := make([]exchangeSettings, 0, len(all))
+	for _, exchange := range all {
+		if exchange.cfg.Enabled {
+			enabled = append(enabled, exchange)
+		}
+	}
+	return enabled
+}
+
+// newExchangeClient creates the live client for an exchange, or a simulated venue with the
+// same name when a market simulator is given
+func newExchangeClient(name string, pairs []models.TradingPair, takerFee float64, market *simulator.Simulator) (exchanges.Exchange, error) {
+	if market != nil {
+		return exchanges.NewSimulated(name, market, pairs, takerFee)
+	}
+
+	switch name {
+	case "Binance":
+		return exchanges.NewBinance(pairs)
+	case "Kraken":
+		return exchanges.NewKraken(pairs)
+	case "Coinbase":
+		return exchanges.NewCoinbase(pairs)
+	default:
+		return nil, fmt.Errorf("unknown exchange %q", name)
+	}
+}
+
+// formatPosition describes the open position of a failed execution, e.g. "Kraken -0.6 BTC"
+func formatPosition(exec execution.Execution) string {
+	exchanges := make([]string, 0, len(exec.OpenPosition))
+	for exchange := range exec.OpenPosition {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+	parts := make([]string, 0, len(exchanges))
+	for _, exchange := range exchanges {
+		parts = append(parts, fmt.Sprintf("%s %+.8f %s", exchange, exec.OpenPosition[exchange], exec.BaseCurrency))
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,290 @@
+package backtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-arbitrage/pkg/clock"
+	"apex-arbitrage/pkg/costs"
+	"apex-arbitrage/pkg/detector"
+	"apex-arbitrage/pkg/models"
+	"apex-arbitrage/pkg/recorder"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxIdleGap is the longest stretch without recorded data that is stepped through pass by pass;
+// longer gaps (e.g. the recorder was not running) are skipped since every book is stale by then
+const maxIdleGap = time.Minute
+
+// Config describes a backtest run
+// @author VrushankPatel
+// @description Selects the recorded data to replay and the detector settings to evaluate
+type Config struct {
+	// Root directory of the recorded order books
+	Dir string
+	// Exchanges to replay, or nil for all recorded exchanges
+	Exchanges []string
+	// Replay window; zero values leave that side open
+	From time.Time
+	To   time.Time
+	// Detector settings under test
+	MinProfitThreshold float64
+	ExchangeFees       map[string]float64
+	CostModel          *costs.Model
+	Slippage           detector.SlippageEstimator
+	TargetNotional     float64
+	TradeSizes         map[models.TradingPair]models.TradeSize
+	CycleSearch        bool
+	// Virtual time between full fallback passes (detector.FallbackInterval when zero)
+	Interval time.Duration
+}
+
+// Report summarizes the opportunities a backtest found
+// @author VrushankPatel
+// @description Totals, estimated PnL per quote currency and breakdowns per pair and per route.
+// An episode is one opportunity from the detector opening it to closing it. Each episode
+// contributes its peak profit once to the estimated PnL, so long-lived opportunities are not
+// counted on every detection.
+type Report struct {
+	Start        time.Time          `json:"start"`
+	End          time.Time          `json:"end"`
+	Duration     string             `json:"duration"`
+	Records      int                `json:"records"`
+	Passes       int                `json:"passes"`
+	Detections   int                `json:"detections"`
+	Episodes     int                `json:"episodes"`
+	EstimatedPnL map[string]float64 `json:"estimated_pnl"`
+	Pairs        []Breakdown        `json:"pairs"`
+	Routes       []Breakdown        `json:"routes"`
+}
+
+// Breakdown summarizes the opportunities of one pair or route
+// @author VrushankPatel
+// @description One row of the per-pair or per-route tables of a Report
+type Breakdown struct {
+	Name          string  `json:"name"`
+	Currency      string  `json:"currency"`
+	Detections    int     `json:"detections"`
+	Episodes      int     `json:"episodes"`
+	AvgProfitPct  float64 `json:"avg_profit_pct"`
+	BestProfitPct float64 `json:"best_profit_pct"`
+	EstimatedPnL  float64 `json:"estimated_pnl"`
+	// How long the episodes stayed open
+	AvgLifetimeSeconds float64 `json:"avg_lifetime_seconds"`
+	MaxLifetimeSeconds float64 `json:"max_lifetime_seconds"`
+}
+
+// episode tracks the peak profit of one open opportunity
+type episode struct {
+	peakProfit float64
+}
+
+// tally accumulates a Breakdown while the backtest runs
+type tally struct {
+	Breakdown
+	totalPct      float64
+	closed        int
+	totalLifetime float64
+}
+
+// run holds the state of one backtest
+type run struct {
+	pass     int
+	episodes map[string]*episode
+	pairs    map[string]*tally
+	routes   map[string]*tally
+	report   Report
+}
+
+// Run replays recorded order books through the detector on a virtual clock
+// @author VrushankPatel
+// @description Feeds every recorded book into a fresh OrderBookStore in receive time order, re-evaluating
+// the opportunities each update affects as it arrives and running a full fallback pass at every
+// interval of recorded time, exactly as the live loop would have
+// @param cfg The backtest configuration
+// @return The report, or an error if the recorded data cannot be read
+func Run(cfg Config) (*Report, error) {
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = detector.FallbackInterval
+	}
+
+	store := models.NewOrderBookStore()
+	arb := detector.NewAPEX(store, cfg.MinProfitThreshold, cfg.ExchangeFees)
+	arb.DisableOpportunityFile()
+	arb.SetCostModel(cfg.CostModel)
+	arb.SetSlippage(cfg.Slippage)
+	arb.SetTradeSizes(cfg.TargetNotional, cfg.TradeSizes)
+	if cfg.CycleSearch {
+		arb.EnableCycleSearch()
+	}
+
+	// The clock is moved to the first record, since that is where recorded time starts
+	replayClock := clock.NewFake(cfg.From)
+	arb.SetClock(replayClock)
+	started := false
+
+	r := &run{
+		episodes: make(map[string]*episode),
+		pairs:    make(map[string]*tally),
+		routes:   make(map[string]*tally),
+	}
+	arb.RegisterOpportunityHandler(r.record)
+
+	var nextPass time.Time
+	records, err := recorder.Replay(cfg.Dir, cfg.Exchanges, cfg.From, cfg.To, func(rec recorder.BookRecord) error {
+		if !started {
+			started = true
+			replayClock.Set(rec.Received)
+			r.report.Start = rec.Received
+			nextPass = rec.Received
+		}
+		if rec.Received.Sub(nextPass) > maxIdleGap {
+			log.Debugf("[Backtest] No data from %s to %s, skipping ahead", nextPass.Format(time.RFC3339), rec.Received.Format(time.RFC3339))
+			nextPass = rec.Received
+		}
+
+		// Run every fallback pass that was due before this update arrived
+		for !rec.Received.Before(nextPass) {
+			replayClock.Set(nextPass)
+			r.pass++
+			arb.DetectOnce()
+			nextPass = nextPass.Add(interval)
+		}
+
+		replayClock.Set(rec.Received)
+		store.Update(rec.Book)
+		arb.DetectUpdate(rec.Book)
+		r.report.End = rec.Received
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// One last pass over the final state of the books, then close what is still open so
+	// every episode has a lifetime. Without any records there is nothing to evaluate.
+	if started {
+		replayClock.Set(r.report.End)
+		r.pass++
+		arb.DetectOnce()
+		arb.CloseOpenOpportunities()
+	}
+
+	r.report.Records = records
+	r.report.Passes = r.pass
+	r.report.Duration = r.report.End.Sub(r.report.Start).String()
+	r.report.EstimatedPnL = make(map[string]float64)
+	for _, t := range r.routes {
+		r.report.EstimatedPnL[t.Currency] += t.EstimatedPnL
+	}
+	r.report.Pairs = finish(r.pairs)
+	r.report.Routes = finish(r.routes)
+	return &r.report, nil
+}
+
+// record is the opportunity handler that accumulates the report
+func (r *run) record(event models.OpportunityEvent) {
+	opp := event.Opportunity
+	route := routeName(opp)
+	pair := models.TradingPair{BaseCurrency: opp.BaseCurrency, QuoteCurrency: opp.QuoteCurrency}.String()
+	tallies := []*tally{tallyFor(r.pairs, pair, opp.QuoteCurrency), tallyFor(r.routes, route, opp.QuoteCurrency)}
+
+	if event.Type == models.OpportunityEventClosed {
+		delete(r.episodes, opp.ID)
+		for _, t := range tallies {
+			t.closed++
+			t.totalLifetime += opp.LifetimeSeconds
+			if opp.LifetimeSeconds > t.MaxLifetimeSeconds {
+				t.MaxLifetimeSeconds = opp.LifetimeSeconds
+			}
+		}
+		return
+	}
+
+	// Profit of acting on this detection at the configured trade size (per unit of the
+	// starting currency for multi-leg opportunities)
+	profit := opp.NetProfit
+
+	r.report.Detections++
+	newEpisode := event.Type == models.OpportunityEventOpened
+	ep, open := r.episodes[opp.ID]
+	if newEpisode || !open {
+		ep = &episode{}
+		r.episodes[opp.ID] = ep
+		r.report.Episodes++
+		newEpisode = true
+	}
+
+	// Only the amount by which this detection raises the episode's peak adds to the PnL
+	gain := 0.0
+	if newEpisode || profit > ep.peakProfit {
+		gain = profit - ep.peakProfit
+		ep.peakProfit = profit
+	}
+
+	for _, t := range tallies {
+		t.Detections++
+		if newEpisode {
+			t.Episodes++
+		}
+		t.totalPct += opp.ProfitPercentage
+		if t.Detections == 1 || opp.ProfitPercentage > t.BestProfitPct {
+			t.BestProfitPct = opp.ProfitPercentage
+		}
+		t.EstimatedPnL += gain
+	}
+}
+
+// tallyFor returns the tally of a pair or route, creating it on first use
+func tallyFor(tallies map[string]*tally, name, currency string) *tally {
+	t, exists := tallies[name]
+	if !exists {
+		t = &tally{Breakdown: Breakdown{Name: name, Currency: currency}}
+		tallies[name] = t
+	}
+	return t
+}
+
+// finish turns tallies into breakdown rows sorted by estimated PnL, best first
+func finish(tallies map[string]*tally) []Breakdown {
+	rows := make([]Breakdown, 0, len(tallies))
+	for _, t := range tallies {
+		row := t.Breakdown
+		if row.Detections > 0 {
+			row.AvgProfitPct = t.totalPct / float64(row.Detections)
+		}
+		if t.closed > 0 {
+			row.AvgLifetimeSeconds = t.totalLifetime / float64(t.closed)
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].EstimatedPnL != rows[j].EstimatedPnL {
+			return rows[i].EstimatedPnL > rows[j].EstimatedPnL
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
+
+// routeName identifies the route of an opportunity, e.g. "Binance→Kraken BTC/USDT" or
+// "triangular Binance USDT→BTC→ETH→USDT"
+func routeName(opp models.ArbitrageOpportunity) string {
+	if len(opp.Legs) == 0 {
+		return fmt.Sprintf("%s→%s %s/%s", opp.BuyExchange, opp.SellExchange, opp.BaseCurrency, opp.QuoteCurrency)
+	}
+
+	steps := []string{opp.Legs[0].From}
+	exchanges := []string{}
+	for _, leg := range opp.Legs {
+		steps = append(steps, leg.To)
+		if len(exchanges) == 0 || exchanges[len(exchanges)-1] != leg.Exchange {
+			exchanges = append(exchanges, leg.Exchange)
+		}
+	}
+	return fmt.Sprintf("%s %s %s", opp.Type, strings.Join(exchanges, "/"), strings.Join(steps, "→"))
+}
@@ -0,0 +1,60 @@
+package backtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Print writes the report as plain text tables
+// @author VrushankPatel
+// @description Human readable summary for the backtest command
+// @param w The writer to print to
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintln(w, "APEX BACKTEST REPORT")
+	fmt.Fprintln(w, "--------------------")
+	fmt.Fprintf(w, "Period:      %s to %s (%s)\n", r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339), r.Duration)
+	fmt.Fprintf(w, "Records:     %d order book updates, %d fallback passes\n", r.Records, r.Passes)
+	fmt.Fprintf(w, "Detections:  %d in %d episodes\n", r.Detections, r.Episodes)
+
+	currencies := make([]string, 0, len(r.EstimatedPnL))
+	for currency := range r.EstimatedPnL {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		fmt.Fprintf(w, "Est. PnL:    %.4f %s\n", r.EstimatedPnL[currency], currency)
+	}
+	if len(currencies) == 0 {
+		fmt.Fprintln(w, "Est. PnL:    none, no opportunities found")
+	}
+
+	printBreakdown(w, "BY PAIR", "PAIR", r.Pairs)
+	printBreakdown(w, "BY ROUTE", "ROUTE", r.Routes)
+}
+
+// printBreakdown writes one breakdown table
+func printBreakdown(w io.Writer, title, column string, rows []Breakdown) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tDETECTIONS\tEPISODES\tAVG PROFIT\tBEST PROFIT\tAVG LIFETIME\tMAX LIFETIME\tEST. PNL\n", column)
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.4f%%\t%.4f%%\t%.3fs\t%.3fs\t%.4f %s\n",
+			row.Name,
+			row.Detections,
+			row.Episodes,
+			row.AvgProfitPct,
+			row.BestProfitPct,
+			row.AvgLifetimeSeconds,
+			row.MaxLifetimeSeconds,
+			row.EstimatedPnL,
+			row.Currency,
+		)
+	}
+	tw.Flush()
+}
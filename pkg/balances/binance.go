@@ -0,0 +1,134 @@
+package balances
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-arbitrage/pkg/execution"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BinanceStreamURL is the production Binance user data stream endpoint
+const BinanceStreamURL = "wss://stream.binance.com:9443/ws"
+
+// binanceKeepAlive is how often the listen key is extended; Binance expires it after 60 minutes
+const binanceKeepAlive = 30 * time.Minute
+
+// BinanceStream keeps the Binance balances current from the user data stream
+// @author VrushankPatel
+// @description Loads an account snapshot over REST once the stream is connected, then applies
+// every outboundAccountPosition event newer than the snapshot, extending the listen key every
+// 30 minutes
+type BinanceStream struct {
+	client *execution.Binance
+	wsURL  string
+}
+
+// binanceAccountEvent is a user data stream event; only outboundAccountPosition carries balances
+type binanceAccountEvent struct {
+	EventType  string `json:"e"`
+	UpdateTime int64  `json:"u"` // Time of the account update, in milliseconds
+	Balances   []struct {
+		Asset string `json:"a"`
+		Free  string `json:"f"`
+	} `json:"B"`
+}
+
+// NewBinanceStream creates a Binance account source
+// @author VrushankPatel
+// @description Uses the REST client for the listen key and snapshots
+// @param client The authenticated Binance REST client
+// @param wsURL The user data stream base URL, BinanceStreamURL when empty
+// @return A pointer to the newly created BinanceStream
+func NewBinanceStream(client *execution.Binance, wsURL string) *BinanceStream {
+	if wsURL == "" {
+		wsURL = BinanceStreamURL
+	}
+	return &BinanceStream{client: client, wsURL: strings.TrimSuffix(wsURL, "/")}
+}
+
+// Name returns the exchange name
+func (s *BinanceStream) Name() string {
+	return "Binance"
+}
+
+// Run streams the balances until the context is cancelled
+func (s *BinanceStream) Run(ctx context.Context, cache *Cache) {
+	runWithReconnect(ctx, cache, s.Name(), func(ctx context.Context) error {
+		return s.stream(ctx, cache)
+	})
+}
+
+// stream connects to the user data stream and applies its events until it fails
+func (s *BinanceStream) stream(ctx context.Context, cache *Cache) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	listenKey, err := s.client.ListenKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create listen key: %v", err)
+	}
+	conn, err := dial(ctx, s.wsURL+"/"+listenKey)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// Events arriving while the snapshot loads wait in the connection. Those the snapshot
+	// already reflects are skipped below, so they cannot roll it back.
+	snapshot, snapshotTime, err := s.client.Balances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load balances: %v", err)
+	}
+	cache.Replace(s.Name(), snapshot)
+	log.Infof("[Balances] Loaded %d Binance balances, streaming updates", len(snapshot))
+
+	go func() {
+		ticker := time.NewTicker(binanceKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.client.KeepAliveListenKey(ctx, listenKey); err != nil {
+					log.Warnf("[Balances] Failed to extend Binance listen key: %v", err)
+				}
+			}
+		}
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var event binanceAccountEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			log.Debugf("[Balances] Unparseable Binance event: %s", string(message))
+			continue
+		}
+		switch event.EventType {
+		case "outboundAccountPosition":
+			if !time.UnixMilli(event.UpdateTime).After(snapshotTime) {
+				log.Debugf("[Balances] Skipping Binance account update older than the snapshot")
+				continue
+			}
+			for _, balance := range event.Balances {
+				free, err := strconv.ParseFloat(balance.Free, 64)
+				if err != nil {
+					continue
+				}
+				cache.Set(s.Name(), balance.Asset, free)
+			}
+		case "listenKeyExpired":
+			return fmt.Errorf("listen key expired")
+		}
+	}
+}
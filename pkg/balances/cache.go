@@ -0,0 +1,140 @@
+package balances
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"apex-arbitrage/pkg/clock"
+)
+
+// Account is a snapshot of the free balances on one exchange
+// @author VrushankPatel
+// @description Balances are keyed by asset, e.g. "BTC"
+type Account struct {
+	Exchange  string             `json:"exchange"`
+	Balances  map[string]float64 `json:"balances"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// Cache holds the latest free balance of every asset per exchange
+// @author VrushankPatel
+// @description Fed by the account sources and read by the detector. An exchange's balances are
+// known from its first snapshot until its source loses the connection, so opportunities are never
+// checked against balances that may be stale.
+type Cache struct {
+	mu       sync.RWMutex
+	accounts map[string]*Account
+	clock    clock.Clock
+}
+
+// NewCache creates an empty balance cache
+// @author VrushankPatel
+// @description No exchange's balances are known until its source loads them
+// @return A pointer to the newly created Cache
+func NewCache() *Cache {
+	return &Cache{accounts: make(map[string]*Account), clock: clock.Real()}
+}
+
+// SetClock replaces the wall clock used for the update times of accounts
+// @author VrushankPatel
+// @description Lets tests and replays control time; call before starting the sources
+// @param clk The clock to use
+func (c *Cache) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// Replace sets every balance of an exchange from a snapshot
+// @author VrushankPatel
+// @description Assets missing from the snapshot have a zero balance
+// @param exchange The exchange name
+// @param balances The free balance per asset
+func (c *Cache) Replace(exchange string, balances map[string]float64) {
+	account := &Account{Exchange: exchange, Balances: make(map[string]float64, len(balances)), UpdatedAt: c.clock.Now()}
+	for asset, free := range balances {
+		account.Balances[asset] = free
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accounts[exchange] = account
+}
+
+// Set updates the balance of one asset from a stream event
+// @author VrushankPatel
+// @description Ignored until the exchange has a snapshot, since other assets would look empty
+// @param exchange The exchange name
+// @param asset The asset, e.g. "BTC"
+// @param free The new free balance
+func (c *Cache) Set(exchange, asset string, free float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	account, exists := c.accounts[exchange]
+	if !exists {
+		return
+	}
+	account.Balances[asset] = free
+	account.UpdatedAt = c.clock.Now()
+}
+
+// Invalidate forgets the balances of an exchange
+// @author VrushankPatel
+// @description Called when a source loses its connection and can no longer keep them current
+// @param exchange The exchange name
+func (c *Cache) Invalidate(exchange string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.accounts, exchange)
+}
+
+// Balance returns the free balance of an asset on an exchange
+// @author VrushankPatel
+// @description Implements detector.BalanceProvider
+// @param exchange The exchange name
+// @param asset The asset, e.g. "BTC"
+// @return The free balance, and false when the exchange's balances are not known
+func (c *Cache) Balance(exchange, asset string) (float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	account, exists := c.accounts[exchange]
+	if !exists {
+		return 0, false
+	}
+	return account.Balances[asset], true
+}
+
+// Balances returns a copy of the free balances per exchange and asset
+// @author VrushankPatel
+// @description Feeds the rebalancing planner
+// @return The balances of every known exchange
+func (c *Cache) Balances() map[string]map[string]float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	balances := make(map[string]map[string]float64, len(c.accounts))
+	for exchange, account := range c.accounts {
+		balances[exchange] = make(map[string]float64, len(account.Balances))
+		for asset, free := range account.Balances {
+			balances[exchange][asset] = free
+		}
+	}
+	return balances
+}
+
+// Accounts returns a copy of every known account, sorted by exchange
+// @author VrushankPatel
+// @description Safe to call at any time
+// @return The accounts
+func (c *Cache) Accounts() []Account {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	accounts := make([]Account, 0, len(c.accounts))
+	for _, account := range c.accounts {
+		copied := Account{Exchange: account.Exchange, Balances: make(map[string]float64, len(account.Balances)), UpdatedAt: account.UpdatedAt}
+		for asset, free := range account.Balances {
+			copied.Balances[asset] = free
+		}
+		accounts = append(accounts, copied)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Exchange < accounts[j].Exchange })
+	return accounts
+}
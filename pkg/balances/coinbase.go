@@ -0,0 +1,69 @@
+package balances
+
+import (
+	"context"
+	"time"
+
+	"apex-arbitrage/pkg/execution"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CoinbasePoller keeps the Coinbase balances current by polling the accounts endpoint
+// @author VrushankPatel
+// @description The Coinbase Exchange feed has no balance channel, so the available balance of
+// every account is loaded over REST at a fixed interval
+type CoinbasePoller struct {
+	client   *execution.Coinbase
+	interval time.Duration
+}
+
+// NewCoinbasePoller creates a Coinbase account source
+// @author VrushankPatel
+// @description Polls every interval, or every 10 seconds when interval is not positive
+// @param client The authenticated Coinbase REST client
+// @param interval Time between polls
+// @return A pointer to the newly created CoinbasePoller
+func NewCoinbasePoller(client *execution.Coinbase, interval time.Duration) *CoinbasePoller {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &CoinbasePoller{client: client, interval: interval}
+}
+
+// Name returns the exchange name
+func (p *CoinbasePoller) Name() string {
+	return "Coinbase"
+}
+
+// Run polls the balances until the context is cancelled
+func (p *CoinbasePoller) Run(ctx context.Context, cache *Cache) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	loaded := false
+	for {
+		balances, err := p.client.Balances(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// Keep nothing that may be stale
+			cache.Invalidate(p.Name())
+			loaded = false
+			log.Errorf("[Balances] Failed to poll Coinbase balances: %v", err)
+		} else {
+			cache.Replace(p.Name(), balances)
+			if !loaded {
+				log.Infof("[Balances] Loaded %d Coinbase balances, polling every %s", len(balances), p.interval)
+				loaded = true
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
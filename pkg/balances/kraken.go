@@ -0,0 +1,146 @@
+package balances
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"apex-arbitrage/pkg/execution"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// KrakenStreamURL is the production Kraken authenticated websocket (v2) endpoint
+const KrakenStreamURL = "wss://ws-auth.kraken.com/v2"
+
+// KrakenStream keeps the Kraken balances current from the private balances channel
+// @author VrushankPatel
+// @description Subscribes with a token from GetWebSocketsToken; the channel sends a snapshot
+// of every asset followed by an update for each ledger entry. Only spot wallets are counted.
+type KrakenStream struct {
+	client *execution.Kraken
+	wsURL  string
+}
+
+// krakenBalanceMessage is a message on the v2 private websocket
+type krakenBalanceMessage struct {
+	Channel string `json:"channel"`
+	Type    string `json:"type"`
+	Method  string `json:"method"`
+	Success *bool  `json:"success"`
+	Error   string `json:"error"`
+	Data    []struct {
+		Asset      string  `json:"asset"`
+		Balance    float64 `json:"balance"`
+		WalletType string  `json:"wallet_type"`
+		Wallets    []struct {
+			Type    string  `json:"type"`
+			Balance float64 `json:"balance"`
+		} `json:"wallets"`
+	} `json:"data"`
+}
+
+// NewKrakenStream creates a Kraken account source
+// @author VrushankPatel
+// @description Uses the REST client for the websocket token
+// @param client The authenticated Kraken REST client
+// @param wsURL The authenticated websocket URL, KrakenStreamURL when empty
+// @return A pointer to the newly created KrakenStream
+func NewKrakenStream(client *execution.Kraken, wsURL string) *KrakenStream {
+	if wsURL == "" {
+		wsURL = KrakenStreamURL
+	}
+	return &KrakenStream{client: client, wsURL: wsURL}
+}
+
+// Name returns the exchange name
+func (s *KrakenStream) Name() string {
+	return "Kraken"
+}
+
+// Run streams the balances until the context is cancelled
+func (s *KrakenStream) Run(ctx context.Context, cache *Cache) {
+	runWithReconnect(ctx, cache, s.Name(), func(ctx context.Context) error {
+		return s.stream(ctx, cache)
+	})
+}
+
+// stream subscribes to the balances channel and applies its messages until it fails
+func (s *KrakenStream) stream(ctx context.Context, cache *Cache) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	token, err := s.client.WebSocketsToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get websocket token: %v", err)
+	}
+	conn, err := dial(ctx, s.wsURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	subscribeMsg := map[string]interface{}{
+		"method": "subscribe",
+		"params": map[string]interface{}{
+			"channel":  "balances",
+			"token":    token,
+			"snapshot": true,
+		},
+	}
+	if err := conn.WriteJSON(subscribeMsg); err != nil {
+		return fmt.Errorf("failed to subscribe: %v", err)
+	}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var msg krakenBalanceMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			log.Debugf("[Balances] Unparseable Kraken message: %s", string(message))
+			continue
+		}
+		if msg.Method == "subscribe" && msg.Success != nil && !*msg.Success {
+			return fmt.Errorf("subscription rejected: %s", msg.Error)
+		}
+		if msg.Channel != "balances" {
+			continue
+		}
+
+		switch msg.Type {
+		case "snapshot":
+			snapshot := make(map[string]float64, len(msg.Data))
+			for _, entry := range msg.Data {
+				balance := entry.Balance
+				if len(entry.Wallets) > 0 {
+					balance = 0
+					for _, wallet := range entry.Wallets {
+						if wallet.Type == "spot" {
+							balance += wallet.Balance
+						}
+					}
+				}
+				snapshot[krakenAsset(entry.Asset)] = balance
+			}
+			cache.Replace(s.Name(), snapshot)
+			log.Infof("[Balances] Loaded %d Kraken balances, streaming updates", len(snapshot))
+		case "update":
+			for _, entry := range msg.Data {
+				if entry.WalletType == "" || entry.WalletType == "spot" {
+					cache.Set(s.Name(), krakenAsset(entry.Asset), entry.Balance)
+				}
+			}
+		}
+	}
+}
+
+// krakenAsset converts Kraken's name for bitcoin to the one used in trading pairs
+func krakenAsset(asset string) string {
+	if asset == "XBT" {
+		return "BTC"
+	}
+	return asset
+}
@@ -0,0 +1,58 @@
+package balances
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+// reconnectDelay is how long a source waits before reconnecting after an error
+const reconnectDelay = 5 * time.Second
+
+// Source keeps the balances of one exchange current in the cache
+// @author VrushankPatel
+// @description Implemented by the authenticated account stream or poller of each exchange
+type Source interface {
+	// Name returns the exchange name the balances are cached under
+	Name() string
+
+	// Run loads the balances and keeps them current until the context is cancelled
+	Run(ctx context.Context, cache *Cache)
+}
+
+// runWithReconnect calls connect until the context is cancelled, invalidating the exchange's
+// balances and waiting reconnectDelay whenever it fails
+func runWithReconnect(ctx context.Context, cache *Cache, name string, connect func(context.Context) error) {
+	for {
+		err := connect(ctx)
+		cache.Invalidate(name)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Errorf("[Balances] %s account stream failed: %v", name, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+			log.Infof("[Balances] Reconnecting to %s account stream...", name)
+		}
+	}
+}
+
+// dial opens a websocket connection that is closed when the context is cancelled
+func dial(ctx context.Context, url string) (*websocket.Conn, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.HandshakeTimeout = 10 * time.Second
+	conn, _, err := dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return conn, nil
+}
@@ -0,0 +1,186 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time and tickers for the detector, exchanges and web server
+// @author VrushankPatel
+// @description Abstracts time.Now and time.NewTicker so tests and replays of recorded data can
+// control time instead of waiting on the wall clock
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// NewTicker returns a ticker that fires every d
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks of a Clock on a channel
+// @author VrushankPatel
+// @description Mirrors time.Ticker; like it, a tick is dropped when the previous one was not received yet
+type Ticker interface {
+	// C returns the channel the ticks are delivered on
+	C() <-chan time.Time
+
+	// Stop turns off the ticker; no more ticks are delivered afterwards
+	Stop()
+}
+
+// Real returns the clock backed by the time package
+// @author VrushankPatel
+// @description The clock used by default everywhere outside tests and replays
+// @return The wall clock
+func Real() Clock {
+	return realClock{}
+}
+
+// realClock implements Clock with the time package
+type realClock struct{}
+
+// Now returns the wall clock time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker returns a time.Ticker wrapped as a Ticker
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker adapts time.Ticker to the Ticker interface
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+// C returns the ticker's channel
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+// Stop stops the ticker
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// Fake is a clock that only moves when told to
+// @author VrushankPatel
+// @description Deterministic clock for tests and accelerated replays. Advance and Set move time forward
+// and fire every ticker that came due, in order, so one call may deliver several ticks per ticker
+// (subject to the same dropping as a real ticker when the receiver falls behind).
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// fakeTicker is a ticker driven by a Fake clock
+type fakeTicker struct {
+	clock    *Fake
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+	stopped  bool
+}
+
+// NewFake creates a fake clock set to the given time
+// @author VrushankPatel
+// @description Creates and initializes a new Fake clock
+// @param start The initial time of the clock
+// @return A pointer to the newly created Fake clock
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+// Now returns the fake clock's current time
+// @author VrushankPatel
+// @description Reads the time last set by NewFake, Advance or Set
+// @return The current fake time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTicker returns a ticker that fires every d of fake time
+// @author VrushankPatel
+// @description The first tick is due d after the current fake time
+// @param d The tick interval; must be positive
+// @return The ticker
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{
+		clock:    f,
+		c:        make(chan time.Time, 1),
+		interval: d,
+		next:     f.now.Add(d),
+	}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Advance moves the fake clock forward by d and fires the tickers that came due
+// @author VrushankPatel
+// @description Shorthand for Set(Now().Add(d))
+// @param d The duration to move forward by
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves the fake clock to t and fires the tickers that came due on the way
+// @author VrushankPatel
+// @description Ticks are delivered in time order across tickers; the clock reads each tick's time while
+// it is delivered. Setting a time in the past is ignored.
+// @param t The new time
+func (f *Fake) Set(t time.Time) {
+	for {
+		f.mu.Lock()
+		var due *fakeTicker
+		for _, ticker := range f.tickers {
+			if !ticker.stopped && !ticker.next.After(t) && (due == nil || ticker.next.Before(due.next)) {
+				due = ticker
+			}
+		}
+		if due == nil {
+			if t.After(f.now) {
+				f.now = t
+			}
+			f.mu.Unlock()
+			return
+		}
+		at := due.next
+		due.next = at.Add(due.interval)
+		if at.After(f.now) {
+			f.now = at
+		}
+		f.mu.Unlock()
+
+		select {
+		case due.c <- at:
+		default:
+		}
+	}
+}
+
+// C returns the channel the ticks are delivered on
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop turns off the ticker
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+	for i, ticker := range t.clock.tickers {
+		if ticker == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// expectTick receives the pending tick of a ticker and checks its time
+func expectTick(t *testing.T, ticker Ticker, want time.Time) {
+	t.Helper()
+	select {
+	case at := <-ticker.C():
+		if !at.Equal(want) {
+			t.Errorf("tick at %v, want %v", at, want)
+		}
+	default:
+		t.Errorf("no tick, want one at %v", want)
+	}
+}
+
+// expectNoTick checks that a ticker has no pending tick
+func expectNoTick(t *testing.T, ticker Ticker) {
+	t.Helper()
+	select {
+	case at := <-ticker.C():
+		t.Errorf("unexpected tick at %v", at)
+	default:
+	}
+}
+
+func TestFakeAdvanceFiresDueTickers(t *testing.T) {
+	clock := NewFake(start)
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(500 * time.Millisecond)
+	expectNoTick(t, ticker)
+
+	clock.Advance(500 * time.Millisecond)
+	expectTick(t, ticker, start.Add(time.Second))
+	if now := clock.Now(); !now.Equal(start.Add(time.Second)) {
+		t.Errorf("now %v, want %v", now, start.Add(time.Second))
+	}
+}
+
+func TestFakeDropsTicksLikeTimeTicker(t *testing.T) {
+	clock := NewFake(start)
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// Only the first of three due ticks fits the channel while nothing receives
+	clock.Advance(3 * time.Second)
+	expectTick(t, ticker, start.Add(time.Second))
+	expectNoTick(t, ticker)
+
+	// The schedule is kept: the next tick is due a second later
+	clock.Advance(time.Second)
+	expectTick(t, ticker, start.Add(4*time.Second))
+}
+
+func TestFakeSetIgnoresPast(t *testing.T) {
+	clock := NewFake(start)
+	clock.Set(start.Add(time.Minute))
+	clock.Set(start)
+	if now := clock.Now(); !now.Equal(start.Add(time.Minute)) {
+		t.Errorf("now %v after setting a past time, want %v", now, start.Add(time.Minute))
+	}
+}
+
+func TestFakeStoppedTickerDoesNotFire(t *testing.T) {
+	clock := NewFake(start)
+	stopped := clock.NewTicker(time.Second)
+	running := clock.NewTicker(2 * time.Second)
+	defer running.Stop()
+	stopped.Stop()
+
+	clock.Advance(2 * time.Second)
+	expectNoTick(t, stopped)
+	expectTick(t, running, start.Add(2*time.Second))
+}
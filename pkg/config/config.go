@@ -1,11 +1,14 @@
 package config
 
 import (
+        "fmt"
         "os"
         "strconv"
+        "strings"
+        "sync"
+        "time"
 
         "github.com/joho/godotenv"
-        log "github.com/sirupsen/logrus"
 )
 
 // Trading pair represents a market pair like BTC/USDT
@@ -14,6 +17,12 @@ type TradingPair struct {
         QuoteCurrency string
 }
 
+// TradeSize is the amount traded per opportunity on one pair, in its base or quote currency
+type TradeSize struct {
+        Amount   float64
+        Currency string
+}
+
 // ExchangeConfig stores configuration for a specific exchange
 type ExchangeConfig struct {
         Enabled  bool
@@ -21,6 +30,11 @@ type ExchangeConfig struct {
         MakerFee float64
         APIKey   string
         APISecret string
+        Passphrase string
+        // REST base URL for order placement (production when empty)
+        RESTURL  string
+        // Account balance stream URL (production when empty)
+        AccountStreamURL string
 }
 
 // ExchangesConfig holds configuration for all exchanges
@@ -43,21 +57,123 @@ type Config struct {
 
         // Application configuration
         SimulationMode     bool
+        SimulationScenario string
         MinProfitThreshold float64
-        LogLevel           string
+        CycleSearch        bool
+        CostModelFile      string
+        // Configuration file the settings were read from
+        ConfigFile string
+        // Reload the configuration when it changes on disk, checking every interval
+        ConfigWatch         bool
+        ConfigWatchInterval time.Duration
+
+        // Logging
+        LogLevel  string
+        LogFile   string
+        LogFormat string
+
+        // Closed opportunity log ("csv" or "json" lines)
+        OpportunityLogFile   string
+        OpportunityLogFormat string
+
+        // Execution modelling
+        SlippageModel     string
+        SlippageBps       float64
+        SlippageImpactBps float64
+        TargetNotional    float64
+        // Trade size per pair ("BTC/USDT"), overriding TargetNotional
+        TradeSizes map[string]TradeSize
+
+        // Paper trading
+        PaperTrading  bool
+        PaperBalances map[string]float64
+        PaperLatency  time.Duration
+
+        // Live order execution
+        ExecutionEnabled        bool
+        ExecutionLegTimeout     time.Duration
+        ExecutionPollInterval   time.Duration
+        ExecutionMaxSlippageBps float64
+
+        // Pre-trade risk limits (0 disables a limit)
+        RiskMaxTradeNotional   float64
+        RiskMaxExposure        float64
+        RiskDailyLossLimit     float64
+        RiskMaxTradesPerMinute int
+        KillSwitchToken        string // Bearer token required to release the kill switch over HTTP
+
+        // Account balance tracking
+        BalanceTracking     bool
+        BalancePollInterval time.Duration
+
+        // Inventory rebalancing
+        RebalanceTargets   map[string]float64
+        RebalanceThreshold float64
+        RebalanceInterval  time.Duration
+        RebalanceMaxCost   float64 // Percentage of a transfer its fees may take, 0 for no limit
+
+        // Market data recording
+        RecorderEnabled bool
+        RecorderDir     string
+        RecorderFrames  bool
         
         // Trading pairs to monitor
         TradingPairs []TradingPair
         
         // Exchange-specific configurations
         Exchanges ExchangesConfig
+
+        // Problems found while loading, reported by Validate
+        problems []string
 }
 
-// LoadConfig reads configuration from .env file or environment variables
+// envReader reads typed environment variables, recording every value it cannot parse
+type envReader struct {
+        problems []string
+}
+
+// dotEnv records the variables set from the .env file, so loading again can replace them while
+// variables of the process environment keep taking precedence
+var dotEnv = struct {
+        sync.Mutex
+        keys map[string]bool
+}{keys: make(map[string]bool)}
+
+// LoadConfig reads configuration from config.yaml, the .env file and environment variables
+// @author VrushankPatel
+// @description Environment variables (including those from .env) take precedence over the
+// configuration file, which takes precedence over the built-in defaults. The file is
+// config.yaml unless CONFIG_FILE names another one; a missing config.yaml is not an error.
+// Invalid values do not fail loading; they are kept back for Validate. Loading again picks
+// up changes to both files.
+// @return The configuration, or an error if the configuration file cannot be read or parsed
 func LoadConfig() (*Config, error) {
+        return LoadConfigFile("")
+}
+
+// LoadConfigFile reads configuration like LoadConfig from the given configuration file
+// @author VrushankPatel
+// @description An empty path falls back to CONFIG_FILE and then config.yaml
+// @param path Path of the configuration file, which must exist when given
+// @return The configuration, or an error if the configuration file cannot be read or parsed
+func LoadConfigFile(path string) (*Config, error) {
         // Load .env file if it exists
-        _ = godotenv.Load()
+        loadDotEnv()
+
+        explicit := path != ""
+        if !explicit {
+                path, explicit = os.LookupEnv("CONFIG_FILE")
+        }
+        if !explicit {
+                path = DefaultConfigFile
+        }
+        file, err := readConfigFile(path, explicit)
+        if err != nil {
+                return nil, err
+        }
+        binance, kraken, coinbase := file.exchange("binance"), file.exchange("kraken"), file.exchange("coinbase")
 
+        env := &envReader{}
         config := &Config{
                 // Exchange API keys
                 BinanceAPIKey:     getEnv("BINANCE_API_KEY", ""),
@@ -69,45 +185,136 @@ func LoadConfig() (*Config, error) {
                 CoinbasePassphrase: getEnv("COINBASE_PASSPHRASE", ""),
 
                 // Application configuration
-                SimulationMode:     getBoolEnv("SIMULATION_MODE", true),
-                MinProfitThreshold: getFloatEnv("MIN_PROFIT_THRESHOLD", 0.1),
-                LogLevel:           getEnv("LOG_LEVEL", "info"),
+                SimulationMode:     env.getBoolEnv("SIMULATION_MODE", true),
+                SimulationScenario: getEnv("SIMULATION_SCENARIO", ""),
+                MinProfitThreshold: env.getFloatEnv("MIN_PROFIT_THRESHOLD", file.MinProfitThreshold),
+                CycleSearch:        env.getBoolEnv("CYCLE_SEARCH", false),
+                CostModelFile:      getEnv("COST_MODEL", ""),
+                ConfigFile:         path,
+
+                // Configuration reloading
+                ConfigWatch:         env.getBoolEnv("CONFIG_WATCH", true),
+                ConfigWatchInterval: env.getDurationEnv("CONFIG_WATCH_INTERVAL", 2*time.Second),
+
+                // Logging
+                LogLevel:  getEnv("LOG_LEVEL", file.Logging.Level),
+                LogFile:   getEnv("LOG_FILE", file.Logging.File),
+                LogFormat: getEnv("LOG_FORMAT", file.Logging.Format),
+
+                // Closed opportunity log
+                OpportunityLogFile:   getEnv("OPPORTUNITIES_LOG_FILE", file.Opportunities.LogFile),
+                OpportunityLogFormat: getEnv("OPPORTUNITIES_LOG_FORMAT", file.Opportunities.LogFormat),
+
+                // Execution modelling
+                SlippageModel:     getEnv("SLIPPAGE_MODEL", "book"),
+                SlippageBps:       env.getFloatEnv("SLIPPAGE_BPS", 2),
+                SlippageImpactBps: env.getFloatEnv("SLIPPAGE_IMPACT_BPS", 10),
+                TargetNotional:    env.getFloatEnv("TARGET_NOTIONAL", 1000),
+                TradeSizes:        env.getTradeSizesEnv("TRADE_SIZES"),
+
+                // Paper trading
+                PaperTrading:  env.getBoolEnv("PAPER_TRADING", false),
+                PaperBalances: env.getAmountsEnv("PAPER_BALANCES", "USDT=10000,BTC=0.2,ETH=3"),
+                PaperLatency:  env.getDurationEnv("PAPER_LATENCY", 150*time.Millisecond),
+
+                // Live order execution
+                ExecutionEnabled:        env.getBoolEnv("EXECUTION_ENABLED", false),
+                ExecutionLegTimeout:     env.getDurationEnv("EXECUTION_LEG_TIMEOUT", 5*time.Second),
+                ExecutionPollInterval:   env.getDurationEnv("EXECUTION_POLL_INTERVAL", 250*time.Millisecond),
+                ExecutionMaxSlippageBps: env.getFloatEnv("EXECUTION_MAX_SLIPPAGE_BPS", 10),
+
+                // Pre-trade risk limits
+                RiskMaxTradeNotional:   env.getFloatEnv("RISK_MAX_TRADE_NOTIONAL", 5000),
+                RiskMaxExposure:        env.getFloatEnv("RISK_MAX_EXPOSURE", 20000),
+                RiskDailyLossLimit:     env.getFloatEnv("RISK_DAILY_LOSS_LIMIT", 500),
+                RiskMaxTradesPerMinute: env.getIntEnv("RISK_MAX_TRADES_PER_MINUTE", 10),
+                KillSwitchToken:        getEnv("KILL_SWITCH_TOKEN", ""),
+
+                // Account balance tracking
+                BalanceTracking:     env.getBoolEnv("BALANCE_TRACKING", false),
+                BalancePollInterval: env.getDurationEnv("BALANCE_POLL_INTERVAL", 10*time.Second),
+
+                // Inventory rebalancing (no targets splits every asset evenly)
+                RebalanceTargets:   env.getAmountsEnv("REBALANCE_TARGETS", ""),
+                RebalanceThreshold: env.getFloatEnv("REBALANCE_THRESHOLD", 0.1),
+                RebalanceInterval:  env.getDurationEnv("REBALANCE_INTERVAL", time.Minute),
+                RebalanceMaxCost:   env.getFloatEnv("REBALANCE_MAX_COST_PERCENTAGE", 1),
+
+                // Market data recording
+                RecorderEnabled: env.getBoolEnv("RECORDER_ENABLED", false),
+                RecorderDir:     getEnv("RECORDER_DIR", "data/ticks"),
+                RecorderFrames:  env.getBoolEnv("RECORDER_RAW_FRAMES", false),
                 
-                // Default trading pairs
-                TradingPairs: []TradingPair{
-                        {BaseCurrency: "BTC", QuoteCurrency: "USDT"},
-                        {BaseCurrency: "ETH", QuoteCurrency: "USDT"},
-                },
+                // Trading pairs to monitor
+                TradingPairs: env.getPairsEnv("TRADING_PAIRS", file.tradingPairs()),
                 
                 // Exchange configurations
                 Exchanges: ExchangesConfig{
                         Binance: ExchangeConfig{
-                                Enabled:   true,
-                                TakerFee:  getFloatEnv("BINANCE_TAKER_FEE", 0.001), // 0.1%
-                                MakerFee:  getFloatEnv("BINANCE_MAKER_FEE", 0.0008), // 0.08%
+                                Enabled:   env.getBoolEnv("BINANCE_ENABLED", binance.Enabled),
+                                TakerFee:  env.getFloatEnv("BINANCE_TAKER_FEE", binance.TakerFee),
+                                MakerFee:  env.getFloatEnv("BINANCE_MAKER_FEE", binance.MakerFee),
                                 APIKey:    getEnv("BINANCE_API_KEY", ""),
                                 APISecret: getEnv("BINANCE_API_SECRET", ""),
+                                RESTURL:   getEnv("BINANCE_REST_URL", ""),
+                                AccountStreamURL: getEnv("BINANCE_ACCOUNT_STREAM_URL", ""),
                         },
                         Kraken: ExchangeConfig{
-                                Enabled:   true,
-                                TakerFee:  getFloatEnv("KRAKEN_TAKER_FEE", 0.0026), // 0.26%
-                                MakerFee:  getFloatEnv("KRAKEN_MAKER_FEE", 0.0016), // 0.16%
+                                Enabled:   env.getBoolEnv("KRAKEN_ENABLED", kraken.Enabled),
+                                TakerFee:  env.getFloatEnv("KRAKEN_TAKER_FEE", kraken.TakerFee),
+                                MakerFee:  env.getFloatEnv("KRAKEN_MAKER_FEE", kraken.MakerFee),
                                 APIKey:    getEnv("KRAKEN_API_KEY", ""),
                                 APISecret: getEnv("KRAKEN_API_SECRET", ""),
+                                RESTURL:   getEnv("KRAKEN_REST_URL", ""),
+                                AccountStreamURL: getEnv("KRAKEN_ACCOUNT_STREAM_URL", ""),
                         },
                         Coinbase: ExchangeConfig{
-                                Enabled:   getBoolEnv("COINBASE_ENABLED", false),
-                                TakerFee:  getFloatEnv("COINBASE_TAKER_FEE", 0.0025), // 0.25%
-                                MakerFee:  getFloatEnv("COINBASE_MAKER_FEE", 0.0015), // 0.15%
+                                Enabled:   env.getBoolEnv("COINBASE_ENABLED", coinbase.Enabled),
+                                TakerFee:  env.getFloatEnv("COINBASE_TAKER_FEE", coinbase.TakerFee),
+                                MakerFee:  env.getFloatEnv("COINBASE_MAKER_FEE", coinbase.MakerFee),
                                 APIKey:    getEnv("COINBASE_API_KEY", ""),
                                 APISecret: getEnv("COINBASE_API_SECRET", ""),
+                                Passphrase: getEnv("COINBASE_PASSPHRASE", ""),
+                                RESTURL:   getEnv("COINBASE_REST_URL", ""),
                         },
                 },
         }
+        config.problems = append(file.problems(), env.problems...)
 
         return config, nil
 }
 
+// loadDotEnv sets the variables of the .env file, if it exists, that the process environment
+// does not set itself. Variables set by an earlier load are updated, or unset when they were
+// removed from the file.
+func loadDotEnv() {
+        values, err := godotenv.Read()
+        if err != nil {
+                values = map[string]string{}
+        }
+
+        dotEnv.Lock()
+        defer dotEnv.Unlock()
+        for key := range dotEnv.keys {
+                if _, exists := values[key]; !exists {
+                        os.Unsetenv(key)
+                        delete(dotEnv.keys, key)
+                }
+        }
+        for key, value := range values {
+                if _, exists := os.LookupEnv(key); exists && !dotEnv.keys[key] {
+                        continue
+                }
+                os.Setenv(key, value)
+                dotEnv.keys[key] = true
+        }
+}
+
+// invalid records a value that could not be used
+func (r *envReader) invalid(format string, args ...interface{}) {
+        r.problems = append(r.problems, fmt.Sprintf(format, args...))
+}
+
 // Helper function to read an environment variable or return a default value
 func getEnv(key, defaultValue string) string {
         if value, exists := os.LookupEnv(key); exists {
@@ -117,25 +324,123 @@ func getEnv(key, defaultValue string) string {
 }
 
 // Helper function to read a boolean environment variable
-func getBoolEnv(key string, defaultValue bool) bool {
+func (r *envReader) getBoolEnv(key string, defaultValue bool) bool {
         if valueStr, exists := os.LookupEnv(key); exists {
                 value, err := strconv.ParseBool(valueStr)
                 if err == nil {
                         return value
                 }
-                log.Warnf("Invalid boolean value for %s: %s", key, valueStr)
+                r.invalid("%s: %q is not a boolean (true or false)", key, valueStr)
+        }
+        return defaultValue
+}
+
+// Helper function to read amounts per asset or exchange, e.g. "USDT=10000,BTC=0.2"; invalid entries are skipped
+func (r *envReader) getAmountsEnv(key, defaultValue string) map[string]float64 {
+        amounts := make(map[string]float64)
+        for _, entry := range strings.Split(getEnv(key, defaultValue), ",") {
+                entry = strings.TrimSpace(entry)
+                if entry == "" {
+                        continue
+                }
+                asset, amountStr, _ := strings.Cut(entry, "=")
+                amount, err := strconv.ParseFloat(strings.TrimSpace(amountStr), 64)
+                if err != nil || amount < 0 || strings.TrimSpace(asset) == "" {
+                        r.invalid("%s: invalid entry %q, expected NAME=AMOUNT with a non-negative amount", key, entry)
+                        continue
+                }
+                amounts[strings.TrimSpace(asset)] = amount
+        }
+        return amounts
+}
+
+// Helper function to read trading pairs, e.g. "BTC/USDT,ETH/USDT"; invalid entries are skipped
+func (r *envReader) getPairsEnv(key string, defaultValue []TradingPair) []TradingPair {
+        valueStr, exists := os.LookupEnv(key)
+        if !exists {
+                return defaultValue
+        }
+        pairs := []TradingPair{}
+        for _, entry := range strings.Split(valueStr, ",") {
+                entry = strings.TrimSpace(entry)
+                if entry == "" {
+                        continue
+                }
+                base, quote, found := strings.Cut(entry, "/")
+                if !found || strings.TrimSpace(base) == "" || strings.TrimSpace(quote) == "" {
+                        r.invalid("%s: invalid trading pair %q, expected BASE/QUOTE", key, entry)
+                        continue
+                }
+                pairs = append(pairs, TradingPair{
+                        BaseCurrency:  strings.ToUpper(strings.TrimSpace(base)),
+                        QuoteCurrency: strings.ToUpper(strings.TrimSpace(quote)),
+                })
+        }
+        return pairs
+}
+
+// Helper function to read a duration environment variable (e.g. "150ms")
+func (r *envReader) getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+        if valueStr, exists := os.LookupEnv(key); exists {
+                value, err := time.ParseDuration(valueStr)
+                if err == nil {
+                        return value
+                }
+                r.invalid("%s: %q is not a duration (e.g. 150ms, 5s, 1m)", key, valueStr)
+        }
+        return defaultValue
+}
+
+// Helper function to read per-pair trade sizes, e.g. "BTC/USDT=0.05 BTC,ETH/USDT=2000 USDT".
+// The currency must be the base or quote currency of the pair; invalid entries are skipped.
+func (r *envReader) getTradeSizesEnv(key string) map[string]TradeSize {
+        sizes := make(map[string]TradeSize)
+        valueStr, exists := os.LookupEnv(key)
+        if !exists {
+                return sizes
+        }
+        for _, entry := range strings.Split(valueStr, ",") {
+                entry = strings.TrimSpace(entry)
+                if entry == "" {
+                        continue
+                }
+                pair, size, _ := strings.Cut(entry, "=")
+                fields := strings.Fields(size)
+                currencies := strings.Split(strings.TrimSpace(pair), "/")
+                if len(fields) != 2 || len(currencies) != 2 {
+                        r.invalid("%s: invalid trade size %q, expected BASE/QUOTE=AMOUNT CURRENCY in the base or quote currency", key, entry)
+                        continue
+                }
+                amount, err := strconv.ParseFloat(fields[0], 64)
+                if err != nil || amount <= 0 || (fields[1] != currencies[0] && fields[1] != currencies[1]) {
+                        r.invalid("%s: invalid trade size %q, expected BASE/QUOTE=AMOUNT CURRENCY in the base or quote currency", key, entry)
+                        continue
+                }
+                sizes[strings.TrimSpace(pair)] = TradeSize{Amount: amount, Currency: fields[1]}
+        }
+        return sizes
+}
+
+// Helper function to read an integer environment variable
+func (r *envReader) getIntEnv(key string, defaultValue int) int {
+        if valueStr, exists := os.LookupEnv(key); exists {
+                value, err := strconv.Atoi(valueStr)
+                if err == nil {
+                        return value
+                }
+                r.invalid("%s: %q is not an integer", key, valueStr)
         }
         return defaultValue
 }
 
 // Helper function to read a float environment variable
-func getFloatEnv(key string, defaultValue float64) float64 {
+func (r *envReader) getFloatEnv(key string, defaultValue float64) float64 {
         if valueStr, exists := os.LookupEnv(key); exists {
                 value, err := strconv.ParseFloat(valueStr, 64)
                 if err == nil {
                         return value
                 }
-                log.Warnf("Invalid float value for %s: %s", key, valueStr)
+                r.invalid("%s: %q is not a number", key, valueStr)
         }
         return defaultValue
 }
\ No newline at end of file
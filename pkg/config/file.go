@@ -0,0 +1,151 @@
+package config
+
+import (
+        "bytes"
+        "errors"
+        "fmt"
+        "io"
+        "os"
+        "sort"
+        "strings"
+
+        "gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is the configuration file read when CONFIG_FILE is not set
+const DefaultConfigFile = "config.yaml"
+
+// fileConfig mirrors the structure of config.yaml
+type fileConfig struct {
+        MinProfitThreshold float64                 `yaml:"minProfitThreshold"`
+        TradingPairs       []filePair              `yaml:"tradingPairs"`
+        Exchanges          map[string]fileExchange `yaml:"exchanges"`
+        Logging            struct {
+                Level  string `yaml:"level"`
+                File   string `yaml:"file"`
+                Format string `yaml:"format"`
+        } `yaml:"logging"`
+        Opportunities      struct {
+                LogFile   string `yaml:"logFile"`
+                LogFormat string `yaml:"logFormat"`
+        } `yaml:"opportunities"`
+}
+
+// filePair is a trading pair entry of config.yaml
+type filePair struct {
+        BaseCurrency  string `yaml:"baseCurrency"`
+        QuoteCurrency string `yaml:"quoteCurrency"`
+}
+
+// fileExchange is an exchange section of config.yaml; settings left out are nil
+type fileExchange struct {
+        Enabled  *bool    `yaml:"enabled"`
+        TakerFee *float64 `yaml:"takerFee"`
+        MakerFee *float64 `yaml:"makerFee"`
+}
+
+// exchangeSettings are the settings of one exchange that config.yaml can change
+type exchangeSettings struct {
+        Enabled  bool
+        TakerFee float64
+        MakerFee float64
+}
+
+// defaultExchanges are the settings of every supported exchange, keyed as in config.yaml
+var defaultExchanges = map[string]exchangeSettings{
+        "binance":  {Enabled: true, TakerFee: 0.001, MakerFee: 0.0008},   // 0.1% / 0.08%
+        "kraken":   {Enabled: true, TakerFee: 0.0026, MakerFee: 0.0016},  // 0.26% / 0.16%
+        "coinbase": {Enabled: false, TakerFee: 0.0025, MakerFee: 0.0015}, // 0.25% / 0.15%
+}
+
+// defaultFileConfig returns the settings used for everything config.yaml leaves out
+func defaultFileConfig() fileConfig {
+        file := fileConfig{
+                MinProfitThreshold: 0.001,
+                TradingPairs: []filePair{
+                        {BaseCurrency: "BTC", QuoteCurrency: "USDT"},
+                        {BaseCurrency: "ETH", QuoteCurrency: "USDT"},
+                },
+        }
+        file.Logging.Level = "info"
+        file.Logging.File = "data/arbitrage.log"
+        file.Logging.Format = "text"
+        file.Opportunities.LogFile = "data/opportunities.csv"
+        file.Opportunities.LogFormat = "csv"
+        return file
+}
+
+// readConfigFile reads a configuration file over the defaults. A missing file is only an
+// error when required, i.e. when its path was given explicitly; unknown keys are always errors.
+func readConfigFile(path string, required bool) (fileConfig, error) {
+        file := defaultFileConfig()
+
+        data, err := os.ReadFile(path)
+        if errors.Is(err, os.ErrNotExist) && !required {
+                return file, nil
+        }
+        if err != nil {
+                return file, fmt.Errorf("failed to read config file: %v", err)
+        }
+
+        decoder := yaml.NewDecoder(bytes.NewReader(data))
+        decoder.KnownFields(true)
+        if err := decoder.Decode(&file); err != nil && err != io.EOF {
+                return file, fmt.Errorf("invalid config file %s: %v", path, err)
+        }
+        return file, nil
+}
+
+// exchange returns the settings of a supported exchange, with the defaults for what the file leaves out
+func (f fileConfig) exchange(name string) exchangeSettings {
+        settings := defaultExchanges[name]
+        for key, section := range f.Exchanges {
+                if strings.ToLower(key) != name {
+                        continue
+                }
+                if section.Enabled != nil {
+                        settings.Enabled = *section.Enabled
+                }
+                if section.TakerFee != nil {
+                        settings.TakerFee = *section.TakerFee
+                }
+                if section.MakerFee != nil {
+                        settings.MakerFee = *section.MakerFee
+                }
+        }
+        return settings
+}
+
+// problems returns what the file configures that is not supported, for Validate
+func (f fileConfig) problems() []string {
+        problems := []string{}
+        for key := range f.Exchanges {
+                if _, supported := defaultExchanges[strings.ToLower(key)]; !supported {
+                        problems = append(problems, fmt.Sprintf("exchanges.%s: unknown exchange, expected one of %s", key, strings.Join(supportedExchanges(), ", ")))
+                }
+        }
+        sort.Strings(problems)
+        return problems
+}
+
+// supportedExchanges returns the names of the supported exchanges as used in config.yaml
+func supportedExchanges() []string {
+        names := make([]string, 0, len(defaultExchanges))
+        for name := range defaultExchanges {
+                names = append(names, name)
+        }
+        sort.Strings(names)
+        return names
+}
+
+// tradingPairs converts the pairs of the file to TradingPairs
+func (f fileConfig) tradingPairs() []TradingPair {
+        pairs := make([]TradingPair, 0, len(f.TradingPairs))
+        for _, pair := range f.TradingPairs {
+                pairs = append(pairs, TradingPair{
+                        BaseCurrency:  strings.ToUpper(strings.TrimSpace(pair.BaseCurrency)),
+                        QuoteCurrency: strings.ToUpper(strings.TrimSpace(pair.QuoteCurrency)),
+                })
+        }
+        return pairs
+}
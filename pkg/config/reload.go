@@ -0,0 +1,80 @@
+package config
+
+import (
+        "fmt"
+        "reflect"
+        "strings"
+)
+
+// Change is a setting whose value differs between two configurations
+type Change struct {
+        Setting string `json:"setting"`
+        Old     string `json:"old"`
+        New     string `json:"new"`
+}
+
+// Reload compares a newly loaded configuration with the one in effect
+// @author VrushankPatel
+// @description The profit threshold, the exchange fees and the trading pairs apply to a running
+// process; every other setting only takes effect on the next start, so it keeps its current value
+// @param next The newly loaded configuration, which should have passed Validate
+// @return The configuration in effect with the live settings of next, the live settings that
+// changed, and the names of the changed settings that need a restart
+func (c *Config) Reload(next *Config) (*Config, []Change, []string) {
+        applied := *c
+        applied.MinProfitThreshold = next.MinProfitThreshold
+        applied.TradingPairs = append([]TradingPair{}, next.TradingPairs...)
+        for _, exchange := range []struct{ current, next *ExchangeConfig }{
+                {&applied.Exchanges.Binance, &next.Exchanges.Binance},
+                {&applied.Exchanges.Kraken, &next.Exchanges.Kraken},
+                {&applied.Exchanges.Coinbase, &next.Exchanges.Coinbase},
+        } {
+                exchange.current.TakerFee = exchange.next.TakerFee
+                exchange.current.MakerFee = exchange.next.MakerFee
+        }
+
+        // Values of settings that need a restart are left out, as they include credentials
+        restart := []string{}
+        for _, change := range changes(reflect.ValueOf(applied), reflect.ValueOf(*next), "") {
+                restart = append(restart, change.Setting)
+        }
+        return &applied, changes(reflect.ValueOf(*c), reflect.ValueOf(applied), ""), restart
+}
+
+// changes lists the exported fields that differ between two values of the same struct type,
+// descending into nested structs
+func changes(old, next reflect.Value, prefix string) []Change {
+        result := []Change{}
+        for i := 0; i < old.NumField(); i++ {
+                field := old.Type().Field(i)
+                if !field.IsExported() {
+                        continue
+                }
+                name := prefix + field.Name
+                if field.Type.Kind() == reflect.Struct {
+                        result = append(result, changes(old.Field(i), next.Field(i), name+".")...)
+                        continue
+                }
+                if !reflect.DeepEqual(old.Field(i).Interface(), next.Field(i).Interface()) {
+                        result = append(result, Change{
+                                Setting: name,
+                                Old:     formatSetting(old.Field(i).Interface()),
+                                New:     formatSetting(next.Field(i).Interface()),
+                        })
+                }
+        }
+        return result
+}
+
+// formatSetting formats the value of a setting for logs, trading pairs as "BTC/USDT, ETH/USDT"
+func formatSetting(value interface{}) string {
+        pairs, isPairs := value.([]TradingPair)
+        if !isPairs {
+                return fmt.Sprint(value)
+        }
+        names := make([]string, 0, len(pairs))
+        for _, pair := range pairs {
+                names = append(names, pair.BaseCurrency+"/"+pair.QuoteCurrency)
+        }
+        return strings.Join(names, ", ")
+}
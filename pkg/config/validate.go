@@ -0,0 +1,206 @@
+package config
+
+import (
+        "fmt"
+        "math"
+        "sort"
+        "strings"
+        "time"
+
+        log "github.com/sirupsen/logrus"
+)
+
+// maxThreshold is the largest profit threshold, as a decimal, that is not taken for a percentage
+const maxThreshold = 0.05
+
+// maxFee is the largest fee, as a decimal, that is not taken for a percentage
+const maxFee = 0.02
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+        Problems []string
+}
+
+// Error returns the problems, one per line
+func (e *ValidationError) Error() string {
+        noun := "problems"
+        if len(e.Problems) == 1 {
+                noun = "problem"
+        }
+        return fmt.Sprintf("invalid configuration (%d %s):\n  - %s", len(e.Problems), noun, strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate checks the configuration and reports every problem at once
+// @author VrushankPatel
+// @description Covers values that could not be parsed, unknown exchanges, fee and threshold
+// ranges (both are decimal fractions, 0.001 = 0.1%), trading pairs, missing credentials for
+// live trading features, and the settings every component needs to start
+// @return A *ValidationError listing the problems, or nil if there are none
+func (c *Config) Validate() error {
+        problems := append([]string{}, c.problems...)
+        add := func(format string, args ...interface{}) {
+                problems = append(problems, fmt.Sprintf(format, args...))
+        }
+
+        // Thresholds and fees are decimals; values this large were almost certainly meant as percentages
+        if math.Abs(c.MinProfitThreshold) >= maxThreshold {
+                add("MIN_PROFIT_THRESHOLD (minProfitThreshold) is %g, i.e. %g%%; it is a decimal fraction, so 0.5%% is 0.005", c.MinProfitThreshold, c.MinProfitThreshold*100)
+        }
+        names := c.exchangeNames()
+        enabled := 0
+        for _, exchange := range c.exchanges() {
+                prefix, key := strings.ToUpper(exchange.name), strings.ToLower(exchange.name)
+                if exchange.cfg.TakerFee < 0 || exchange.cfg.TakerFee >= maxFee {
+                        add("%s_TAKER_FEE (exchanges.%s.takerFee) is %g; fees are decimal fractions between 0 and %g, so 0.1%% is 0.001", prefix, key, exchange.cfg.TakerFee, maxFee)
+                }
+                if exchange.cfg.MakerFee <= -maxFee || exchange.cfg.MakerFee >= maxFee {
+                        add("%s_MAKER_FEE (exchanges.%s.makerFee) is %g; fees are decimal fractions between -%g and %g, so 0.1%% is 0.001", prefix, key, exchange.cfg.MakerFee, maxFee, maxFee)
+                }
+                if !exchange.cfg.Enabled {
+                        continue
+                }
+                enabled++
+
+                // Reading market data is public, trading and account balances are not
+                if !c.SimulationMode && (c.ExecutionEnabled || c.BalanceTracking) {
+                        if exchange.cfg.APIKey == "" || exchange.cfg.APISecret == "" {
+                                add("%s_API_KEY and %s_API_SECRET are required for live execution and balance tracking on %s", prefix, prefix, exchange.name)
+                        }
+                        if exchange.name == "Coinbase" && exchange.cfg.Passphrase == "" {
+                                add("COINBASE_PASSPHRASE is required for live execution and balance tracking on Coinbase")
+                        }
+                }
+        }
+        if enabled == 0 {
+                add("no exchange is enabled; enable at least one of %s", strings.Join(names, ", "))
+        }
+
+        // Trading pairs
+        if len(c.TradingPairs) == 0 {
+                add("TRADING_PAIRS (tradingPairs) is empty; at least one pair is required")
+        }
+        monitored := make(map[string]bool, len(c.TradingPairs))
+        for _, pair := range c.TradingPairs {
+                name := pair.BaseCurrency + "/" + pair.QuoteCurrency
+                switch {
+                case pair.BaseCurrency == "" || pair.QuoteCurrency == "":
+                        add("trading pair %q needs both a base and a quote currency", name)
+                case pair.BaseCurrency == pair.QuoteCurrency:
+                        add("trading pair %s trades a currency against itself", name)
+                case monitored[name]:
+                        add("trading pair %s is listed more than once", name)
+                }
+                monitored[name] = true
+        }
+        for _, pair := range sortedKeys(c.TradeSizes) {
+                if !monitored[pair] {
+                        add("TRADE_SIZES has a size for %s, which is not a monitored trading pair", pair)
+                }
+        }
+        for _, exchange := range sortedKeys(c.RebalanceTargets) {
+                known := false
+                for _, name := range names {
+                        known = known || name == exchange
+                }
+                if !known {
+                        add("REBALANCE_TARGETS: unknown exchange %s, expected one of %s", exchange, strings.Join(names, ", "))
+                }
+        }
+
+        // Settings components reject at startup
+        if _, err := log.ParseLevel(c.LogLevel); err != nil {
+                add("LOG_LEVEL (logging.level) %q is not one of debug, info, warn, error", c.LogLevel)
+        }
+        if c.LogFormat != "text" && c.LogFormat != "json" {
+                add("LOG_FORMAT (logging.format) %q is not text or json", c.LogFormat)
+        }
+        if c.OpportunityLogFormat != "csv" && c.OpportunityLogFormat != "json" {
+                add("OPPORTUNITIES_LOG_FORMAT (opportunities.logFormat) %q is not csv or json", c.OpportunityLogFormat)
+        }
+        switch c.SlippageModel {
+        case "", "none", "fixed", "linear", "book":
+        default:
+                add("SLIPPAGE_MODEL %q is not one of none, fixed, linear, book", c.SlippageModel)
+        }
+        if c.RebalanceThreshold < 0 || c.RebalanceThreshold > 1 {
+                add("REBALANCE_THRESHOLD is %g; it is a share between 0 and 1", c.RebalanceThreshold)
+        }
+
+        // Amounts and limits cannot be negative, and intervals must be positive
+        amounts := []struct {
+                name  string
+                value float64
+        }{
+                {"SLIPPAGE_BPS", c.SlippageBps},
+                {"SLIPPAGE_IMPACT_BPS", c.SlippageImpactBps},
+                {"TARGET_NOTIONAL", c.TargetNotional},
+                {"EXECUTION_MAX_SLIPPAGE_BPS", c.ExecutionMaxSlippageBps},
+                {"RISK_MAX_TRADE_NOTIONAL", c.RiskMaxTradeNotional},
+                {"RISK_MAX_EXPOSURE", c.RiskMaxExposure},
+                {"RISK_DAILY_LOSS_LIMIT", c.RiskDailyLossLimit},
+                {"RISK_MAX_TRADES_PER_MINUTE", float64(c.RiskMaxTradesPerMinute)},
+                {"REBALANCE_MAX_COST_PERCENTAGE", c.RebalanceMaxCost},
+        }
+        for _, amount := range amounts {
+                if amount.value < 0 {
+                        add("%s is %g; it cannot be negative", amount.name, amount.value)
+                }
+        }
+        if c.PaperLatency < 0 {
+                add("PAPER_LATENCY is %s; it cannot be negative", c.PaperLatency)
+        }
+        intervals := []struct {
+                name  string
+                value time.Duration
+        }{
+                {"EXECUTION_LEG_TIMEOUT", c.ExecutionLegTimeout},
+                {"EXECUTION_POLL_INTERVAL", c.ExecutionPollInterval},
+                {"BALANCE_POLL_INTERVAL", c.BalancePollInterval},
+                {"REBALANCE_INTERVAL", c.RebalanceInterval},
+                {"CONFIG_WATCH_INTERVAL", c.ConfigWatchInterval},
+        }
+        for _, interval := range intervals {
+                if interval.value <= 0 {
+                        add("%s is %s; it must be positive", interval.name, interval.value)
+                }
+        }
+
+        if len(problems) == 0 {
+                return nil
+        }
+        return &ValidationError{Problems: problems}
+}
+
+// namedExchange is an exchange configuration with its display name
+type namedExchange struct {
+        name string
+        cfg  ExchangeConfig
+}
+
+// exchanges returns every supported exchange in a fixed order
+func (c *Config) exchanges() []namedExchange {
+        return []namedExchange{
+                {name: "Binance", cfg: c.Exchanges.Binance},
+                {name: "Kraken", cfg: c.Exchanges.Kraken},
+                {name: "Coinbase", cfg: c.Exchanges.Coinbase},
+        }
+}
+
+// exchangeNames returns the display names of the supported exchanges
+func (c *Config) exchangeNames() []string {
+        names := []string{}
+        for _, exchange := range c.exchanges() {
+                names = append(names, exchange.name)
+        }
+        return names
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+        keys := make([]string, 0, len(m))
+        for key := range m {
+                keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        return keys
+}
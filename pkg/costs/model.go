@@ -0,0 +1,145 @@
+package costs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Model holds the costs of moving assets between exchanges
+// @author VrushankPatel
+// @description Withdrawal fees per exchange and asset, on-chain network fees and deposit confirmation
+// times per asset, loaded from a cost model file
+type Model struct {
+	// Network fee and confirmation time per asset
+	Assets map[string]AssetCosts `yaml:"assets"`
+	// Withdrawal fees and deposit times per exchange name
+	Exchanges map[string]ExchangeCosts `yaml:"exchanges"`
+}
+
+// AssetCosts describes moving one asset on-chain
+type AssetCosts struct {
+	// Fee paid to the network per transfer, in units of the asset
+	NetworkFee float64 `yaml:"networkFee"`
+	// Time until a deposit is credited, used when the receiving exchange sets none
+	ConfirmationTime time.Duration `yaml:"confirmationTime"`
+}
+
+// ExchangeCosts describes the transfer costs charged by one exchange
+type ExchangeCosts struct {
+	// Fee charged per withdrawal, in units of the asset
+	WithdrawalFees map[string]float64 `yaml:"withdrawalFees"`
+	// Time until a deposit of the asset is credited, overriding the asset's confirmation time
+	DepositTimes map[string]time.Duration `yaml:"depositTimes"`
+}
+
+// Transfer is the cost of moving an asset from one exchange to another
+// @author VrushankPatel
+// @description Fees are in units of the transferred asset
+type Transfer struct {
+	Asset         string
+	WithdrawalFee float64
+	NetworkFee    float64
+	Time          time.Duration
+}
+
+// DefaultModel returns the built-in cost model
+// @author VrushankPatel
+// @description Typical withdrawal fees and confirmation times of the supported exchanges, mirrored by costs.yaml
+// @return The default model
+func DefaultModel() *Model {
+	return &Model{
+		Assets: map[string]AssetCosts{
+			"BTC":  {NetworkFee: 0.00002, ConfirmationTime: 30 * time.Minute},
+			"ETH":  {NetworkFee: 0.0003, ConfirmationTime: 5 * time.Minute},
+			"SOL":  {NetworkFee: 0.00001, ConfirmationTime: time.Minute},
+			"USDT": {NetworkFee: 1, ConfirmationTime: 5 * time.Minute},
+			"USDC": {NetworkFee: 1, ConfirmationTime: 5 * time.Minute},
+		},
+		Exchanges: map[string]ExchangeCosts{
+			"Binance": {
+				WithdrawalFees: map[string]float64{"BTC": 0.0002, "ETH": 0.0015, "SOL": 0.008, "USDT": 1, "USDC": 1},
+			},
+			"Kraken": {
+				WithdrawalFees: map[string]float64{"BTC": 0.0002, "ETH": 0.0025, "SOL": 0.01, "USDT": 2.5, "USDC": 2.5},
+				DepositTimes:   map[string]time.Duration{"BTC": 40 * time.Minute, "ETH": 10 * time.Minute},
+			},
+			"Coinbase": {
+				WithdrawalFees: map[string]float64{"BTC": 0, "ETH": 0, "SOL": 0, "USDT": 0, "USDC": 0},
+			},
+		},
+	}
+}
+
+// Load reads a cost model file
+// @author VrushankPatel
+// @description Parses a YAML cost model; the file fully defines the assets and exchanges it lists
+// @param path Path of the cost model file
+// @return The model, or an error if the file cannot be read or is invalid
+func Load(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cost model file: %v", err)
+	}
+
+	model := &Model{}
+	if err := yaml.Unmarshal(data, model); err != nil {
+		return nil, fmt.Errorf("failed to parse cost model file: %v", err)
+	}
+	if err := model.validate(); err != nil {
+		return nil, fmt.Errorf("invalid cost model %s: %v", path, err)
+	}
+	return model, nil
+}
+
+// validate checks that no cost is negative
+func (m *Model) validate() error {
+	for asset, costs := range m.Assets {
+		if costs.NetworkFee < 0 || costs.ConfirmationTime < 0 {
+			return fmt.Errorf("asset %s: costs must not be negative", asset)
+		}
+	}
+	for exchange, costs := range m.Exchanges {
+		for asset, fee := range costs.WithdrawalFees {
+			if fee < 0 {
+				return fmt.Errorf("exchange %s: withdrawal fee for %s must not be negative", exchange, asset)
+			}
+		}
+		for asset, wait := range costs.DepositTimes {
+			if wait < 0 {
+				return fmt.Errorf("exchange %s: deposit time for %s must not be negative", exchange, asset)
+			}
+		}
+	}
+	return nil
+}
+
+// Transfer returns the cost of withdrawing an asset from one exchange and depositing it on another
+// @author VrushankPatel
+// @description Unknown assets and exchanges cost nothing, so a partial model only adds what it knows
+// @param asset The asset moved (e.g., "BTC")
+// @param from The exchange the asset is withdrawn from
+// @param to The exchange the asset is deposited to
+// @return The transfer costs
+func (m *Model) Transfer(asset, from, to string) Transfer {
+	transfer := Transfer{Asset: asset}
+	if m == nil || from == to {
+		return transfer
+	}
+
+	if costs, exists := m.Assets[asset]; exists {
+		transfer.NetworkFee = costs.NetworkFee
+		transfer.Time = costs.ConfirmationTime
+	}
+	if costs, exists := m.Exchanges[from]; exists {
+		transfer.WithdrawalFee = costs.WithdrawalFees[asset]
+	}
+	if costs, exists := m.Exchanges[to]; exists {
+		if wait, exists := costs.DepositTimes[asset]; exists {
+			transfer.Time = wait
+		}
+	}
+	return transfer
+}
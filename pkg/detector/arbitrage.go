@@ -3,103 +3,182 @@ package detector
 import (
 	"context"
 	"fmt"
-	"math"
-	"math/rand"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"apex-arbitrage/pkg/clock"
+	"apex-arbitrage/pkg/costs"
 	"apex-arbitrage/pkg/models"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// OpportunityHandler is a function that processes a detected arbitrage opportunity
+const (
+	// FallbackInterval is how often a full detection pass runs in addition to the passes
+	// triggered by order book updates. It catches books going stale and runs the cycle search.
+	FallbackInterval = 2 * time.Second
+
+	// staleAfter is how old an order book may be and still take part in detection
+	staleAfter = 10 * time.Second
+)
+
+// OpportunityHandler is a function that processes an arbitrage opportunity lifecycle event
 // @author VrushankPatel
-// @description Function type for handling arbitrage opportunities when detected
-type OpportunityHandler func(models.ArbitrageOpportunity)
+// @description Function type for handling arbitrage opportunities when they open, update or close
+type OpportunityHandler func(models.OpportunityEvent)
 
 // APEX handles the detection of arbitrage opportunities
 // @author VrushankPatel
 // @description Core struct that handles the arbitrage detection logic and opportunity management
 type APEX struct {
-	// Map of exchange names to order books
-	orderBooks map[string]*models.OrderBook
-	// Mutex for thread-safe access to order books
-	orderBookMutex *sync.RWMutex
+	// Shared store of order books per exchange and trading pair
+	orderBooks *models.OrderBookStore
 	// Minimum profit threshold (as a decimal, e.g. 0.01 = 1%)
 	minProfitThreshold float64
 	// Map of exchange names to their taker fees
 	exchangeFees map[string]float64
-	// In-memory list of recently detected opportunities
+	// Withdrawal, network and deposit costs of moving assets between exchanges
+	costModel *costs.Model
+	// Slippage model applied to cross-exchange opportunities (nil for top of book)
+	slippage SlippageEstimator
+	// Trade size per pair, and the size in quote currency for pairs without one (0 for none)
+	tradeSizes     map[models.TradingPair]models.TradeSize
+	targetNotional float64
+	// Account balances opportunities are checked against (nil when not tracked)
+	balances BalanceProvider
+	// In-memory list of recently opened opportunities
 	opportunities []models.ArbitrageOpportunity
-	// File handle for logging opportunities to CSV
-	opportunityFile *os.File
+	// Opportunities currently open, keyed by route ID
+	openOpportunities map[string]*models.ArbitrageOpportunity
+	// Last version of each open opportunity sent to the handlers, keyed by route ID
+	published map[string]models.ArbitrageOpportunity
+	// Route IDs observed during the running full detection pass (nil outside full passes)
+	seenInPass map[string]bool
+	// File closed opportunities are logged to (nil for none), and its format
+	opportunityFile   *os.File
+	opportunityFormat string
 	// List of handlers to be called when opportunities are detected
 	opportunityHandlers []OpportunityHandler
+	// Whether the graph-based multi-leg cycle search runs on each detection pass
+	cycleSearch bool
+	// Whether order books come from the simulated market data source
+	simulationMode bool
+	// Source of time for the detection loop, staleness checks and opportunity timestamps
+	clock clock.Clock
+	// Order books updated since the last event-driven pass, and the signal that there are any
+	pending   map[models.OrderBookKey]bool
+	pendingMu sync.Mutex
+	updates   chan struct{}
+	// Settings waiting to be applied by the detection loop, and the signal that there are any
+	nextSettings *Settings
+	settingsMu   sync.Mutex
+	reconfigured chan struct{}
 }
 
 // NewAPEX creates a new APEX instance
 // @author VrushankPatel
 // @description Creates and initializes a new APEX with the provided configurations
-// @param orderBooks Map of exchange names to order books
-// @param mutex Mutex for thread-safe access to the order books
+// @param orderBooks Shared store of order books per exchange and trading pair
 // @param minProfitThreshold Minimum profit threshold as a decimal (e.g., 0.01 for 1%)
-// @param binanceFee The fee rate for Binance exchange as a decimal
-// @param krakenFee The fee rate for Kraken exchange as a decimal
+// @param exchangeFees Map of exchange names to their taker fee rates as decimals
 // @return A pointer to the newly created APEX
 func NewAPEX(
-	orderBooks map[string]*models.OrderBook,
-	mutex *sync.RWMutex,
+	orderBooks *models.OrderBookStore,
 	minProfitThreshold float64,
-	binanceFee float64,
-	krakenFee float64,
+	exchangeFees map[string]float64,
 ) *APEX {
-
-	// Create opportunities log file
-	f, err := os.OpenFile("data/opportunities.csv", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Errorf("Failed to open opportunities log file: %v", err)
-	}
-
-	// Write header if file is new
-	stat, err := f.Stat()
-	if err == nil && stat.Size() == 0 {
-		_, err = f.WriteString("timestamp,buy_exchange,sell_exchange,buy_price,sell_price,profit_percentage,net_profit\n")
-		if err != nil {
-			log.Errorf("Failed to write header to opportunities file: %v", err)
-		}
+	// Copy the fees so later changes by the caller don't race with detection
+	fees := make(map[string]float64, len(exchangeFees))
+	for exchange, fee := range exchangeFees {
+		fees[exchange] = fee
 	}
 
 	return &APEX{
-		orderBooks:         orderBooks,
-		orderBookMutex:     mutex,
-		minProfitThreshold: minProfitThreshold,
-		exchangeFees: map[string]float64{
-			"Binance": binanceFee,
-			"Kraken":  krakenFee,
-		},
+		orderBooks:          orderBooks,
+		minProfitThreshold:  minProfitThreshold,
+		exchangeFees:        fees,
 		opportunities:       make([]models.ArbitrageOpportunity, 0),
-		opportunityFile:     f,
+		openOpportunities:   make(map[string]*models.ArbitrageOpportunity),
+		published:           make(map[string]models.ArbitrageOpportunity),
 		opportunityHandlers: make([]OpportunityHandler, 0),
+		clock:               clock.Real(),
+		pending:             make(map[models.OrderBookKey]bool),
+		updates:             make(chan struct{}, 1),
+		reconfigured:        make(chan struct{}, 1),
+	}
+}
+
+// EnableSimulationMode marks every opportunity detected from now on as simulated, for use
+// when the order books are fed by simulated exchanges rather than live venues
+// @author VrushankPatel
+// @description Flags opportunities as simulated in logs, CSV consumers and web clients
+func (a *APEX) EnableSimulationMode() {
+	a.simulationMode = true
+}
+
+// SetClock replaces the wall clock used by the detection loop, staleness checks and opportunity timestamps
+// @author VrushankPatel
+// @description Lets tests and backtests drive detection on a controlled clock; call before Start
+// @param c The clock to use
+func (a *APEX) SetClock(c clock.Clock) {
+	a.clock = c
+}
+
+// SetOpportunityFile logs every closed opportunity to a file
+// @author VrushankPatel
+// @description Appends to the file, creating it and its directory if needed; replaces any
+// previously set file. Without a file closed opportunities are not logged to disk.
+// @param path Path of the log file (e.g., "data/opportunities.csv")
+// @param format OpportunityFileCSV or OpportunityFileJSON
+// @return An error if the format is unknown or the file cannot be opened
+func (a *APEX) SetOpportunityFile(path, format string) error {
+	if format != OpportunityFileCSV && format != OpportunityFileJSON {
+		return fmt.Errorf("unknown opportunity log format %q, expected %s or %s", format, OpportunityFileCSV, OpportunityFileJSON)
+	}
+	f, err := openOpportunityFile(path, format)
+	if err != nil {
+		return fmt.Errorf("failed to open opportunities log file: %v", err)
+	}
+	a.DisableOpportunityFile()
+	a.opportunityFile = f
+	a.opportunityFormat = format
+	return nil
+}
+
+// DisableOpportunityFile stops writing closed opportunities to the opportunity log
+// @author VrushankPatel
+// @description Keeps offline runs such as backtests out of the live opportunity log
+func (a *APEX) DisableOpportunityFile() {
+	if a.opportunityFile != nil {
+		a.opportunityFile.Close()
+		a.opportunityFile = nil
 	}
 }
 
+// DetectOnce runs a single full detection pass over the current order books
+// @author VrushankPatel
+// @description Same pass the fallback ticker runs, for callers driving their own clock
+func (a *APEX) DetectOnce() {
+	a.detectArbitrageOpportunities()
+}
+
 // Start begins the arbitrage detection loop
 // @author VrushankPatel
-// @description Starts the continuous arbitrage detection process, monitoring markets for opportunities
+// @description Starts the continuous arbitrage detection process. Every order book update triggers a
+// pass over the pairs and exchange it affects as soon as it arrives; a slower full pass runs as a fallback.
 // @param ctx Context used for cancellation and shutdown signals
 func (a *APEX) Start(ctx context.Context) {
-	// Initialize the random number generator for our simulated data
-	// In Go 1.20+ rand.Seed is deprecated but we keep it for compatibility
-	rand.Seed(time.Now().UnixNano())
+	// React to order book updates as the exchanges publish them
+	a.orderBooks.Subscribe(a.queueUpdate)
 
-	// Fast ticker for detecting arbitrage opportunities
-	detectionTicker := time.NewTicker(500 * time.Millisecond)
+	// Fallback ticker for full detection passes
+	detectionTicker := a.clock.NewTicker(FallbackInterval)
 	defer detectionTicker.Stop()
 
 	// Slower ticker for printing market summary information
-	summaryTicker := time.NewTicker(5 * time.Second)
+	summaryTicker := a.clock.NewTicker(5 * time.Second)
 	defer summaryTicker.Stop()
 
 	if a.opportunityFile != nil {
@@ -112,275 +191,277 @@ func (a *APEX) Start(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			log.Info("Shutting down APEX")
+			a.CloseOpenOpportunities()
 			return
-		case <-detectionTicker.C:
+		case <-a.updates:
+			a.detectPendingUpdates()
+		case <-a.reconfigured:
+			a.applySettings()
+		case <-detectionTicker.C():
 			a.detectArbitrageOpportunities()
-		case <-summaryTicker.C:
+		case <-summaryTicker.C():
 			a.printMarketSummary()
 		}
 	}
 }
 
-// detectArbitrageOpportunities checks for arbitrage opportunities between exchanges
+// detectArbitrageOpportunities runs a full pass over every fresh order book: all exchange
+// pairs for every trading pair, triangles within every exchange and, if enabled, the cycle search
 func (a *APEX) detectArbitrageOpportunities() {
-	a.orderBookMutex.RLock()
-	defer a.orderBookMutex.RUnlock()
-
-	// SIMULATION MODE: Always generate simulated data for demonstration
-	// This allows us to show the system working even without real exchange connections
-	if rand.Intn(3) == 0 { // Only run simulation in some cycles to avoid too many logs
-		a.simulateArbitrageData()
+	// Nothing to compare until exchanges have published data
+	if a.orderBooks.Len() == 0 {
+		log.Debug("Waiting for data from exchanges")
 		return
 	}
 
-	// We need at least two exchanges to compare
-	if len(a.orderBooks) < 2 {
-		// If we don't have real data, use simulated data for demonstration
-		a.simulateArbitrageData()
-		return
+	byPair, byExchange := a.freshBooks()
+
+	// Every open opportunity not seen again by the end of the pass has closed
+	a.seenInPass = make(map[string]bool)
+	defer a.closeUnseen()
+
+	comparablePairs := 0
+	for pair, fresh := range byPair {
+		if a.detectPairOpportunities(pair, fresh) {
+			comparablePairs++
+		}
 	}
 
-	// Make sure we have both Binance and Kraken data
-	binanceBook, hasBinance := a.orderBooks["Binance"]
-	krakenBook, hasKraken := a.orderBooks["Kraken"]
+	// Check three-leg cycles within each exchange
+	for exchange, books := range byExchange {
+		a.detectTriangularOpportunities(exchange, books)
+	}
 
-	if !hasBinance || !hasKraken {
-		log.Debug("Missing data from one of the exchanges")
-		return
+	// Search the currency graph across exchanges and assets for longer cycles
+	if a.cycleSearch {
+		a.detectCycleOpportunities(byExchange)
 	}
 
-	// Check for stale data (more than 10 seconds old)
-	now := time.Now()
-	if now.Sub(binanceBook.LastUpdate) > 10*time.Second || now.Sub(krakenBook.LastUpdate) > 10*time.Second {
+	if comparablePairs == 0 && len(byExchange) == 0 {
 		log.Debug("Data is stale, waiting for fresh updates")
-		a.simulateArbitrageData() // Generate simulated data for stale data
-		return
 	}
+}
 
-	// Calculate fees
-	binanceFee := a.exchangeFees["Binance"]
-	krakenFee := a.exchangeFees["Kraken"]
-
-	// Check Binance -> Kraken arbitrage
-	// Buy on Binance, sell on Kraken
-	binanceBuyPrice := binanceBook.Ask * (1 + binanceFee) // Including fee
-	krakenSellPrice := krakenBook.Bid * (1 - krakenFee)   // After fee
-
-	binanceToKrakenProfit := (krakenSellPrice / binanceBuyPrice) - 1
-
-	if binanceToKrakenProfit > a.minProfitThreshold {
-		opportunity := models.ArbitrageOpportunity{
-			Timestamp:        time.Now(),
-			BuyExchange:      "Binance",
-			SellExchange:     "Kraken",
-			BuyPrice:         binanceBook.Ask,
-			SellPrice:        krakenBook.Bid,
-			ProfitPercentage: binanceToKrakenProfit * 100, // Convert to percentage
-			NetProfit:        krakenSellPrice - binanceBuyPrice,
+// freshBooks returns the order books that can take part in detection, grouped by trading
+// pair and by exchange. Only books updated within the last 10 seconds of exchanges with a
+// configured fee qualify.
+func (a *APEX) freshBooks() (map[models.TradingPair][]models.OrderBook, map[string][]models.OrderBook) {
+	now := a.clock.Now()
+	byPair := make(map[models.TradingPair][]models.OrderBook)
+	byExchange := make(map[string][]models.OrderBook)
+	for _, book := range a.orderBooks.Snapshot() {
+		if now.Sub(book.LastUpdate) > staleAfter {
+			log.Debugf("Data from %s for %s is stale, skipping", book.Exchange, book.Pair())
+			continue
 		}
+		if _, hasFee := a.exchangeFees[book.Exchange]; !hasFee {
+			log.Debugf("No fee configured for %s, skipping", book.Exchange)
+			continue
+		}
+		byPair[book.Pair()] = append(byPair[book.Pair()], book)
+		byExchange[book.Exchange] = append(byExchange[book.Exchange], book)
+	}
+	return byPair, byExchange
+}
 
-		a.logOpportunity(opportunity)
+// detectPairOpportunities checks both directions for every pair of exchanges quoting a
+// trading pair, and reports whether there were at least two exchanges to compare
+func (a *APEX) detectPairOpportunities(pair models.TradingPair, fresh []models.OrderBook) bool {
+	if len(fresh) < 2 {
+		log.Debugf("Not enough fresh data for %s, have %d exchanges", pair, len(fresh))
+		return false
 	}
 
-	// Check Kraken -> Binance arbitrage
-	// Buy on Kraken, sell on Binance
-	krakenBuyPrice := krakenBook.Ask * (1 + krakenFee)     // Including fee
-	binanceSellPrice := binanceBook.Bid * (1 - binanceFee) // After fee
-
-	krakenToBinanceProfit := (binanceSellPrice / krakenBuyPrice) - 1
-
-	if krakenToBinanceProfit > a.minProfitThreshold {
-		opportunity := models.ArbitrageOpportunity{
-			Timestamp:        time.Now(),
-			BuyExchange:      "Kraken",
-			SellExchange:     "Binance",
-			BuyPrice:         krakenBook.Ask,
-			SellPrice:        binanceBook.Bid,
-			ProfitPercentage: krakenToBinanceProfit * 100, // Convert to percentage
-			NetProfit:        binanceSellPrice - krakenBuyPrice,
+	for _, buyBook := range fresh {
+		for _, sellBook := range fresh {
+			if buyBook.Exchange == sellBook.Exchange {
+				continue
+			}
+			a.checkOpportunity(buyBook, sellBook)
 		}
-
-		a.logOpportunity(opportunity)
 	}
+	return true
 }
 
-// logOpportunity logs an arbitrage opportunity to console and file
-func (a *APEX) logOpportunity(opp models.ArbitrageOpportunity) {
-	// Check if this is likely a simulated opportunity (if both exchanges updated at exactly the same time)
-	isSimulated := false
-	a.orderBookMutex.RLock()
-	binanceBook, hasBinance := a.orderBooks["Binance"]
-	krakenBook, hasKraken := a.orderBooks["Kraken"]
-	if hasBinance && hasKraken {
-		timeDiff := binanceBook.LastUpdate.Sub(krakenBook.LastUpdate)
-		if timeDiff < 10*time.Millisecond && timeDiff > -10*time.Millisecond {
-			isSimulated = true
-		}
+// checkOpportunity evaluates buying at the ask on buyBook and selling at the bid on sellBook,
+// observing an opportunity when the fee-adjusted profit exceeds the threshold and closing
+// the route's open opportunity otherwise
+func (a *APEX) checkOpportunity(buyBook, sellBook models.OrderBook) {
+	if buyBook.Ask <= 0 || sellBook.Bid <= 0 {
+		return
+	}
+
+	buyFee := a.exchangeFees[buyBook.Exchange]
+	sellFee := a.exchangeFees[sellBook.Exchange]
+
+	buyPrice := buyBook.Ask * (1 + buyFee)    // Including fee
+	sellPrice := sellBook.Bid * (1 - sellFee) // After fee
+
+	profit := (sellPrice / buyPrice) - 1
+
+	if profit <= a.minProfitThreshold {
+		a.closeOpportunity(models.ArbitrageOpportunity{
+			Type:          models.OpportunityCrossExchange,
+			BaseCurrency:  buyBook.BaseCurrency,
+			QuoteCurrency: buyBook.QuoteCurrency,
+			BuyExchange:   buyBook.Exchange,
+			SellExchange:  sellBook.Exchange,
+		}.RouteID())
+		return
 	}
-	a.orderBookMutex.RUnlock()
-
-	// Log to console with a simulated flag if necessary
-	fields := log.Fields{
-		"buy_exchange":      opp.BuyExchange,
-		"sell_exchange":     opp.SellExchange,
-		"buy_price":         opp.BuyPrice,
-		"sell_price":        opp.SellPrice,
-		"profit_percentage": fmt.Sprintf("%.4f%%", opp.ProfitPercentage),
-		"net_profit":        fmt.Sprintf("%.2f USDT", opp.NetProfit),
+
+	opportunity := models.ArbitrageOpportunity{
+		Type:          models.OpportunityCrossExchange,
+		Timestamp:     a.clock.Now(),
+		BaseCurrency:  buyBook.BaseCurrency,
+		QuoteCurrency: buyBook.QuoteCurrency,
+		BuyExchange:   buyBook.Exchange,
+		SellExchange:  sellBook.Exchange,
+		BuyPrice:      buyBook.Ask,
+		SellPrice:     sellBook.Bid,
 	}
 
-	if isSimulated {
-		fields["simulated"] = true
-		log.WithFields(fields).Info("SIMULATED ARBITRAGE OPPORTUNITY DETECTED")
-	} else {
-		log.WithFields(fields).Info("ARBITRAGE OPPORTUNITY DETECTED")
+	// With depth on both sides, size the opportunity against the visible liquidity
+	// and report volume-weighted prices and profit instead of top of book
+	maxQuantity, maxCost, maxProceeds := 0.0, 0.0, 0.0
+	if len(buyBook.Asks) > 0 && len(sellBook.Bids) > 0 {
+		maxQuantity, maxCost, maxProceeds = executableSize(buyBook.Asks, sellBook.Bids, buyFee, sellFee, a.minProfitThreshold)
+		if maxQuantity > 0 {
+			opportunity.MaxQuantity = maxQuantity
+			opportunity.BuyVWAP = maxCost / maxQuantity
+			opportunity.SellVWAP = maxProceeds / maxQuantity
+		}
 	}
 
-	// Log to file
-	if a.opportunityFile != nil {
-		csvLine := fmt.Sprintf("%s,%s,%s,%.4f,%.4f,%.4f,%.4f\n",
-			opp.Timestamp.Format(time.RFC3339),
-			opp.BuyExchange,
-			opp.SellExchange,
-			opp.BuyPrice,
-			opp.SellPrice,
-			opp.ProfitPercentage,
-			opp.NetProfit,
-		)
-
-		if _, err := a.opportunityFile.WriteString(csvLine); err != nil {
-			log.Errorf("Failed to write opportunity to file: %v", err)
+	// Evaluate at the configured trade size, or without one at the depth-sized quantity
+	// (one unit at the top of book when depth is unknown)
+	quantity, cost, proceeds := 1.0, buyBook.Ask, sellBook.Bid
+	if q := a.tradeQuantity(buyBook, sellBook); q > 0 {
+		quantity, cost, proceeds = q, q*buyBook.Ask, q*sellBook.Bid
+	} else if maxQuantity > 0 {
+		quantity, cost, proceeds = maxQuantity, maxCost, maxProceeds
+	}
+
+	// With a slippage model, trade that quantity at its effective prices
+	if a.slippage != nil {
+		buyPrice, buyOk := a.slippage.EffectivePrice(buyBook, "buy", quantity)
+		sellPrice, sellOk := a.slippage.EffectivePrice(sellBook, "sell", quantity)
+		if !buyOk || !sellOk {
+			a.closeOpportunity(opportunity.RouteID())
+			return
 		}
+		opportunity.BuyPrice = buyPrice
+		opportunity.SellPrice = sellPrice
+		cost, proceeds = quantity*buyPrice, quantity*sellPrice
 	}
 
-	// Add to our in-memory list of opportunities
-	a.opportunities = append(a.opportunities, opp)
+	// Charge the taker fees and the cost of moving the asset to the sell exchange
+	breakdown := a.costBreakdown(opportunity, quantity, cost, proceeds, buyFee, sellFee)
+	netProfit := breakdown.GrossProfit - breakdown.TotalCost
+	profit = netProfit / (cost * (1 + buyFee))
+	if profit <= a.minProfitThreshold {
+		a.closeOpportunity(opportunity.RouteID())
+		return
+	}
 
-	// Notify all registered opportunity handlers
-	a.notifyOpportunityHandlers(opp)
+	opportunity.Costs = &breakdown
+	opportunity.ProfitPercentage = profit * 100
+	opportunity.Quantity = quantity
+	opportunity.NetProfit = netProfit
+	if maxQuantity > 0 {
+		maxBreakdown := a.costBreakdown(opportunity, maxQuantity, maxCost, maxProceeds, buyFee, sellFee)
+		opportunity.MaxProfit = maxBreakdown.GrossProfit - maxBreakdown.TotalCost
+	}
+	a.annotateBalances(&opportunity, buyFee)
+
+	a.observeOpportunity(opportunity)
 }
 
 // printMarketSummary prints a summary of the current market state
 func (a *APEX) printMarketSummary() {
-	a.orderBookMutex.RLock()
-	defer a.orderBookMutex.RUnlock()
-
 	// Check if we have any data
-	if len(a.orderBooks) == 0 {
+	if a.orderBooks.Len() == 0 {
 		log.Info("Market Summary: Waiting for data from exchanges...")
 		return
 	}
 
-	// Group orderbooks by pair for multi-pair support
-	pairData := make(map[string]map[string]*models.OrderBook)
-
-	// First, organize by trading pair
-	for _, book := range a.orderBooks {
-		pairKey := book.BaseCurrency + "/" + book.QuoteCurrency
-		if _, exists := pairData[pairKey]; !exists {
-			pairData[pairKey] = make(map[string]*models.OrderBook)
-		}
-		pairData[pairKey][book.Exchange] = book
+	// Only retain the last 100 opportunities to avoid memory issues
+	if len(a.opportunities) > 100 {
+		a.opportunities = a.opportunities[len(a.opportunities)-100:]
 	}
 
 	// For each pair, calculate and display metrics
-	for pair, exchanges := range pairData {
+	for pair, books := range a.orderBooks.SnapshotByPair() {
 		// Check if we have at least two exchanges for this pair
-		if len(exchanges) < 2 {
-			log.Infof("Market Summary for %s: Need at least two exchanges, have %d", pair, len(exchanges))
+		if len(books) < 2 {
+			log.Infof("Market Summary for %s: Need at least two exchanges, have %d", pair, len(books))
 			continue
 		}
 
-		// For this example, we'll focus on Binance and Kraken
-		binanceBook, hasBinance := exchanges["Binance"]
-		krakenBook, hasKraken := exchanges["Kraken"]
+		fields := log.Fields{"pair": pair.String()}
 
-		if !hasBinance || !hasKraken {
-			log.Infof("Market Summary for %s: Missing data from one of the primary exchanges", pair)
-			continue
-		}
+		// Find the cheapest ask and the richest bid across all exchanges
+		bestBuyBook := books[0]
+		bestSellBook := books[0]
+		for _, book := range books {
+			name := strings.ToLower(book.Exchange)
+			fields[name+"_bid"] = fmt.Sprintf("%.2f %s", book.Bid, book.QuoteCurrency)
+			fields[name+"_ask"] = fmt.Sprintf("%.2f %s", book.Ask, book.QuoteCurrency)
 
-		// Calculate the price spread between exchanges
-		binanceAsk := binanceBook.Ask
-		krakenAsk := krakenBook.Ask
-		binanceBid := binanceBook.Bid
-		krakenBid := krakenBook.Bid
-
-		bestBuyPrice := math.Min(binanceAsk, krakenAsk)
-		bestSellPrice := math.Max(binanceBid, krakenBid)
-		bestBuyExchange := "Binance"
-		bestSellExchange := "Kraken"
-
-		if krakenAsk < binanceAsk {
-			bestBuyExchange = "Kraken"
-		}
-		if binanceBid > krakenBid {
-			bestSellExchange = "Binance"
+			if book.Ask < bestBuyBook.Ask {
+				bestBuyBook = book
+			}
+			if book.Bid > bestSellBook.Bid {
+				bestSellBook = book
+			}
 		}
 
 		// Calculate price difference percentage
-		priceSpreadPct := ((bestSellPrice - bestBuyPrice) / bestBuyPrice) * 100
+		bestBuyPrice := bestBuyBook.Ask
+		bestSellPrice := bestSellBook.Bid
+		priceSpreadPct := 0.0
+		if bestBuyPrice > 0 {
+			priceSpreadPct = ((bestSellPrice - bestBuyPrice) / bestBuyPrice) * 100
+		}
 
-		// Calculate total opportunities and total profit
-		totalOpportunities := len(a.opportunities)
+		// Calculate total opportunities, total profit and average profit percentage for this pair
+		pairOpportunities := 0
 		totalProfit := 0.0
+		totalPct := 0.0
+		recentOppStr := "None detected yet"
 		for _, opp := range a.opportunities {
+			if opp.BaseCurrency != pair.BaseCurrency || opp.QuoteCurrency != pair.QuoteCurrency {
+				continue
+			}
+			pairOpportunities++
 			totalProfit += opp.NetProfit
-		}
-
-		// Only retain the last 100 opportunities to avoid memory issues
-		if len(a.opportunities) > 100 {
-			a.opportunities = a.opportunities[len(a.opportunities)-100:]
-		}
-
-		// Get the most recent opportunity if available
-		var recentOppStr string
-		if len(a.opportunities) > 0 {
-			// Get the most recent opportunity
-			recentOpp := a.opportunities[len(a.opportunities)-1]
+			totalPct += opp.ProfitPercentage
 			recentOppStr = fmt.Sprintf("%s→%s: %.2f%%",
-				recentOpp.BuyExchange,
-				recentOpp.SellExchange,
-				recentOpp.ProfitPercentage)
-		} else {
-			recentOppStr = "None detected yet"
+				opp.BuyExchange,
+				opp.SellExchange,
+				opp.ProfitPercentage)
 		}
 
-		// Calculate average profit percentage if we have opportunities
 		var avgProfit float64
-		if len(a.opportunities) > 0 {
-			totalPct := 0.0
-			for _, opp := range a.opportunities {
-				totalPct += opp.ProfitPercentage
-			}
-			avgProfit = totalPct / float64(len(a.opportunities))
+		if pairOpportunities > 0 {
+			avgProfit = totalPct / float64(pairOpportunities)
 		}
 
+		fields["best_buy"] = fmt.Sprintf("%s at %.2f %s", bestBuyBook.Exchange, bestBuyPrice, bestBuyBook.QuoteCurrency)
+		fields["best_sell"] = fmt.Sprintf("%s at %.2f %s", bestSellBook.Exchange, bestSellPrice, bestSellBook.QuoteCurrency)
+		fields["price_spread"] = fmt.Sprintf("%.4f%%", priceSpreadPct)
+		fields["opportunities"] = pairOpportunities
+		fields["total_profit"] = fmt.Sprintf("%.2f %s", totalProfit, bestBuyBook.QuoteCurrency)
+		fields["avg_profit_pct"] = fmt.Sprintf("%.2f%%", avgProfit)
+		fields["recent_opp"] = recentOppStr
+
 		// Print summary
-		log.WithFields(log.Fields{
-			"pair":           pair,
-			"binance_bid":    fmt.Sprintf("%.2f USDT", binanceBid),
-			"binance_ask":    fmt.Sprintf("%.2f USDT", binanceAsk),
-			"kraken_bid":     fmt.Sprintf("%.2f USDT", krakenBid),
-			"kraken_ask":     fmt.Sprintf("%.2f USDT", krakenAsk),
-			"best_buy":       fmt.Sprintf("%s at %.2f USDT", bestBuyExchange, bestBuyPrice),
-			"best_sell":      fmt.Sprintf("%s at %.2f USDT", bestSellExchange, bestSellPrice),
-			"price_spread":   fmt.Sprintf("%.4f%%", priceSpreadPct),
-			"opportunities":  totalOpportunities,
-			"total_profit":   fmt.Sprintf("%.2f USDT", totalProfit),
-			"avg_profit_pct": fmt.Sprintf("%.2f%%", avgProfit),
-			"recent_opp":     recentOppStr,
-		}).Infof("MARKET SUMMARY FOR %s", pair)
+		log.WithFields(fields).Infof("MARKET SUMMARY FOR %s", pair)
 	}
 
 	// Also print a summary of all opportunities
 	totalOpportunities := len(a.opportunities)
 	if totalOpportunities > 0 {
-		// Only retain the last 100 opportunities to avoid memory issues
-		if totalOpportunities > 100 {
-			a.opportunities = a.opportunities[len(a.opportunities)-100:]
-		}
-
 		// Calculate total profit
 		totalProfit := 0.0
 		totalPct := 0.0
@@ -394,7 +475,7 @@ func (a *APEX) printMarketSummary() {
 
 		log.WithFields(log.Fields{
 			"opportunities_detected": totalOpportunities,
-			"total_profit":           fmt.Sprintf("%.2f USDT", totalProfit),
+			"total_profit":           fmt.Sprintf("%.2f", totalProfit),
 			"avg_profit_pct":         fmt.Sprintf("%.2f%%", avgProfit),
 			"recent_opportunity": fmt.Sprintf("%s→%s: %.2f%%",
 				recentOpp.BuyExchange,
@@ -403,159 +484,3 @@ func (a *APEX) printMarketSummary() {
 		}).Info("TOTAL ARBITRAGE SUMMARY")
 	}
 }
-
-// simulateArbitrageData simulates arbitrage data for demonstration purposes
-// when real exchange data is not available
-func (a *APEX) simulateArbitrageData() {
-	// Release the lock since we'll be updating data and need to reacquire it
-	a.orderBookMutex.RUnlock()
-
-	// Generate simulated data with price discrepancies
-	// Base price around current BTC price with some variance
-	baseBTCPrice := 70000.0 + (rand.Float64()*2000.0 - 1000.0)
-
-	a.orderBookMutex.Lock()
-
-	// Create simulated order books based on whether we want to generate opportunities
-	if rand.Intn(2) == 0 {
-		// Generate guaranteed arbitrage opportunity
-		a.createGuaranteedArbitrageOpportunity(baseBTCPrice)
-	} else {
-		// Create normal market data
-		a.createNormalMarketData(baseBTCPrice)
-	}
-
-	a.orderBookMutex.Unlock()
-
-	// Reacquire the lock so we can release it at the end of detectArbitrageOpportunities
-	a.orderBookMutex.RLock()
-}
-
-// createGuaranteedArbitrageOpportunity creates simulated order books that will always trigger an arbitrage opportunity
-func (a *APEX) createGuaranteedArbitrageOpportunity(baseBTCPrice float64) {
-	// Create order books with guaranteed price differences that will trigger arbitrage detection
-	// Set Binance ask significantly lower than Kraken bid to create an obvious opportunity
-	binanceAsk := baseBTCPrice * 0.90 // 10% below base price
-	krakenBid := baseBTCPrice * 1.05  // 5% above base price
-
-	// Make the current timestamp
-	currentTime := time.Now()
-
-	binanceBook := &models.OrderBook{
-		Exchange:   "Binance",
-		Symbol:     "BTCUSDT",
-		Bid:        baseBTCPrice * 0.99, // 1% below base
-		Ask:        binanceAsk,          // Set low for opportunity
-		LastUpdate: currentTime,
-	}
-
-	krakenBook := &models.OrderBook{
-		Exchange:   "Kraken",
-		Symbol:     "XBT/USDT",
-		Bid:        krakenBid,           // Set high for opportunity
-		Ask:        baseBTCPrice * 1.03, // 3% above base
-		LastUpdate: currentTime,
-	}
-
-	// Add to the order books map
-	a.orderBooks["Binance"] = binanceBook
-	a.orderBooks["Kraken"] = krakenBook
-
-	// Manually create and log the opportunity
-	// Calculate fees
-	binanceFee := a.exchangeFees["Binance"]
-	krakenFee := a.exchangeFees["Kraken"]
-
-	// Check Binance -> Kraken arbitrage (guaranteed to be profitable)
-	binanceBuyPrice := binanceAsk * (1 + binanceFee)
-	krakenSellPrice := krakenBid * (1 - krakenFee)
-
-	binanceToKrakenProfit := (krakenSellPrice / binanceBuyPrice) - 1
-
-	// Print debug information to see what's happening with our simulation
-	log.WithFields(log.Fields{
-		"binance_ask":       binanceAsk,
-		"kraken_bid":        krakenBid,
-		"binance_buy_price": binanceBuyPrice,
-		"kraken_sell_price": krakenSellPrice,
-		"profit_pct":        binanceToKrakenProfit * 100,
-		"min_threshold_pct": a.minProfitThreshold * 100,
-		"is_profitable":     binanceToKrakenProfit > a.minProfitThreshold,
-	}).Info("SIMULATION DEBUG: Potential arbitrage opportunity calculated")
-
-	// This should always be above threshold, but check anyway
-	if binanceToKrakenProfit > a.minProfitThreshold {
-		// Create the opportunity with the exact same timestamp as the order books
-		// to ensure it's recognized as simulated data
-		opportunity := models.ArbitrageOpportunity{
-			Timestamp:        currentTime,
-			BuyExchange:      "Binance",
-			SellExchange:     "Kraken",
-			BuyPrice:         binanceAsk,
-			SellPrice:        krakenBid,
-			ProfitPercentage: binanceToKrakenProfit * 100,
-			NetProfit:        krakenSellPrice - binanceBuyPrice,
-		}
-
-		// Force it to be logged as a simulated opportunity
-		log.WithFields(log.Fields{
-			"buy_exchange":      opportunity.BuyExchange,
-			"sell_exchange":     opportunity.SellExchange,
-			"buy_price":         opportunity.BuyPrice,
-			"sell_price":        opportunity.SellPrice,
-			"profit_percentage": fmt.Sprintf("%.4f%%", opportunity.ProfitPercentage),
-			"net_profit":        fmt.Sprintf("%.2f USDT", opportunity.NetProfit),
-			"simulated":         true,
-		}).Info("SIMULATED ARBITRAGE OPPORTUNITY DETECTED")
-
-		// Log to file
-		if a.opportunityFile != nil {
-			csvLine := fmt.Sprintf("%s,%s,%s,%.4f,%.4f,%.4f,%.4f\n",
-				opportunity.Timestamp.Format(time.RFC3339),
-				opportunity.BuyExchange,
-				opportunity.SellExchange,
-				opportunity.BuyPrice,
-				opportunity.SellPrice,
-				opportunity.ProfitPercentage,
-				opportunity.NetProfit,
-			)
-
-			if _, err := a.opportunityFile.WriteString(csvLine); err != nil {
-				log.Errorf("Failed to write opportunity to file: %v", err)
-			}
-		}
-
-		// Add to our in-memory list of opportunities
-		a.opportunities = append(a.opportunities, opportunity)
-
-		// Notify all registered opportunity handlers
-		a.notifyOpportunityHandlers(opportunity)
-	} else {
-		log.Warnf("SIMULATION DEBUG: Expected a profitable opportunity, but profit %.4f%% below threshold %.4f%%",
-			binanceToKrakenProfit*100, a.minProfitThreshold*100)
-	}
-}
-
-// createNormalMarketData creates simulated order books with realistic but non-arbitrage pricing
-func (a *APEX) createNormalMarketData(baseBTCPrice float64) {
-	// Create simulated order books with normal market spreads
-	binanceBook := &models.OrderBook{
-		Exchange:   "Binance",
-		Symbol:     "BTCUSDT",
-		Bid:        baseBTCPrice - (rand.Float64() * 50),
-		Ask:        baseBTCPrice + (rand.Float64() * 50),
-		LastUpdate: time.Now(),
-	}
-
-	krakenBook := &models.OrderBook{
-		Exchange:   "Kraken",
-		Symbol:     "XBT/USDT",
-		Bid:        baseBTCPrice - (rand.Float64() * 60),
-		Ask:        baseBTCPrice + (rand.Float64() * 60),
-		LastUpdate: time.Now(),
-	}
-
-	// Add to the order books map
-	a.orderBooks["Binance"] = binanceBook
-	a.orderBooks["Kraken"] = krakenBook
-}
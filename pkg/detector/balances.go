@@ -0,0 +1,60 @@
+package detector
+
+import (
+	"apex-arbitrage/pkg/models"
+)
+
+// BalanceProvider reports the free balances of the trading accounts
+// @author VrushankPatel
+// @description Implemented by the balance cache fed by the exchange account streams
+type BalanceProvider interface {
+	// Balance returns the free balance of an asset on an exchange, and false when the
+	// exchange's balances are not known
+	Balance(exchange, asset string) (float64, bool)
+}
+
+// SetBalances makes the detector annotate cross-exchange opportunities with the size the
+// account balances can fund
+// @author VrushankPatel
+// @description Each opportunity gets an ExecutableQuantity and a BalanceStatus, limited by the
+// quote balance on the buy exchange and the base balance on the sell exchange. Opportunities
+// are still reported when the balances cannot fund them; they are flagged BalanceInsufficient.
+// @param balances The balance source, nil to stop annotating
+func (a *APEX) SetBalances(balances BalanceProvider) {
+	a.balances = balances
+}
+
+// annotateBalances sets the executable quantity and balance status of a cross-exchange
+// opportunity, leaving them empty when the balances of either exchange are unknown
+func (a *APEX) annotateBalances(opportunity *models.ArbitrageOpportunity, buyFee float64) {
+	if a.balances == nil {
+		return
+	}
+	quote, quoteKnown := a.balances.Balance(opportunity.BuyExchange, opportunity.QuoteCurrency)
+	base, baseKnown := a.balances.Balance(opportunity.SellExchange, opportunity.BaseCurrency)
+	if !quoteKnown || !baseKnown {
+		return
+	}
+
+	// The buy leg spends price plus fee in quote currency, the sell leg delivers the base asset
+	executable := opportunity.Quantity
+	if affordable := quote / (opportunity.BuyPrice * (1 + buyFee)); affordable < executable {
+		executable = affordable
+	}
+	if base < executable {
+		executable = base
+	}
+	if executable < 0 {
+		executable = 0
+	}
+
+	opportunity.ExecutableQuantity = executable
+	switch {
+	case executable >= opportunity.Quantity*(1-1e-9):
+		opportunity.BalanceStatus = models.BalanceSufficient
+	case executable > 0:
+		opportunity.BalanceStatus = models.BalancePartial
+	default:
+		opportunity.BalanceStatus = models.BalanceInsufficient
+	}
+}
@@ -0,0 +1,37 @@
+package detector
+
+import (
+	"apex-arbitrage/pkg/costs"
+	"apex-arbitrage/pkg/models"
+)
+
+// SetCostModel sets the withdrawal, network and deposit costs charged on cross-exchange opportunities
+// @author VrushankPatel
+// @description Without a cost model only taker fees are deducted, and the cycle search does not
+// move assets between exchanges. Multi-leg opportunities are evaluated per unit of their starting
+// currency, so the cycle search charges fixed transfer costs as a share of the target notional.
+// @param model The cost model to use, or nil to charge taker fees only
+func (a *APEX) SetCostModel(model *costs.Model) {
+	a.costModel = model
+}
+
+// costBreakdown itemizes the costs of buying quantity for cost on the buy exchange and selling
+// it for proceeds on the sell exchange, moving the asset between them
+func (a *APEX) costBreakdown(opp models.ArbitrageOpportunity, quantity, cost, proceeds, buyFee, sellFee float64) models.CostBreakdown {
+	transfer := a.costModel.Transfer(opp.BaseCurrency, opp.BuyExchange, opp.SellExchange)
+
+	// Transfer fees are paid in the asset, so value them at the price it is sold for
+	unitValue := proceeds / quantity
+
+	breakdown := models.CostBreakdown{
+		Quantity:            quantity,
+		GrossProfit:         proceeds - cost,
+		TradingFees:         cost*buyFee + proceeds*sellFee,
+		WithdrawalFee:       transfer.WithdrawalFee * unitValue,
+		NetworkFee:          transfer.NetworkFee * unitValue,
+		TransferAsset:       transfer.Asset,
+		TransferTimeSeconds: transfer.Time.Seconds(),
+	}
+	breakdown.TotalCost = breakdown.TradingFees + breakdown.WithdrawalFee + breakdown.NetworkFee
+	return breakdown
+}
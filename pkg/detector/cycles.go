@@ -0,0 +1,361 @@
+package detector
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// maxCycleLegs caps the number of legs (trades and transfers) of a reported cycle
+const maxCycleLegs = 6
+
+// cycleEpsilon guards relaxations against floating point noise on zero-weight transfer edges
+// (transfers the cost model charges nothing for)
+const cycleEpsilon = 1e-12
+
+// currencyNode is a vertex of the currency graph: one currency held on one exchange
+type currencyNode struct {
+	exchange string
+	currency string
+}
+
+// graphEdge is a conversion between two currency nodes weighted by -log(rate)
+type graphEdge struct {
+	from   int
+	to     int
+	weight float64
+	leg    models.TradeLeg
+}
+
+// EnableCycleSearch turns on the graph-based multi-leg cycle search, which runs on every
+// detection pass alongside the pairwise and triangular checks
+// @author VrushankPatel
+// @description Enables negative-cycle detection over the currency graph of all order books
+func (a *APEX) EnableCycleSearch() {
+	a.cycleSearch = true
+}
+
+// detectCycleOpportunities builds a weighted currency graph from every fresh order book,
+// linking the same currency across exchanges with transfer edges, and reports the negative
+// cycles found by Bellman-Ford as multi-leg opportunities
+// @author VrushankPatel
+// @description Finds compounded mispricings spanning several exchanges and assets
+// @param byExchange Fresh order books grouped by exchange
+func (a *APEX) detectCycleOpportunities(byExchange map[string][]models.OrderBook) {
+	nodeIndex := make(map[currencyNode]int)
+	nodeOf := func(exchange, currency string) int {
+		node := currencyNode{exchange: exchange, currency: currency}
+		if idx, exists := nodeIndex[node]; exists {
+			return idx
+		}
+		nodeIndex[node] = len(nodeIndex)
+		return nodeIndex[node]
+	}
+
+	exchanges := make([]string, 0, len(byExchange))
+	for exchange := range byExchange {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+
+	// Market edges within each exchange
+	edges := make([]graphEdge, 0)
+	holders := make(map[string][]string) // Currency -> exchanges it can be held on
+	for _, exchange := range exchanges {
+		legs := conversionLegs(exchange, byExchange[exchange], a.exchangeFees[exchange])
+		for _, from := range sortedKeys(legs) {
+			holders[from] = append(holders[from], exchange)
+			for _, to := range sortedKeys(legs[from]) {
+				leg := legs[from][to]
+				edges = append(edges, graphEdge{
+					from:   nodeOf(exchange, from),
+					to:     nodeOf(exchange, to),
+					weight: -math.Log(leg.Rate),
+					leg:    leg,
+				})
+			}
+		}
+	}
+
+	// Transfer edges moving a currency between exchanges, only with a cost model to charge them
+	if a.costModel != nil {
+		amounts := a.transferAmounts(byExchange)
+		for _, currency := range sortedKeys(holders) {
+			for _, source := range holders[currency] {
+				for _, destination := range holders[currency] {
+					if source == destination {
+						continue
+					}
+					rate, ok := a.transferRate(currency, source, destination, amounts[currency])
+					if !ok {
+						continue
+					}
+					edges = append(edges, graphEdge{
+						from:   nodeOf(source, currency),
+						to:     nodeOf(destination, currency),
+						weight: -math.Log(rate),
+						leg: models.TradeLeg{
+							Exchange:   source,
+							ToExchange: destination,
+							Side:       "transfer",
+							From:       currency,
+							To:         currency,
+							Rate:       rate,
+						},
+					})
+				}
+			}
+		}
+	}
+
+	for _, cycle := range findNegativeCycles(len(nodeIndex), edges) {
+		legs := make([]models.TradeLeg, 0, len(cycle))
+		for _, edge := range cycle {
+			legs = append(legs, edge.leg)
+		}
+		if len(legs) > maxCycleLegs || coveredByPairwiseChecks(legs) {
+			continue
+		}
+		a.checkCycle(rotateCycleToHomeCurrency(legs))
+	}
+}
+
+// checkCycle compounds the rates of a cycle and observes an opportunity when the result
+// exceeds the profit threshold, closing it otherwise
+func (a *APEX) checkCycle(legs []models.TradeLeg) {
+	rate := 1.0
+	for _, leg := range legs {
+		rate *= leg.Rate
+	}
+
+	profit := rate - 1
+	if profit <= a.minProfitThreshold {
+		a.closeOpportunity(models.ArbitrageOpportunity{Type: models.OpportunityCycle, Legs: legs}.RouteID())
+		return
+	}
+
+	// Report the first and last trades as the buy and sell sides of the cycle
+	var firstTrade, lastTrade models.TradeLeg
+	for _, leg := range legs {
+		if leg.Side == "transfer" {
+			continue
+		}
+		if firstTrade.Side == "" {
+			firstTrade = leg
+		}
+		lastTrade = leg
+	}
+
+	opportunity := models.ArbitrageOpportunity{
+		Type:             models.OpportunityCycle,
+		Timestamp:        a.clock.Now(),
+		QuoteCurrency:    legs[0].From,
+		BuyExchange:      firstTrade.Exchange,
+		SellExchange:     lastTrade.Exchange,
+		BuyPrice:         firstTrade.Price,
+		SellPrice:        lastTrade.Price,
+		ProfitPercentage: profit * 100, // Convert to percentage
+		NetProfit:        profit,       // Per unit of the starting currency
+		Legs:             legs,
+	}
+
+	a.observeOpportunity(opportunity)
+}
+
+// transferRate returns the share of an amount of a currency that arrives when it is moved
+// between exchanges, after the withdrawal and network fees of the cost model. Transfers with
+// fees cannot be priced without an amount and are reported as not possible, as are those whose
+// fees exceed the amount.
+func (a *APEX) transferRate(currency, source, destination string, amount float64) (float64, bool) {
+	transfer := a.costModel.Transfer(currency, source, destination)
+	fee := transfer.WithdrawalFee + transfer.NetworkFee
+	if fee <= 0 {
+		return 1, true
+	}
+	if amount <= fee {
+		return 0, false
+	}
+	return 1 - fee/amount, true
+}
+
+// transferAmounts values the reference trade size (the target notional) in every currency of
+// the books, so fixed transfer fees can be charged as a share of it. The notional is taken to be
+// in the currencies that are only ever quoted (e.g. USDT); other currencies are converted at their
+// average mid price against those. Currencies without such a market get no amount.
+func (a *APEX) transferAmounts(byExchange map[string][]models.OrderBook) map[string]float64 {
+	amounts := make(map[string]float64)
+	if a.targetNotional <= 0 {
+		return amounts
+	}
+
+	bases := make(map[string]bool)
+	for _, books := range byExchange {
+		for _, book := range books {
+			bases[book.BaseCurrency] = true
+		}
+	}
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, books := range byExchange {
+		for _, book := range books {
+			if bases[book.QuoteCurrency] {
+				continue
+			}
+			amounts[book.QuoteCurrency] = a.targetNotional
+			if book.Bid > 0 && book.Ask > 0 {
+				sums[book.BaseCurrency] += (book.Bid + book.Ask) / 2
+				counts[book.BaseCurrency]++
+			}
+		}
+	}
+	for currency, sum := range sums {
+		amounts[currency] = a.targetNotional / (sum / float64(counts[currency]))
+	}
+	return amounts
+}
+
+// findNegativeCycles runs Bellman-Ford from a virtual source connected to every node and
+// extracts the distinct negative-weight cycles reachable from nodes still relaxing after
+// n passes
+func findNegativeCycles(n int, edges []graphEdge) [][]graphEdge {
+	if n == 0 {
+		return nil
+	}
+
+	dist := make([]float64, n) // Virtual source reaches every node at distance 0
+	pred := make([]int, n)
+	for i := range pred {
+		pred[i] = -1
+	}
+
+	var relaxed []int
+	for pass := 0; pass < n; pass++ {
+		relaxed = relaxed[:0]
+		for i, edge := range edges {
+			if dist[edge.from]+edge.weight < dist[edge.to]-cycleEpsilon {
+				dist[edge.to] = dist[edge.from] + edge.weight
+				pred[edge.to] = i
+				relaxed = append(relaxed, edge.to)
+			}
+		}
+		if len(relaxed) == 0 {
+			return nil
+		}
+	}
+
+	cycles := make([][]graphEdge, 0)
+	seen := make(map[string]bool)
+	for _, start := range relaxed {
+		// Walking back n predecessors is guaranteed to land inside a cycle
+		node := start
+		for i := 0; i < n && node >= 0; i++ {
+			if pred[node] < 0 {
+				node = -1
+				break
+			}
+			node = edges[pred[node]].from
+		}
+		if node < 0 {
+			continue
+		}
+
+		// Collect the cycle by following predecessors until we return to node
+		cycle := make([]graphEdge, 0)
+		indices := make([]int, 0)
+		current := node
+		for len(cycle) <= n {
+			edgeIdx := pred[current]
+			cycle = append([]graphEdge{edges[edgeIdx]}, cycle...)
+			indices = append(indices, edgeIdx)
+			current = edges[edgeIdx].from
+			if current == node {
+				break
+			}
+		}
+		if current != node {
+			continue
+		}
+
+		sort.Ints(indices)
+		key := make([]string, 0, len(indices))
+		for _, idx := range indices {
+			key = append(key, strconv.Itoa(idx))
+		}
+		if seen[strings.Join(key, ",")] {
+			continue
+		}
+		seen[strings.Join(key, ",")] = true
+		cycles = append(cycles, cycle)
+	}
+
+	return cycles
+}
+
+// coveredByPairwiseChecks reports whether a cycle is just a cross-exchange pair or a
+// single-exchange triangle, which the dedicated checks already report
+func coveredByPairwiseChecks(legs []models.TradeLeg) bool {
+	trades := 0
+	transfers := 0
+	exchanges := make(map[string]bool)
+	for _, leg := range legs {
+		if leg.Side == "transfer" {
+			transfers++
+			continue
+		}
+		trades++
+		exchanges[leg.Exchange] = true
+	}
+
+	if trades == 2 && len(exchanges) == 2 {
+		return true
+	}
+	return trades == 3 && transfers == 0 && len(exchanges) == 1
+}
+
+// rotateCycleToHomeCurrency rotates a cycle so it starts with a trade spending the currency
+// used as quote by the most of its markets, preferring exchanges by name for stable output
+func rotateCycleToHomeCurrency(legs []models.TradeLeg) []models.TradeLeg {
+	quoteCount := make(map[string]int)
+	for _, leg := range legs {
+		if leg.Side != "transfer" {
+			quoteCount[leg.QuoteCurrency]++
+		}
+	}
+
+	best := -1
+	for i, leg := range legs {
+		if leg.Side == "transfer" {
+			continue
+		}
+		if best < 0 {
+			best = i
+			continue
+		}
+		current := legs[best]
+		if quoteCount[leg.From] > quoteCount[current.From] ||
+			(quoteCount[leg.From] == quoteCount[current.From] &&
+				(leg.From < current.From || (leg.From == current.From && leg.Exchange < current.Exchange))) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return legs
+	}
+
+	rotated := make([]models.TradeLeg, 0, len(legs))
+	rotated = append(rotated, legs[best:]...)
+	return append(rotated, legs[:best]...)
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
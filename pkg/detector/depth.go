@@ -0,0 +1,65 @@
+package detector
+
+import (
+	"math"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// executableSize walks the asks of the buy exchange and the bids of the sell exchange
+// together, matching quantity for as long as the fee-adjusted profit of the next unit
+// stays above minProfit
+// @author VrushankPatel
+// @description Estimates how much of an opportunity can actually be executed against the visible depth
+// @param asks Ask levels of the buy exchange, best first
+// @param bids Bid levels of the sell exchange, best first
+// @param buyFee Taker fee rate of the buy exchange as a decimal
+// @param sellFee Taker fee rate of the sell exchange as a decimal
+// @param minProfit Minimum marginal profit as a decimal (e.g., 0.001 for 0.1%)
+// @return The matched base quantity, the quote cost before fees and the quote proceeds before fees
+func executableSize(asks, bids []models.PriceLevel, buyFee, sellFee, minProfit float64) (float64, float64, float64) {
+	var quantity, cost, proceeds float64
+
+	askIdx, bidIdx := 0, 0
+	askLeft, bidLeft := 0.0, 0.0
+	if len(asks) > 0 {
+		askLeft = asks[0].Quantity
+	}
+	if len(bids) > 0 {
+		bidLeft = bids[0].Quantity
+	}
+
+	for askIdx < len(asks) && bidIdx < len(bids) {
+		ask := asks[askIdx].Price
+		bid := bids[bidIdx].Price
+
+		// Stop once the next unit no longer clears the threshold after fees
+		marginalProfit := (bid*(1-sellFee))/(ask*(1+buyFee)) - 1
+		if marginalProfit <= minProfit {
+			break
+		}
+
+		// Match the smaller of the two remaining level quantities
+		matched := math.Min(askLeft, bidLeft)
+		quantity += matched
+		cost += matched * ask
+		proceeds += matched * bid
+
+		askLeft -= matched
+		bidLeft -= matched
+		if askLeft <= 0 {
+			askIdx++
+			if askIdx < len(asks) {
+				askLeft = asks[askIdx].Quantity
+			}
+		}
+		if bidLeft <= 0 {
+			bidIdx++
+			if bidIdx < len(bids) {
+				bidLeft = bids[bidIdx].Quantity
+			}
+		}
+	}
+
+	return quantity, cost, proceeds
+}
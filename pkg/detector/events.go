@@ -0,0 +1,67 @@
+package detector
+
+import (
+	"apex-arbitrage/pkg/models"
+)
+
+// queueUpdate records that an order book changed and wakes the detection loop. It runs on the
+// publishing exchange's goroutine, so it only marks the book as pending and never blocks;
+// updates arriving while a pass runs are coalesced into the next one.
+func (a *APEX) queueUpdate(book models.OrderBook) {
+	a.pendingMu.Lock()
+	a.pending[book.Key()] = true
+	a.pendingMu.Unlock()
+
+	select {
+	case a.updates <- struct{}{}:
+	default:
+	}
+}
+
+// detectPendingUpdates runs an event-driven pass over the order books updated since the last one
+func (a *APEX) detectPendingUpdates() {
+	a.pendingMu.Lock()
+	pending := a.pending
+	a.pending = make(map[models.OrderBookKey]bool)
+	a.pendingMu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	keys := make([]models.OrderBookKey, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	a.detectAffected(keys)
+}
+
+// DetectUpdate immediately re-evaluates the opportunities affected by one order book update
+// @author VrushankPatel
+// @description Same pass the detection loop runs when an update arrives, for callers driving their
+// own clock such as backtests
+// @param book The order book that was just stored
+func (a *APEX) DetectUpdate(book models.OrderBook) {
+	a.detectAffected([]models.OrderBookKey{book.Key()})
+}
+
+// detectAffected checks only what the updated books can change: the cross-exchange
+// comparisons of their trading pairs and the triangles on their exchanges. The cycle search
+// spans every book, so it is left to the full fallback pass.
+func (a *APEX) detectAffected(keys []models.OrderBookKey) {
+	byPair, byExchange := a.freshBooks()
+
+	pairs := make(map[models.TradingPair]bool)
+	exchanges := make(map[string]bool)
+	for _, key := range keys {
+		pair := models.TradingPair{BaseCurrency: key.BaseCurrency, QuoteCurrency: key.QuoteCurrency}
+		if !pairs[pair] {
+			pairs[pair] = true
+			a.detectPairOpportunities(pair, byPair[pair])
+		}
+		if !exchanges[key.Exchange] {
+			exchanges[key.Exchange] = true
+			a.detectTriangularOpportunities(key.Exchange, byExchange[key.Exchange])
+		}
+	}
+}
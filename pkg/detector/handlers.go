@@ -5,14 +5,14 @@ import (
 )
 
 // RegisterOpportunityHandler registers a handler function that will be called
-// whenever a new arbitrage opportunity is detected
+// whenever an arbitrage opportunity opens, updates or closes
 func (a *APEX) RegisterOpportunityHandler(handler OpportunityHandler) {
 	a.opportunityHandlers = append(a.opportunityHandlers, handler)
 }
 
 // notifyOpportunityHandlers calls all registered opportunity handlers
-func (a *APEX) notifyOpportunityHandlers(opp models.ArbitrageOpportunity) {
+func (a *APEX) notifyOpportunityHandlers(event models.OpportunityEvent) {
 	for _, handler := range a.opportunityHandlers {
-		handler(opp)
+		handler(event)
 	}
 }
@@ -0,0 +1,292 @@
+package detector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"apex-arbitrage/pkg/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Formats of the closed opportunity log
+const (
+	OpportunityFileCSV  = "csv"  // One CSV row per opportunity under opportunityFileHeader
+	OpportunityFileJSON = "json" // One JSON object per line, with every field of the opportunity
+)
+
+const (
+	// updateInterval is the least time between two updated events of the same opportunity
+	updateInterval = time.Second
+
+	// updateTolerance is the relative change in price, quantity or profit below which a
+	// re-detection is not published as an update
+	updateTolerance = 1e-9
+)
+
+// opportunityFileHeader is the header of the opportunities CSV, one row per closed opportunity
+const opportunityFileHeader = "id,type,opened_at,closed_at,lifetime_seconds,observations,buy_exchange,sell_exchange,buy_price,sell_price,profit_percentage,peak_profit_percentage,quantity,net_profit"
+
+// openOpportunityFile opens the opportunity log for appending and, for CSV, writes the header
+// if the file is new. A CSV file written in an older format is moved aside first, so rows of
+// different layouts never end up in the same file.
+func openOpportunityFile(path, format string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	if format == OpportunityFileCSV {
+		if header, err := readFirstLine(path); err == nil && header != "" && header != opportunityFileHeader {
+			ext := filepath.Ext(path)
+			legacy := strings.TrimSuffix(path, ext) + "-" + time.Now().Format("20060102-150405") + ext
+			if err := os.Rename(path, legacy); err != nil {
+				log.Errorf("Failed to move aside opportunities file in the old format: %v", err)
+			} else {
+				log.Warnf("Opportunities file was in an older format, moved it to %s", legacy)
+			}
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write header if file is new
+	stat, err := f.Stat()
+	if format == OpportunityFileCSV && err == nil && stat.Size() == 0 {
+		if _, err := f.WriteString(opportunityFileHeader + "\n"); err != nil {
+			log.Errorf("Failed to write header to opportunities file: %v", err)
+		}
+	}
+	return f, nil
+}
+
+// readFirstLine returns the first line of a file
+func readFirstLine(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	return strings.TrimSpace(scanner.Text()), scanner.Err()
+}
+
+// observeOpportunity records a detection of an opportunity. The first detection of a route
+// opens an opportunity; later ones update it while it stays open. Updates are published to
+// the handlers only when the price, quantity or profit changed, and at most once per
+// updateInterval per route, so an opportunity re-detected on every book update does not
+// flood them.
+func (a *APEX) observeOpportunity(opp models.ArbitrageOpportunity) {
+	// Opportunities found on simulated market data are flagged as such
+	opp.Simulated = a.simulationMode
+	opp.ID = opp.RouteID()
+	opp.Status = models.OpportunityStatusOpen
+
+	if a.seenInPass != nil {
+		a.seenInPass[opp.ID] = true
+	}
+
+	open, exists := a.openOpportunities[opp.ID]
+	if !exists {
+		opp.OpenedAt = opp.Timestamp
+		opp.Observations = 1
+		opp.PeakProfitPercentage = opp.ProfitPercentage
+		a.openOpportunities[opp.ID] = &opp
+		a.published[opp.ID] = opp
+
+		a.logOpportunity(opp, models.OpportunityEventOpened)
+
+		// Add to our in-memory list of opportunities
+		a.opportunities = append(a.opportunities, opp)
+		a.notifyOpportunityHandlers(models.OpportunityEvent{Type: models.OpportunityEventOpened, Opportunity: opp})
+		return
+	}
+
+	opp.OpenedAt = open.OpenedAt
+	opp.Observations = open.Observations + 1
+	opp.PeakProfitPercentage = open.PeakProfitPercentage
+	if opp.ProfitPercentage > opp.PeakProfitPercentage {
+		opp.PeakProfitPercentage = opp.ProfitPercentage
+	}
+	opp.LifetimeSeconds = opp.Timestamp.Sub(opp.OpenedAt).Seconds()
+	*open = opp
+
+	last := a.published[opp.ID]
+	if !changedMaterially(last, opp) || opp.Timestamp.Sub(last.Timestamp) < updateInterval {
+		return
+	}
+	a.published[opp.ID] = opp
+
+	a.logOpportunity(opp, models.OpportunityEventUpdated)
+	a.notifyOpportunityHandlers(models.OpportunityEvent{Type: models.OpportunityEventUpdated, Opportunity: opp})
+}
+
+// closeOpportunity closes the open opportunity of a route, if there is one
+func (a *APEX) closeOpportunity(id string) {
+	open, exists := a.openOpportunities[id]
+	if !exists {
+		return
+	}
+	delete(a.openOpportunities, id)
+	delete(a.published, id)
+
+	opp := *open
+	closedAt := a.clock.Now()
+	opp.Status = models.OpportunityStatusClosed
+	opp.ClosedAt = &closedAt
+	opp.LifetimeSeconds = closedAt.Sub(opp.OpenedAt).Seconds()
+
+	a.logOpportunity(opp, models.OpportunityEventClosed)
+	a.writeOpportunityRow(opp)
+	a.notifyOpportunityHandlers(models.OpportunityEvent{Type: models.OpportunityEventClosed, Opportunity: opp})
+}
+
+// changedMaterially reports whether the prices, quantity or profit of an opportunity moved
+// beyond updateTolerance since an earlier version of it
+func changedMaterially(before, after models.ArbitrageOpportunity) bool {
+	for _, values := range [][2]float64{
+		{before.BuyPrice, after.BuyPrice},
+		{before.SellPrice, after.SellPrice},
+		{before.Quantity, after.Quantity},
+		{before.ProfitPercentage, after.ProfitPercentage},
+		{before.NetProfit, after.NetProfit},
+	} {
+		if math.Abs(values[1]-values[0]) > updateTolerance*math.Max(math.Abs(values[0]), math.Abs(values[1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// closeUnseen ends a full detection pass, closing every open opportunity the pass did not see
+// again, e.g. because its order books went stale
+func (a *APEX) closeUnseen() {
+	for _, id := range sortedKeys(a.openOpportunities) {
+		if !a.seenInPass[id] {
+			a.closeOpportunity(id)
+		}
+	}
+	a.seenInPass = nil
+}
+
+// CloseOpenOpportunities closes every open opportunity
+// @author VrushankPatel
+// @description Called on shutdown, and at the end of a backtest, so every opportunity gets its closed event and CSV row
+func (a *APEX) CloseOpenOpportunities() {
+	for _, id := range sortedKeys(a.openOpportunities) {
+		a.closeOpportunity(id)
+	}
+}
+
+// logOpportunity logs an opportunity lifecycle event to the console. Opened and closed
+// opportunities are logged at info level, updates only at debug level.
+func (a *APEX) logOpportunity(opp models.ArbitrageOpportunity, event string) {
+	pair := models.TradingPair{BaseCurrency: opp.BaseCurrency, QuoteCurrency: opp.QuoteCurrency}
+
+	fields := log.Fields{
+		"id":                opp.ID,
+		"pair":              pair.String(),
+		"buy_exchange":      opp.BuyExchange,
+		"sell_exchange":     opp.SellExchange,
+		"buy_price":         opp.BuyPrice,
+		"sell_price":        opp.SellPrice,
+		"profit_percentage": fmt.Sprintf("%.4f%%", opp.ProfitPercentage),
+		"net_profit":        fmt.Sprintf("%.2f %s", opp.NetProfit, opp.QuoteCurrency),
+		"quantity":          fmt.Sprintf("%.8f %s", opp.Quantity, opp.BaseCurrency),
+	}
+
+	if len(opp.Legs) > 0 {
+		fields["type"] = opp.Type
+		fields["legs"] = formatLegs(opp.Legs)
+		fields["net_profit"] = fmt.Sprintf("%.6f %s per %s", opp.NetProfit, opp.QuoteCurrency, opp.QuoteCurrency)
+		delete(fields, "pair")
+		delete(fields, "quantity")
+	}
+
+	if opp.MaxQuantity > 0 {
+		fields["max_quantity"] = fmt.Sprintf("%.8f %s", opp.MaxQuantity, opp.BaseCurrency)
+		fields["max_profit"] = fmt.Sprintf("%.2f %s", opp.MaxProfit, opp.QuoteCurrency)
+	}
+
+	if opp.BalanceStatus != "" {
+		fields["balance_status"] = opp.BalanceStatus
+		fields["executable_quantity"] = fmt.Sprintf("%.8f %s", opp.ExecutableQuantity, opp.BaseCurrency)
+	}
+
+	if opp.Costs != nil {
+		fields["costs"] = fmt.Sprintf("trading %.2f, withdrawal %.2f, network %.2f %s",
+			opp.Costs.TradingFees, opp.Costs.WithdrawalFee, opp.Costs.NetworkFee, opp.QuoteCurrency)
+		fields["transfer_time"] = fmt.Sprintf("%.0fs", opp.Costs.TransferTimeSeconds)
+	}
+
+	if event != models.OpportunityEventOpened {
+		fields["lifetime"] = fmt.Sprintf("%.3fs", opp.LifetimeSeconds)
+		fields["peak_profit_percentage"] = fmt.Sprintf("%.4f%%", opp.PeakProfitPercentage)
+		fields["observations"] = opp.Observations
+	}
+
+	prefix := ""
+	if opp.Simulated {
+		fields["simulated"] = true
+		prefix = "SIMULATED "
+	}
+
+	entry := log.WithFields(fields)
+	switch event {
+	case models.OpportunityEventOpened:
+		entry.Info(prefix + "ARBITRAGE OPPORTUNITY OPENED")
+	case models.OpportunityEventUpdated:
+		entry.Debug(prefix + "ARBITRAGE OPPORTUNITY UPDATED")
+	case models.OpportunityEventClosed:
+		entry.Info(prefix + "ARBITRAGE OPPORTUNITY CLOSED")
+	}
+}
+
+// writeOpportunityRow appends a closed opportunity to the opportunity log
+func (a *APEX) writeOpportunityRow(opp models.ArbitrageOpportunity) {
+	if a.opportunityFile == nil {
+		return
+	}
+
+	if a.opportunityFormat == OpportunityFileJSON {
+		line, err := json.Marshal(opp)
+		if err != nil {
+			log.Errorf("Failed to encode opportunity: %v", err)
+			return
+		}
+		if _, err := a.opportunityFile.Write(append(line, '\n')); err != nil {
+			log.Errorf("Failed to write opportunity to file: %v", err)
+		}
+		return
+	}
+
+	csvLine := fmt.Sprintf("%s,%s,%s,%s,%.3f,%d,%s,%s,%.4f,%.4f,%.4f,%.4f,%.8f,%.4f\n",
+		opp.ID,
+		opp.Type,
+		opp.OpenedAt.Format(time.RFC3339Nano),
+		opp.ClosedAt.Format(time.RFC3339Nano),
+		opp.LifetimeSeconds,
+		opp.Observations,
+		opp.BuyExchange,
+		opp.SellExchange,
+		opp.BuyPrice,
+		opp.SellPrice,
+		opp.ProfitPercentage,
+		opp.PeakProfitPercentage,
+		opp.Quantity,
+		opp.NetProfit,
+	)
+
+	if _, err := a.opportunityFile.WriteString(csvLine); err != nil {
+		log.Errorf("Failed to write opportunity to file: %v", err)
+	}
+}
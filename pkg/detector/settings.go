@@ -0,0 +1,68 @@
+package detector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Settings are the detection parameters that can change while the detector runs
+// @author VrushankPatel
+// @description Applied by the detection loop between passes, so a pass never mixes old and new values
+type Settings struct {
+	// Minimum profit threshold (as a decimal, e.g. 0.01 = 1%)
+	MinProfitThreshold float64
+	// Map of exchange names to their taker fees; exchanges left out are no longer evaluated
+	ExchangeFees map[string]float64
+}
+
+// UpdateSettings replaces the profit threshold and taker fees of a running detector
+// @author VrushankPatel
+// @description Safe to call from any goroutine. The detection loop applies the settings before
+// its next pass and then re-evaluates every order book, so opportunities that no longer clear
+// the threshold close right away. Only the latest settings are applied if several arrive at once.
+// @param settings The new threshold and fees
+func (a *APEX) UpdateSettings(settings Settings) {
+	fees := make(map[string]float64, len(settings.ExchangeFees))
+	for exchange, fee := range settings.ExchangeFees {
+		fees[exchange] = fee
+	}
+
+	a.settingsMu.Lock()
+	a.nextSettings = &Settings{MinProfitThreshold: settings.MinProfitThreshold, ExchangeFees: fees}
+	a.settingsMu.Unlock()
+
+	select {
+	case a.reconfigured <- struct{}{}:
+	default:
+	}
+}
+
+// applySettings switches to the settings passed to UpdateSettings, if any, and runs a full pass with them
+func (a *APEX) applySettings() {
+	a.settingsMu.Lock()
+	next := a.nextSettings
+	a.nextSettings = nil
+	a.settingsMu.Unlock()
+
+	if next == nil {
+		return
+	}
+	a.minProfitThreshold = next.MinProfitThreshold
+	a.exchangeFees = next.ExchangeFees
+
+	exchanges := make([]string, 0, len(a.exchangeFees))
+	for exchange := range a.exchangeFees {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+	fees := make([]string, 0, len(exchanges))
+	for _, exchange := range exchanges {
+		fees = append(fees, fmt.Sprintf("%s %.4f%%", exchange, a.exchangeFees[exchange]*100))
+	}
+	log.Infof("Detection settings updated: min profit threshold %.4f%%, taker fees %s", a.minProfitThreshold*100, strings.Join(fees, ", "))
+
+	a.detectArbitrageOpportunities()
+}
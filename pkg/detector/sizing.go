@@ -0,0 +1,44 @@
+package detector
+
+import (
+	"apex-arbitrage/pkg/models"
+)
+
+// SetTradeSizes sets the size cross-exchange opportunities are evaluated and reported at
+// @author VrushankPatel
+// @description NetProfit and the costs breakdown are computed for the trade size, capped by the
+// quantities at the top of both books when they are known. Without any trade size the opportunity
+// is evaluated at its depth-sized MaxQuantity, or for one unit when depth is unknown.
+// @param targetNotional Trade size in quote currency for pairs without their own size (0 for none)
+// @param sizes Trade size per pair, in its base or quote currency
+func (a *APEX) SetTradeSizes(targetNotional float64, sizes map[models.TradingPair]models.TradeSize) {
+	a.targetNotional = targetNotional
+	a.tradeSizes = make(map[models.TradingPair]models.TradeSize, len(sizes))
+	for pair, size := range sizes {
+		a.tradeSizes[pair] = size
+	}
+}
+
+// tradeQuantity returns the base quantity to trade buying on buyBook and selling on sellBook,
+// or 0 if no trade size is configured for the pair
+func (a *APEX) tradeQuantity(buyBook, sellBook models.OrderBook) float64 {
+	pair := buyBook.Pair()
+	size, exists := a.tradeSizes[pair]
+	if !exists {
+		if a.targetNotional <= 0 {
+			return 0
+		}
+		size = models.TradeSize{Amount: a.targetNotional, Currency: pair.QuoteCurrency}
+	}
+
+	quantity := size.BaseQuantity(pair, buyBook.Ask)
+
+	// Never trade more than is offered at the best ask and bid
+	if len(buyBook.Asks) > 0 && buyBook.Asks[0].Quantity < quantity {
+		quantity = buyBook.Asks[0].Quantity
+	}
+	if len(sellBook.Bids) > 0 && sellBook.Bids[0].Quantity < quantity {
+		quantity = sellBook.Bids[0].Quantity
+	}
+	return quantity
+}
@@ -0,0 +1,165 @@
+package detector
+
+import (
+	"fmt"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// Slippage models selectable by name in the configuration
+const (
+	SlippageNone   = "none"   // Fill at the best bid/ask
+	SlippageFixed  = "fixed"  // Constant slippage in basis points
+	SlippageLinear = "linear" // Fixed slippage plus market impact growing with the trade notional
+	SlippageBook   = "book"   // Walk the order book depth, falling back to the linear model
+)
+
+// SlippageEstimator estimates the average price a market order actually fills at
+// @author VrushankPatel
+// @description Pluggable model of execution costs beyond the taker fee; side is "buy" (filled
+// against the asks) or "sell" (filled against the bids)
+type SlippageEstimator interface {
+	// EffectivePrice returns the average fill price of trading quantity on the book, and
+	// false if the model cannot price that size
+	EffectivePrice(book models.OrderBook, side string, quantity float64) (float64, bool)
+}
+
+// FixedSlippage fills every order a constant number of basis points beyond the best price
+// @author VrushankPatel
+// @description Simplest model, for venues without depth data
+type FixedSlippage struct {
+	Bps float64
+}
+
+// EffectivePrice moves the best price against the order by Bps
+// @author VrushankPatel
+// @description Ignores the order size
+// @param book The order book traded against
+// @param side "buy" or "sell"
+// @param quantity The order size in base currency
+// @return The effective price, and false if the book has no price on that side
+func (f FixedSlippage) EffectivePrice(book models.OrderBook, side string, quantity float64) (float64, bool) {
+	return applyBps(book, side, f.Bps)
+}
+
+// LinearSlippage adds market impact proportional to the order notional
+// @author VrushankPatel
+// @description Slippage in basis points is FixedBps + ImpactBpsPerMillion * notional / 1,000,000,
+// with the notional in quote currency at the best price
+type LinearSlippage struct {
+	FixedBps            float64
+	ImpactBpsPerMillion float64
+}
+
+// EffectivePrice moves the best price against the order by the size-dependent slippage
+// @author VrushankPatel
+// @description Larger orders are assumed to move the price linearly further
+// @param book The order book traded against
+// @param side "buy" or "sell"
+// @param quantity The order size in base currency
+// @return The effective price, and false if the book has no price on that side
+func (l LinearSlippage) EffectivePrice(book models.OrderBook, side string, quantity float64) (float64, bool) {
+	best, ok := bestPrice(book, side)
+	if !ok {
+		return 0, false
+	}
+	notional := quantity * best
+	return applyBps(book, side, l.FixedBps+l.ImpactBpsPerMillion*notional/1e6)
+}
+
+// BookSlippage walks the order book depth to find the volume-weighted fill price
+// @author VrushankPatel
+// @description Exact for the visible depth; when the book has no depth or too little to fill the
+// order, the Fallback model prices the whole order instead (no fallback means it cannot be priced)
+type BookSlippage struct {
+	Fallback SlippageEstimator
+}
+
+// EffectivePrice returns the volume-weighted price of filling quantity level by level
+// @author VrushankPatel
+// @description Consumes the asks for buys and the bids for sells, best first
+// @param book The order book traded against
+// @param side "buy" or "sell"
+// @param quantity The order size in base currency
+// @return The effective price, and false if neither the depth nor the fallback can price the order
+func (b BookSlippage) EffectivePrice(book models.OrderBook, side string, quantity float64) (float64, bool) {
+	levels := book.Bids
+	if side == "buy" {
+		levels = book.Asks
+	}
+
+	remaining := quantity
+	value := 0.0
+	for _, level := range levels {
+		if remaining <= 0 {
+			break
+		}
+		fill := level.Quantity
+		if fill > remaining {
+			fill = remaining
+		}
+		value += fill * level.Price
+		remaining -= fill
+	}
+	if quantity > 0 && remaining <= quantity*1e-9 {
+		return value / quantity, true
+	}
+
+	if b.Fallback == nil {
+		return 0, false
+	}
+	return b.Fallback.EffectivePrice(book, side, quantity)
+}
+
+// NewSlippageEstimator creates the slippage model selected in the configuration
+// @author VrushankPatel
+// @description Builds one of the SlippageNone, SlippageFixed, SlippageLinear or SlippageBook models
+// @param model The model name
+// @param fixedBps Fixed slippage in basis points (fixed, linear and the book fallback)
+// @param impactBpsPerMillion Impact per million of notional in basis points (linear and the book fallback)
+// @return The estimator (nil for SlippageNone), or an error for an unknown model name
+func NewSlippageEstimator(model string, fixedBps, impactBpsPerMillion float64) (SlippageEstimator, error) {
+	switch model {
+	case SlippageNone, "":
+		return nil, nil
+	case SlippageFixed:
+		return FixedSlippage{Bps: fixedBps}, nil
+	case SlippageLinear:
+		return LinearSlippage{FixedBps: fixedBps, ImpactBpsPerMillion: impactBpsPerMillion}, nil
+	case SlippageBook:
+		return BookSlippage{Fallback: LinearSlippage{FixedBps: fixedBps, ImpactBpsPerMillion: impactBpsPerMillion}}, nil
+	default:
+		return nil, fmt.Errorf("unknown slippage model %q (expected %s, %s, %s or %s)", model, SlippageNone, SlippageFixed, SlippageLinear, SlippageBook)
+	}
+}
+
+// SetSlippage makes cross-exchange opportunities be evaluated at effective prices from the slippage estimator
+// @author VrushankPatel
+// @description BuyPrice and SellPrice become the effective fill prices of buying and selling the
+// trade size (see SetTradeSizes), and profit is computed from them. Multi-leg opportunities keep
+// top of book rates.
+// @param estimator The slippage model, or nil to evaluate at the best bid/ask
+func (a *APEX) SetSlippage(estimator SlippageEstimator) {
+	a.slippage = estimator
+}
+
+// bestPrice returns the best ask for buys and the best bid for sells
+func bestPrice(book models.OrderBook, side string) (float64, bool) {
+	price := book.Bid
+	if side == "buy" {
+		price = book.Ask
+	}
+	return price, price > 0
+}
+
+// applyBps moves the best price against the order by bps
+func applyBps(book models.OrderBook, side string, bps float64) (float64, bool) {
+	best, ok := bestPrice(book, side)
+	if !ok {
+		return 0, false
+	}
+	if side == "buy" {
+		return best * (1 + bps/10000), true
+	}
+	return best * (1 - bps/10000), true
+}
@@ -0,0 +1,177 @@
+package detector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// detectTriangularOpportunities evaluates every three-leg currency cycle that can be
+// traded on a single exchange with the given order books
+// @author VrushankPatel
+// @description Finds intra-exchange mispricings such as USDT→BTC→ETH→USDT with taker fees applied per leg
+// @param exchange The name of the exchange the books belong to
+// @param books Fresh order books of that exchange
+func (a *APEX) detectTriangularOpportunities(exchange string, books []models.OrderBook) {
+	fee, hasFee := a.exchangeFees[exchange]
+	if !hasFee || len(books) < 3 {
+		return
+	}
+
+	legs := conversionLegs(exchange, books, fee)
+
+	currencies := make([]string, 0, len(legs))
+	for currency := range legs {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	seen := make(map[string]bool)
+	for _, first := range currencies {
+		for second, leg1 := range legs[first] {
+			for third, leg2 := range legs[second] {
+				if third == first {
+					continue
+				}
+				leg3, closes := legs[third][first]
+				if !closes {
+					continue
+				}
+
+				cycle := []models.TradeLeg{leg1, leg2, leg3}
+				cycle = rotateToHomeCurrency(cycle)
+
+				// Each cycle is reachable from all three of its currencies; report it once
+				key := cycleKey(cycle)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				a.checkTriangularCycle(exchange, cycle)
+			}
+		}
+	}
+}
+
+// checkTriangularCycle compounds the fee-adjusted rates of a cycle and observes an
+// opportunity when the result exceeds the profit threshold, closing it otherwise
+func (a *APEX) checkTriangularCycle(exchange string, cycle []models.TradeLeg) {
+	rate := 1.0
+	for _, leg := range cycle {
+		rate *= leg.Rate
+	}
+
+	profit := rate - 1
+	if profit <= a.minProfitThreshold {
+		a.closeOpportunity(models.ArbitrageOpportunity{Type: models.OpportunityTriangular, Legs: cycle}.RouteID())
+		return
+	}
+
+	start := cycle[0].From
+	opportunity := models.ArbitrageOpportunity{
+		Type:             models.OpportunityTriangular,
+		Timestamp:        a.clock.Now(),
+		QuoteCurrency:    start,
+		BuyExchange:      exchange,
+		SellExchange:     exchange,
+		BuyPrice:         cycle[0].Price,
+		SellPrice:        cycle[len(cycle)-1].Price,
+		ProfitPercentage: profit * 100, // Convert to percentage
+		NetProfit:        profit,       // Per unit of the starting currency
+		Legs:             cycle,
+	}
+
+	a.observeOpportunity(opportunity)
+}
+
+// conversionLegs builds, for every currency, the legs that convert it into another
+// currency through one of the exchange's markets, with the taker fee deducted
+func conversionLegs(exchange string, books []models.OrderBook, fee float64) map[string]map[string]models.TradeLeg {
+	legs := make(map[string]map[string]models.TradeLeg)
+	addLeg := func(leg models.TradeLeg) {
+		if _, exists := legs[leg.From]; !exists {
+			legs[leg.From] = make(map[string]models.TradeLeg)
+		}
+		legs[leg.From][leg.To] = leg
+	}
+
+	for _, book := range books {
+		if book.Bid <= 0 || book.Ask <= 0 {
+			continue
+		}
+
+		// Spending quote to buy base at the ask
+		addLeg(models.TradeLeg{
+			Exchange:      exchange,
+			BaseCurrency:  book.BaseCurrency,
+			QuoteCurrency: book.QuoteCurrency,
+			Side:          "buy",
+			Price:         book.Ask,
+			From:          book.QuoteCurrency,
+			To:            book.BaseCurrency,
+			Rate:          (1 / book.Ask) * (1 - fee),
+		})
+
+		// Selling base for quote at the bid
+		addLeg(models.TradeLeg{
+			Exchange:      exchange,
+			BaseCurrency:  book.BaseCurrency,
+			QuoteCurrency: book.QuoteCurrency,
+			Side:          "sell",
+			Price:         book.Bid,
+			From:          book.BaseCurrency,
+			To:            book.QuoteCurrency,
+			Rate:          book.Bid * (1 - fee),
+		})
+	}
+
+	return legs
+}
+
+// rotateToHomeCurrency rotates a cycle so it starts from the currency used as quote by
+// the most of its markets (e.g. USDT), which is the natural currency to measure profit in
+func rotateToHomeCurrency(cycle []models.TradeLeg) []models.TradeLeg {
+	quoteCount := make(map[string]int)
+	for _, leg := range cycle {
+		quoteCount[leg.QuoteCurrency]++
+	}
+
+	best := 0
+	for i, leg := range cycle {
+		current, candidate := cycle[best].From, leg.From
+		if quoteCount[candidate] > quoteCount[current] ||
+			(quoteCount[candidate] == quoteCount[current] && candidate < current) {
+			best = i
+		}
+	}
+
+	rotated := make([]models.TradeLeg, 0, len(cycle))
+	rotated = append(rotated, cycle[best:]...)
+	return append(rotated, cycle[:best]...)
+}
+
+// cycleKey identifies a cycle by its currency path, e.g. "USDT>BTC>ETH>USDT"
+func cycleKey(cycle []models.TradeLeg) string {
+	path := make([]string, 0, len(cycle)+1)
+	for _, leg := range cycle {
+		path = append(path, leg.From)
+	}
+	path = append(path, cycle[0].From)
+	return strings.Join(path, ">")
+}
+
+// formatLegs renders a leg sequence for logging, e.g. "buy BTC/USDT@Binance 70000 → ..."
+func formatLegs(legs []models.TradeLeg) string {
+	parts := make([]string, 0, len(legs))
+	for _, leg := range legs {
+		if leg.Side == "transfer" {
+			parts = append(parts, fmt.Sprintf("transfer %s %s→%s", leg.From, leg.Exchange, leg.ToExchange))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s/%s@%s %.8g", leg.Side, leg.BaseCurrency, leg.QuoteCurrency, leg.Exchange, leg.Price))
+	}
+	return strings.Join(parts, " → ")
+}
@@ -4,6 +4,8 @@ import (
         "context"
         "encoding/json"
         "fmt"
+        "net/http"
+        "strings"
         "sync"
         "time"
 
@@ -16,96 +18,99 @@ import (
 // Binance defines the Binance exchange client
 type Binance struct {
         BaseExchange
-        wsURL    string
-        conn     *websocket.Conn
+        wsURL      string
+        restURL    string
+        conn       *websocket.Conn
+        connMutex  sync.Mutex // Serializes writes, which also come from SetTradingPairs
+        requestID  int
+        httpClient *http.Client
+        depth      map[string]*binanceDepthState // Keyed by symbol
+        snapshots  chan binanceSnapshotResult    // Snapshots fetched off the read loop
+}
+
+// binanceDepthState tracks the local depth book of one symbol and its sync position
+type binanceDepthState struct {
+        book         *models.DepthBook
+        lastUpdateID int64
+        synced       bool
+        // Whether a snapshot is being fetched, and the diffs received meanwhile
+        loading  bool
+        buffered []BinanceDepthUpdate
+}
+
+// binanceSnapshotResult is a depth snapshot fetched for a symbol's depth state
+type binanceSnapshotResult struct {
         symbol   string
-        pairs    []models.TradingPair
-}
-
-// BinanceBookTickerResponse defines the structure of Binance's bookTicker websocket response
-type BinanceBookTickerResponse struct {
-        UpdateID   int64   `json:"u"`
-        Symbol     string  `json:"s"`
-        BestBidQty string  `json:"B"`
-        BestBid    string  `json:"b"`
-        BestAskQty string  `json:"A"`
-        BestAsk    string  `json:"a"`
-        EventTime  int64   `json:"E"`
-}
-
-// NewBinance creates a new Binance exchange client
-func NewBinance(symbol string) (*Binance, error) {
-        // Parse the symbol to derive base and quote currencies
-        // Assuming standard format like BTCUSDT, extract BTC and USDT
-        var baseCurrency, quoteCurrency string
-        
-        if len(symbol) >= 7 {
-            // Most common quote currencies are USDT (4), BUSD (4), USDC (4), USD (3)
-            baseCurrency = symbol[:len(symbol)-4]
-            quoteCurrency = symbol[len(symbol)-4:]
-        } else if len(symbol) >= 6 {
-            // Fallback for pairs like BTCUSD
-            baseCurrency = symbol[:len(symbol)-3]
-            quoteCurrency = symbol[len(symbol)-3:]
-        } else {
-            // Default fallback
-            baseCurrency = "BTC"
-            quoteCurrency = "USDT"
-        }
-        
-        return &Binance{
-                BaseExchange: BaseExchange{
-                        name:   "Binance",
-                        symbol: symbol,
-                        orderBook: &models.OrderBook{
-                                Exchange:      "Binance",
-                                Symbol:        symbol,
-                                BaseCurrency:  baseCurrency,
-                                QuoteCurrency: quoteCurrency,
-                        },
-                        takerFee: 0.001, // 0.1% is the default fee
-                },
-                wsURL:  "wss://stream.binance.com:9443/ws",
-                symbol: symbol,
-        }, nil
+        state    *binanceDepthState
+        snapshot BinanceDepthSnapshot
+        err      error
+}
+
+// binanceMaxBufferedDiffs is the most diffs kept per symbol while its snapshot loads; beyond
+// that they are dropped and the gap forces another snapshot
+const binanceMaxBufferedDiffs = 1000
+
+// BinanceDepthUpdate defines the structure of Binance's depth diff websocket event
+type BinanceDepthUpdate struct {
+        EventType     string     `json:"e"`
+        EventTime     int64      `json:"E"`
+        Symbol        string     `json:"s"`
+        FirstUpdateID int64      `json:"U"`
+        FinalUpdateID int64      `json:"u"`
+        Bids          [][]string `json:"b"`
+        Asks          [][]string `json:"a"`
+}
+
+// BinanceDepthSnapshot defines the structure of Binance's REST depth snapshot
+type BinanceDepthSnapshot struct {
+        LastUpdateID int64      `json:"lastUpdateId"`
+        Bids         [][]string `json:"bids"`
+        Asks         [][]string `json:"asks"`
+}
+
+// BinanceCombinedStreamResponse defines the envelope Binance wraps combined stream payloads in
+type BinanceCombinedStreamResponse struct {
+        Stream string          `json:"stream"`
+        Data   json.RawMessage `json:"data"`
+}
+
+// NewBinance creates a new Binance exchange client streaming all given trading pairs
+func NewBinance(pairs []models.TradingPair) (*Binance, error) {
+        if len(pairs) == 0 {
+                return nil, fmt.Errorf("at least one trading pair is required")
+        }
+
+        b := &Binance{
+                wsURL:      "wss://stream.binance.com:9443/stream",
+                restURL:    "https://api.binance.com/api/v3/depth",
+                httpClient: &http.Client{Timeout: 10 * time.Second},
+                snapshots:  make(chan binanceSnapshotResult, 16),
+        }
+        b.init("Binance", pairs, 0.001) // 0.1% is the default fee
+        return b, nil
 }
 
 // Connect establishes a websocket connection to Binance and starts streaming order book data
-func (b *Binance) Connect(ctx context.Context, orderBooks map[string]*models.OrderBook, mu *sync.RWMutex) {
-        // Let's use the actual combined streams endpoint for better reliability
-        wsEndpoint := "wss://stream.binance.com:9443/ws"
-        
-        log.Infof("[Binance] Connecting to %s", wsEndpoint)
+func (b *Binance) Connect(ctx context.Context, store *models.OrderBookStore) {
+        // Use the combined streams endpoint so every symbol shares one connection
+        log.Infof("[Binance] Connecting to %s", b.wsURL)
         
         // Setup custom dialer with longer timeouts
         dialer := websocket.DefaultDialer
         dialer.HandshakeTimeout = 10 * time.Second
         
-        var err error
-        b.conn, _, err = dialer.Dial(wsEndpoint, nil)
+        conn, _, err := dialer.Dial(b.wsURL, nil)
         if err != nil {
                 log.Errorf("[Binance] Failed to connect to websocket: %v", err)
-                
-                // Initialize with some dummy data so the simulation can work
-                log.Warn("[Binance] Using simulation mode for demonstration purposes")
-                b.orderBook.Bid = 69500.0
-                b.orderBook.Ask = 69550.0
-                b.orderBook.LastUpdate = time.Now()
-                updateOrderBookMap(b.Name(), b.orderBook, orderBooks, mu)
-                
                 return
         }
+        b.connMutex.Lock()
+        b.conn = conn
+        b.connMutex.Unlock()
+        b.attach(store)
         
-        // Subscribe to the stream using the message format from Binance docs
-        subscribeMsg := map[string]interface{}{
-                "method": "SUBSCRIBE",
-                "params": []string{
-                        fmt.Sprintf("%s@bookTicker", b.symbol),
-                },
-                "id": 1,
-        }
-        
-        if err := b.conn.WriteJSON(subscribeMsg); err != nil {
+        // Subscribe to the depth diff stream of every symbol
+        if err := b.subscribe("SUBSCRIBE", b.symbols()); err != nil {
                 log.Errorf("[Binance] Failed to subscribe to stream: %v", err)
                 b.Close()
                 return
@@ -120,7 +125,17 @@ func (b *Binance) Connect(ctx context.Context, orderBooks map[string]*models.Ord
                 b.Close()
         }()
         
-        log.Infof("[Binance] Connected to websocket for %s", b.symbol)
+        log.Infof("[Binance] Connected to websocket for %s", strings.Join(b.symbols(), ", "))
+        
+        // Fetch the initial snapshots concurrently; each symbol's diffs are buffered until its
+        // snapshot arrives, so no symbol waits for the others
+        symbols := b.symbols()
+        b.depth = make(map[string]*binanceDepthState, len(symbols))
+        for _, symbol := range symbols {
+                state := &binanceDepthState{book: models.NewDepthBook()}
+                b.depth[symbol] = state
+                b.requestSnapshot(ctx, symbol, state)
+        }
         
         // Process incoming messages
         for {
@@ -129,7 +144,7 @@ func (b *Binance) Connect(ctx context.Context, orderBooks map[string]*models.Ord
                         return
                 default:
                         // Read message from websocket
-                        _, message, err := b.conn.ReadMessage()
+                        _, message, err := conn.ReadMessage()
                         if err != nil {
                                 log.Errorf("[Binance] Error reading from websocket: %v", err)
                                 
@@ -137,11 +152,17 @@ func (b *Binance) Connect(ctx context.Context, orderBooks map[string]*models.Ord
                                 if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
                                         time.Sleep(5 * time.Second)
                                         log.Info("[Binance] Attempting to reconnect...")
-                                        b.Connect(ctx, orderBooks, mu)
+                                        b.Connect(ctx, store)
                                         return
                                 }
                                 return
                         }
+
+                        // Keep the raw frame for the tick recorder
+                        b.recordFrame(message)
+                        
+                        // Apply the snapshots fetched since the last message
+                        b.applySnapshots(ctx, store)
                         
                         // First, check if this is a subscription response
                         var subResponse map[string]interface{}
@@ -153,46 +174,259 @@ func (b *Binance) Connect(ctx context.Context, orderBooks map[string]*models.Ord
                                 }
                         }
                         
-                        // Parse the message as ticker data
-                        var tickerData BinanceBookTickerResponse
-                        if err := json.Unmarshal(message, &tickerData); err != nil {
-                                log.Errorf("[Binance] Error parsing message: %v", err)
+                        // Unwrap the combined stream envelope
+                        var envelope BinanceCombinedStreamResponse
+                        if err := json.Unmarshal(message, &envelope); err != nil || len(envelope.Data) == 0 {
+                                log.Errorf("[Binance] Error parsing stream envelope: %v", err)
                                 log.Debugf("[Binance] Raw message: %s", string(message))
                                 continue
                         }
                         
-                        // Validate we have the required fields
-                        if tickerData.BestBid == "" || tickerData.BestAsk == "" {
-                                log.Debugf("[Binance] Incomplete ticker data received")
+                        // Parse the payload as a depth diff
+                        var update BinanceDepthUpdate
+                        if err := json.Unmarshal(envelope.Data, &update); err != nil {
+                                log.Errorf("[Binance] Error parsing message: %v", err)
+                                log.Debugf("[Binance] Raw message: %s", string(message))
                                 continue
                         }
                         
-                        // Update our order book
-                        bid, err := models.ParseFloat(tickerData.BestBid)
-                        if err != nil {
-                                log.Errorf("[Binance] Error parsing bid: %v", err)
+                        if update.EventType != "depthUpdate" {
+                                log.Debugf("[Binance] Received non-depth event: %s", update.EventType)
                                 continue
                         }
                         
-                        ask, err := models.ParseFloat(tickerData.BestAsk)
-                        if err != nil {
-                                log.Errorf("[Binance] Error parsing ask: %v", err)
+                        state, exists := b.depth[update.Symbol]
+                        if !b.tracks(update.Symbol) {
+                                delete(b.depth, update.Symbol)
+                                log.Debugf("[Binance] Received depth update for unknown symbol %s", update.Symbol)
                                 continue
                         }
+                        if !exists {
+                                // The pair was added while connected; its diffs need a snapshot to apply to
+                                state = &binanceDepthState{book: models.NewDepthBook()}
+                                b.depth[update.Symbol] = state
+                                b.requestSnapshot(ctx, update.Symbol, state)
+                        }
                         
-                        // Update the order book
-                        b.orderBook.Bid = bid
-                        b.orderBook.Ask = ask
-                        b.orderBook.LastUpdate = time.Now()
+                        if !b.applyDepthUpdate(ctx, state, update) {
+                                continue
+                        }
                         
-                        // Update the shared map
-                        updateOrderBookMap(b.Name(), b.orderBook, orderBooks, mu)
+                        // Publish the top levels for the symbol to the shared store
+                        b.updateOrderBook(update.Symbol, state.book.Bids(bookDepth), state.book.Asks(bookDepth), store)
+                }
+        }
+}
+
+// applyDepthUpdate applies a diff event to a symbol's depth book following Binance's
+// sync rules, reloading the snapshot if a gap in update IDs is detected.
+// It returns true when the book changed and is in sync.
+func (b *Binance) applyDepthUpdate(ctx context.Context, state *binanceDepthState, update BinanceDepthUpdate) bool {
+        // Keep diffs until the snapshot they apply to arrives
+        if state.loading {
+                if len(state.buffered) < binanceMaxBufferedDiffs {
+                        state.buffered = append(state.buffered, update)
+                }
+                return false
+        }
+        
+        // Drop events already contained in the snapshot
+        if update.FinalUpdateID <= state.lastUpdateID {
+                return false
+        }
+        
+        if !state.synced {
+                // The first event after the snapshot must straddle its lastUpdateId
+                if update.FirstUpdateID > state.lastUpdateID+1 {
+                        log.Warnf("[Binance] Depth snapshot for %s is behind the stream, reloading", update.Symbol)
+                        b.requestSnapshot(ctx, update.Symbol, state)
+                        return false
+                }
+                state.synced = true
+        } else if update.FirstUpdateID != state.lastUpdateID+1 {
+                log.Warnf("[Binance] Gap in depth updates for %s, reloading snapshot", update.Symbol)
+                b.requestSnapshot(ctx, update.Symbol, state)
+                return false
+        }
+        
+        for _, entry := range update.Bids {
+                if len(entry) < 2 {
+                        continue
+                }
+                level, err := parseLevel(entry[0], entry[1])
+                if err != nil {
+                        log.Errorf("[Binance] Error parsing bid level: %v", err)
+                        continue
+                }
+                state.book.UpdateBid(level.Price, level.Quantity)
+        }
+        for _, entry := range update.Asks {
+                if len(entry) < 2 {
+                        continue
+                }
+                level, err := parseLevel(entry[0], entry[1])
+                if err != nil {
+                        log.Errorf("[Binance] Error parsing ask level: %v", err)
+                        continue
+                }
+                state.book.UpdateAsk(level.Price, level.Quantity)
+        }
+        
+        state.lastUpdateID = update.FinalUpdateID
+        return true
+}
+
+// requestSnapshot fetches a fresh snapshot for a symbol without blocking the read loop, which
+// buffers the symbol's diffs until applySnapshots replaces the book with it
+func (b *Binance) requestSnapshot(ctx context.Context, symbol string, state *binanceDepthState) {
+        state.loading = true
+        state.buffered = nil
+        go func() {
+                snapshot, err := b.fetchSnapshot(ctx, symbol)
+                select {
+                case b.snapshots <- binanceSnapshotResult{symbol: symbol, state: state, snapshot: snapshot, err: err}:
+                case <-ctx.Done():
+                }
+        }()
+}
+
+// applySnapshots applies the snapshots fetched since the last call, replays the diffs buffered
+// meanwhile and publishes the books that changed
+func (b *Binance) applySnapshots(ctx context.Context, store *models.OrderBookStore) {
+        for {
+                var result binanceSnapshotResult
+                select {
+                case result = <-b.snapshots:
+                default:
+                        return
+                }
+                
+                // Snapshots of removed pairs or of an earlier connection are stale
+                state := result.state
+                if b.depth[result.symbol] != state || !state.loading {
+                        continue
+                }
+                buffered := state.buffered
+                state.loading = false
+                state.buffered = nil
+                if result.err != nil {
+                        // The next diff finds the book out of sync and requests another snapshot
+                        log.Errorf("[Binance] Failed to load depth snapshot for %s: %v", result.symbol, result.err)
+                        state.lastUpdateID = 0
+                        state.synced = false
+                        continue
+                }
+                b.applySnapshot(result.symbol, state, result.snapshot)
+                
+                changed := false
+                for _, update := range buffered {
+                        if b.applyDepthUpdate(ctx, state, update) {
+                                changed = true
+                        }
+                }
+                if changed {
+                        b.updateOrderBook(result.symbol, state.book.Bids(bookDepth), state.book.Asks(bookDepth), store)
+                }
+        }
+}
+
+// fetchSnapshot requests the REST depth snapshot of a symbol
+func (b *Binance) fetchSnapshot(ctx context.Context, symbol string) (BinanceDepthSnapshot, error) {
+        var snapshot BinanceDepthSnapshot
+        url := fmt.Sprintf("%s?symbol=%s&limit=1000", b.restURL, symbol)
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+        if err != nil {
+                return snapshot, err
+        }
+        
+        resp, err := b.httpClient.Do(req)
+        if err != nil {
+                return snapshot, err
+        }
+        defer resp.Body.Close()
+        
+        if resp.StatusCode != http.StatusOK {
+                return snapshot, fmt.Errorf("unexpected status %s", resp.Status)
+        }
+        
+        if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+                return snapshot, fmt.Errorf("failed to decode snapshot: %v", err)
+        }
+        return snapshot, nil
+}
+
+// applySnapshot replaces a symbol's depth book with a snapshot; the next diff must follow it
+func (b *Binance) applySnapshot(symbol string, state *binanceDepthState, snapshot BinanceDepthSnapshot) {
+        state.book.Reset()
+        for _, entry := range snapshot.Bids {
+                if len(entry) < 2 {
+                        continue
+                }
+                if level, err := parseLevel(entry[0], entry[1]); err == nil {
+                        state.book.UpdateBid(level.Price, level.Quantity)
+                }
+        }
+        for _, entry := range snapshot.Asks {
+                if len(entry) < 2 {
+                        continue
+                }
+                if level, err := parseLevel(entry[0], entry[1]); err == nil {
+                        state.book.UpdateAsk(level.Price, level.Quantity)
                 }
         }
+        state.lastUpdateID = snapshot.LastUpdateID
+        state.synced = false
+        
+        log.Debugf("[Binance] Loaded depth snapshot for %s at update %d", symbol, snapshot.LastUpdateID)
+}
+
+// SetTradingPairs changes the streamed trading pairs, subscribing to the depth streams of new
+// pairs and unsubscribing from removed ones on the open connection
+func (b *Binance) SetTradingPairs(pairs []models.TradingPair) {
+        added, removed := b.setPairs(pairs)
+        if len(removed) > 0 {
+                if err := b.subscribe("UNSUBSCRIBE", removed); err != nil {
+                        log.Errorf("[Binance] Failed to unsubscribe from %s: %v", strings.Join(removed, ", "), err)
+                } else {
+                        log.Infof("[Binance] Unsubscribed from %s", strings.Join(removed, ", "))
+                }
+        }
+        if len(added) > 0 {
+                if err := b.subscribe("SUBSCRIBE", added); err != nil {
+                        log.Errorf("[Binance] Failed to subscribe to %s: %v", strings.Join(added, ", "), err)
+                } else {
+                        log.Infof("[Binance] Subscribed to %s", strings.Join(added, ", "))
+                }
+        }
+}
+
+// subscribe sends a SUBSCRIBE or UNSUBSCRIBE request for the depth diff streams of the
+// symbols. Without a connection it does nothing, since connecting subscribes to every pair.
+func (b *Binance) subscribe(method string, symbols []string) error {
+        b.connMutex.Lock()
+        defer b.connMutex.Unlock()
+        if b.conn == nil {
+                return nil
+        }
+        
+        // Stream names must be lowercase
+        streams := make([]string, 0, len(symbols))
+        for _, symbol := range symbols {
+                streams = append(streams, fmt.Sprintf("%s@depth@100ms", strings.ToLower(symbol)))
+        }
+        
+        b.requestID++
+        return b.conn.WriteJSON(map[string]interface{}{
+                "method": method,
+                "params": streams,
+                "id":     b.requestID,
+        })
 }
 
 // Close closes the websocket connection
 func (b *Binance) Close() error {
+        b.connMutex.Lock()
+        defer b.connMutex.Unlock()
         if b.conn != nil {
                 return b.conn.Close()
         }
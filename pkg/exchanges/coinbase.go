@@ -0,0 +1,209 @@
+package exchanges
+
+import (
+        "context"
+        "encoding/json"
+        "fmt"
+        "strings"
+        "sync"
+        "time"
+
+        "apex-arbitrage/pkg/models"
+
+        "github.com/gorilla/websocket"
+        log "github.com/sirupsen/logrus"
+)
+
+// Coinbase defines the Coinbase exchange client
+type Coinbase struct {
+        BaseExchange
+        wsURL     string
+        conn      *websocket.Conn
+        connMutex sync.Mutex // Serializes writes, which also come from SetTradingPairs
+}
+
+// CoinbaseSubscribeMessage defines the structure for the subscription request
+type CoinbaseSubscribeMessage struct {
+        Type       string   `json:"type"`
+        ProductIDs []string `json:"product_ids"`
+        Channels   []string `json:"channels"`
+}
+
+// CoinbaseTickerResponse defines the structure of Coinbase's ticker websocket response
+type CoinbaseTickerResponse struct {
+        Type        string `json:"type"`
+        Sequence    int64  `json:"sequence"`
+        ProductID   string `json:"product_id"`
+        Price       string `json:"price"`
+        BestBid     string `json:"best_bid"`
+        BestBidSize string `json:"best_bid_size"`
+        BestAsk     string `json:"best_ask"`
+        BestAskSize string `json:"best_ask_size"`
+        Time        string `json:"time"`
+        Message     string `json:"message"`
+        Reason      string `json:"reason"`
+}
+
+// NewCoinbase creates a new Coinbase exchange client streaming all given trading pairs
+func NewCoinbase(pairs []models.TradingPair) (*Coinbase, error) {
+        if len(pairs) == 0 {
+                return nil, fmt.Errorf("at least one trading pair is required")
+        }
+
+        c := &Coinbase{
+                wsURL: "wss://ws-feed.exchange.coinbase.com",
+        }
+        c.init("Coinbase", pairs, 0.0025) // 0.25% is the default fee
+        return c, nil
+}
+
+// Connect establishes a websocket connection to Coinbase and starts streaming order book data
+func (c *Coinbase) Connect(ctx context.Context, store *models.OrderBookStore) {
+        log.Infof("[Coinbase] Connecting to %s", c.wsURL)
+
+        // Setup custom dialer with longer timeouts
+        dialer := websocket.DefaultDialer
+        dialer.HandshakeTimeout = 10 * time.Second
+
+        conn, _, err := dialer.Dial(c.wsURL, nil)
+        if err != nil {
+                log.Errorf("[Coinbase] Failed to connect to websocket: %v", err)
+                return
+        }
+        c.connMutex.Lock()
+        c.conn = conn
+        c.connMutex.Unlock()
+        c.attach(store)
+
+        defer c.Close()
+
+        // Monitor for context cancellation
+        go func() {
+                <-ctx.Done()
+                log.Info("[Coinbase] Context cancelled, closing connection")
+                c.Close()
+        }()
+
+        // Subscribe to the ticker channel, which carries best bid/ask with sizes
+        if err := c.subscribe("subscribe", c.symbols()); err != nil {
+                log.Errorf("[Coinbase] Failed to send subscription request: %v", err)
+                return
+        }
+
+        log.Infof("[Coinbase] Subscribed to ticker for %s", strings.Join(c.symbols(), ", "))
+
+        // Process incoming messages
+        for {
+                select {
+                case <-ctx.Done():
+                        return
+                default:
+                        // Read message from websocket
+                        _, message, err := conn.ReadMessage()
+                        if err != nil {
+                                log.Errorf("[Coinbase] Error reading from websocket: %v", err)
+
+                                // Try to reconnect
+                                if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+                                        time.Sleep(5 * time.Second)
+                                        log.Info("[Coinbase] Attempting to reconnect...")
+                                        c.Connect(ctx, store)
+                                        return
+                                }
+                                return
+                        }
+
+                        // Keep the raw frame for the tick recorder
+                        c.recordFrame(message)
+
+                        var tickerData CoinbaseTickerResponse
+                        if err := json.Unmarshal(message, &tickerData); err != nil {
+                                log.Errorf("[Coinbase] Error parsing message: %v", err)
+                                log.Debugf("[Coinbase] Raw message: %s", string(message))
+                                continue
+                        }
+
+                        switch tickerData.Type {
+                        case "ticker":
+                                // Handled below
+                        case "error":
+                                log.Errorf("[Coinbase] Received error: %s (%s)", tickerData.Message, tickerData.Reason)
+                                continue
+                        default:
+                                // Subscription confirmations, heartbeats, etc.
+                                log.Debugf("[Coinbase] Received system message: %s", tickerData.Type)
+                                continue
+                        }
+
+                        // Validate we have the required fields
+                        if tickerData.BestBid == "" || tickerData.BestAsk == "" {
+                                log.Debugf("[Coinbase] Incomplete ticker data received")
+                                continue
+                        }
+
+                        // The ticker only carries the top of book, so publish it as one level per side
+                        bid, err := parseLevel(tickerData.BestBid, tickerData.BestBidSize)
+                        if err != nil {
+                                log.Errorf("[Coinbase] Error parsing bid: %v", err)
+                                continue
+                        }
+
+                        ask, err := parseLevel(tickerData.BestAsk, tickerData.BestAskSize)
+                        if err != nil {
+                                log.Errorf("[Coinbase] Error parsing ask: %v", err)
+                                continue
+                        }
+
+                        // Update the order book for the product and the shared map
+                        if !c.updateOrderBook(tickerData.ProductID, []models.PriceLevel{bid}, []models.PriceLevel{ask}, store) {
+                                log.Debugf("[Coinbase] Received ticker for unknown product %s", tickerData.ProductID)
+                        }
+                }
+        }
+}
+
+// SetTradingPairs changes the streamed trading pairs, subscribing to the tickers of new
+// products and unsubscribing from removed ones on the open connection
+func (c *Coinbase) SetTradingPairs(pairs []models.TradingPair) {
+        added, removed := c.setPairs(pairs)
+        if len(removed) > 0 {
+                if err := c.subscribe("unsubscribe", removed); err != nil {
+                        log.Errorf("[Coinbase] Failed to unsubscribe from %s: %v", strings.Join(removed, ", "), err)
+                } else {
+                        log.Infof("[Coinbase] Unsubscribed from ticker for %s", strings.Join(removed, ", "))
+                }
+        }
+        if len(added) > 0 {
+                if err := c.subscribe("subscribe", added); err != nil {
+                        log.Errorf("[Coinbase] Failed to subscribe to %s: %v", strings.Join(added, ", "), err)
+                } else {
+                        log.Infof("[Coinbase] Subscribed to ticker for %s", strings.Join(added, ", "))
+                }
+        }
+}
+
+// subscribe sends a subscribe or unsubscribe request for the tickers of the products. Without
+// a connection it does nothing, since connecting subscribes to every product.
+func (c *Coinbase) subscribe(messageType string, productIDs []string) error {
+        c.connMutex.Lock()
+        defer c.connMutex.Unlock()
+        if c.conn == nil {
+                return nil
+        }
+
+        return c.conn.WriteJSON(CoinbaseSubscribeMessage{
+                Type:       messageType,
+                ProductIDs: productIDs,
+                Channels:   []string{"ticker"},
+        })
+}
+
+// Close closes the websocket connection
+func (c *Coinbase) Close() error {
+        c.connMutex.Lock()
+        defer c.connMutex.Unlock()
+        if c.conn != nil {
+                return c.conn.Close()
+        }
+        return nil
+}
@@ -2,9 +2,11 @@ package exchanges
 
 import (
         "context"
+        "fmt"
         "sync"
         "time"
 
+        "apex-arbitrage/pkg/clock"
         "apex-arbitrage/pkg/models"
 )
 
@@ -14,10 +16,17 @@ type Exchange interface {
         Name() string
         
         // Connect establishes a websocket connection and starts streaming order book data
-        Connect(ctx context.Context, orderBooks map[string]*models.OrderBook, mu *sync.RWMutex)
+        Connect(ctx context.Context, store *models.OrderBookStore)
         
-        // GetOrderBook returns the current orderbook snapshot for the exchange
-        GetOrderBook() *models.OrderBook
+        // GetOrderBook returns the current orderbook snapshot for a trading pair on the exchange
+        GetOrderBook(pair models.TradingPair) *models.OrderBook
+        
+        // GetTradingPairs returns the trading pairs the exchange streams
+        GetTradingPairs() []models.TradingPair
+
+        // SetTradingPairs changes the streamed trading pairs, subscribing to the new ones and
+        // unsubscribing from the removed ones without dropping the connection
+        SetTradingPairs(pairs []models.TradingPair)
         
         // Close closes the websocket connection
         Close() error
@@ -27,15 +36,53 @@ type Exchange interface {
         
         // GetTakerFee returns the exchange's taker fee rate
         GetTakerFee() float64
+
+        // SetTakerFee replaces the exchange's taker fee rate, e.g. with the configured one
+        SetTakerFee(fee float64)
+
+        // SetFrameRecorder makes the exchange pass every raw websocket frame to the recorder
+        SetFrameRecorder(recorder FrameRecorder)
+
+        // SetClock replaces the wall clock used to timestamp updates
+        SetClock(c clock.Clock)
 }
 
+// FrameRecorder receives raw websocket frames as they arrive from an exchange
+type FrameRecorder interface {
+        RecordFrame(exchange string, received time.Time, frame []byte)
+}
+
+// bookDepth is the number of price levels maintained and published per order book
+const bookDepth = 10
+
 // BaseExchange contains common fields and methods for exchanges
 type BaseExchange struct {
         name       string
-        symbol     string
-        orderBook  *models.OrderBook
-        lastUpdate time.Time
+        pairs      []models.TradingPair
+        orderBooks map[string]*models.OrderBook // Keyed by exchange-specific symbol
+        booksMutex sync.RWMutex
+        store      *models.OrderBookStore // Shared store the books are published to, once connected
         takerFee   float64
+        frames     FrameRecorder
+        clock      clock.Clock
+}
+
+// init sets up the base exchange with one empty order book per trading pair
+func (b *BaseExchange) init(name string, pairs []models.TradingPair, takerFee float64) {
+        b.name = name
+        b.pairs = pairs
+        b.takerFee = takerFee
+        b.clock = clock.Real()
+        b.orderBooks = make(map[string]*models.OrderBook, len(pairs))
+        for _, pair := range pairs {
+                symbol := pair.GetSymbol(name)
+                b.orderBooks[symbol] = &models.OrderBook{
+                        Exchange:      name,
+                        Symbol:        symbol,
+                        BaseCurrency:  pair.BaseCurrency,
+                        QuoteCurrency: pair.QuoteCurrency,
+                }
+        }
 }
 
 // Name returns the exchange name
@@ -43,24 +90,161 @@ func (b *BaseExchange) Name() string {
         return b.name
 }
 
-// GetOrderBook returns the current orderbook snapshot
-func (b *BaseExchange) GetOrderBook() *models.OrderBook {
-        return b.orderBook
+// GetOrderBook returns a snapshot of the current orderbook for a trading pair
+func (b *BaseExchange) GetOrderBook(pair models.TradingPair) *models.OrderBook {
+        b.booksMutex.RLock()
+        defer b.booksMutex.RUnlock()
+        book, exists := b.orderBooks[pair.GetSymbol(b.name)]
+        if !exists {
+                return nil
+        }
+        snapshot := *book
+        return &snapshot
+}
+
+// GetTradingPairs returns the trading pairs the exchange streams
+func (b *BaseExchange) GetTradingPairs() []models.TradingPair {
+        b.booksMutex.RLock()
+        defer b.booksMutex.RUnlock()
+        return append([]models.TradingPair{}, b.pairs...)
 }
 
 // GetTakerFee returns the exchange's taker fee rate
 func (b *BaseExchange) GetTakerFee() float64 {
+        b.booksMutex.RLock()
+        defer b.booksMutex.RUnlock()
         return b.takerFee
 }
 
+// SetTakerFee replaces the exchange's taker fee rate, e.g. with the configured one
+func (b *BaseExchange) SetTakerFee(fee float64) {
+        b.booksMutex.Lock()
+        defer b.booksMutex.Unlock()
+        b.takerFee = fee
+}
+
 // GetFormattedSymbol returns the exchange-specific trading pair format
 func (b *BaseExchange) GetFormattedSymbol(pair models.TradingPair) string {
         return pair.GetSymbol(b.name)
 }
 
-// updateOrderBookMap safely updates the shared orderbook map
-func updateOrderBookMap(exchange string, book *models.OrderBook, orderBooks map[string]*models.OrderBook, mu *sync.RWMutex) {
-        mu.Lock()
-        defer mu.Unlock()
-        orderBooks[exchange] = book
+// SetFrameRecorder makes the exchange pass every raw websocket frame to the recorder
+func (b *BaseExchange) SetFrameRecorder(recorder FrameRecorder) {
+        b.frames = recorder
+}
+
+// SetClock replaces the wall clock used to timestamp updates
+func (b *BaseExchange) SetClock(c clock.Clock) {
+        b.clock = c
+}
+
+// recordFrame hands a raw websocket frame to the frame recorder, if one is set
+func (b *BaseExchange) recordFrame(frame []byte) {
+        if b.frames != nil {
+                b.frames.RecordFrame(b.name, b.clock.Now(), frame)
+        }
+}
+
+// symbols returns the exchange-specific symbols of all streamed trading pairs
+func (b *BaseExchange) symbols() []string {
+        b.booksMutex.RLock()
+        defer b.booksMutex.RUnlock()
+        symbols := make([]string, 0, len(b.pairs))
+        for _, pair := range b.pairs {
+                symbols = append(symbols, pair.GetSymbol(b.name))
+        }
+        return symbols
+}
+
+// tracks reports whether a symbol belongs to one of the streamed trading pairs
+func (b *BaseExchange) tracks(symbol string) bool {
+        b.booksMutex.RLock()
+        defer b.booksMutex.RUnlock()
+        _, exists := b.orderBooks[symbol]
+        return exists
+}
+
+// attach remembers the store the order books are published to, so removed pairs can be dropped from it
+func (b *BaseExchange) attach(store *models.OrderBookStore) {
+        b.booksMutex.Lock()
+        defer b.booksMutex.Unlock()
+        b.store = store
+}
+
+// setPairs replaces the streamed trading pairs and returns the symbols added and removed.
+// The books of removed pairs are dropped here and from the shared store, and updates still
+// arriving for them are ignored from now on.
+func (b *BaseExchange) setPairs(pairs []models.TradingPair) (added, removed []string) {
+        b.booksMutex.Lock()
+        defer b.booksMutex.Unlock()
+
+        wanted := make(map[string]models.TradingPair, len(pairs))
+        for _, pair := range pairs {
+                wanted[pair.GetSymbol(b.name)] = pair
+        }
+        for _, pair := range b.pairs {
+                symbol := pair.GetSymbol(b.name)
+                if _, keep := wanted[symbol]; keep {
+                        continue
+                }
+                delete(b.orderBooks, symbol)
+                if b.store != nil {
+                        b.store.Remove(b.name, pair)
+                }
+                removed = append(removed, symbol)
+        }
+        for _, pair := range pairs {
+                symbol := pair.GetSymbol(b.name)
+                if _, exists := b.orderBooks[symbol]; exists {
+                        continue
+                }
+                b.orderBooks[symbol] = &models.OrderBook{
+                        Exchange:      b.name,
+                        Symbol:        symbol,
+                        BaseCurrency:  pair.BaseCurrency,
+                        QuoteCurrency: pair.QuoteCurrency,
+                }
+                added = append(added, symbol)
+        }
+        b.pairs = append([]models.TradingPair{}, pairs...)
+        return added, removed
+}
+
+// updateOrderBook applies new bid/ask levels (best first) to the book for a symbol
+// and publishes a snapshot of it to the shared orderbook store
+func (b *BaseExchange) updateOrderBook(symbol string, bids, asks []models.PriceLevel, store *models.OrderBookStore) bool {
+        if len(bids) == 0 || len(asks) == 0 {
+                return false
+        }
+
+        b.booksMutex.Lock()
+        book, exists := b.orderBooks[symbol]
+        if !exists {
+                b.booksMutex.Unlock()
+                return false
+        }
+        book.Bid = bids[0].Price
+        book.Ask = asks[0].Price
+        book.Bids = bids
+        book.Asks = asks
+        book.LastUpdate = b.clock.Now()
+        snapshot := *book
+
+        // Publish before unlocking, so a pair being removed cannot be published again after it
+        store.Update(snapshot)
+        b.booksMutex.Unlock()
+        return true
+}
+
+// parseLevel converts an exchange price/quantity string pair into a price level
+func parseLevel(price, quantity string) (models.PriceLevel, error) {
+        p, err := models.ParseFloat(price)
+        if err != nil {
+                return models.PriceLevel{}, fmt.Errorf("failed to parse price: %v", err)
+        }
+        q, err := models.ParseFloat(quantity)
+        if err != nil {
+                return models.PriceLevel{}, fmt.Errorf("failed to parse quantity: %v", err)
+        }
+        return models.PriceLevel{Price: p, Quantity: q}, nil
 }
@@ -4,6 +4,7 @@ import (
         "context"
         "encoding/json"
         "fmt"
+        "strings"
         "sync"
         "time"
 
@@ -16,14 +17,17 @@ import (
 // Kraken defines the Kraken exchange client
 type Kraken struct {
         BaseExchange
-        wsURL  string
-        conn   *websocket.Conn
-        symbol string
+        wsURL     string
+        conn      *websocket.Conn
+        connMutex sync.Mutex // Serializes writes, which also come from SetTradingPairs
+        requestID int
+        depth     map[string]*models.DepthBook // Keyed by pair name
 }
 
 // KrakenSubscription defines the structure for subscription message
 type KrakenSubscription struct {
-        Name string `json:"name"`
+        Name  string `json:"name"`
+        Depth int    `json:"depth,omitempty"`
 }
 
 // KrakenSubscribeMessage defines the structure for the subscription request
@@ -34,45 +38,36 @@ type KrakenSubscribeMessage struct {
         Subscribe KrakenSubscription `json:"subscription"`
 }
 
-// NewKraken creates a new Kraken exchange client
-func NewKraken(symbol string) (*Kraken, error) {
-        return &Kraken{
-                BaseExchange: BaseExchange{
-                        name:   "Kraken",
-                        symbol: symbol,
-                        orderBook: &models.OrderBook{
-                                Exchange: "Kraken",
-                                Symbol:   symbol,
-                        },
-                },
-                wsURL:  "wss://ws.kraken.com",
-                symbol: symbol,
-        }, nil
+// NewKraken creates a new Kraken exchange client streaming all given trading pairs
+func NewKraken(pairs []models.TradingPair) (*Kraken, error) {
+        if len(pairs) == 0 {
+                return nil, fmt.Errorf("at least one trading pair is required")
+        }
+
+        k := &Kraken{
+                wsURL: "wss://ws.kraken.com",
+        }
+        k.init("Kraken", pairs, 0.0026) // 0.26% is the default fee
+        return k, nil
 }
 
 // Connect establishes a websocket connection to Kraken and starts streaming order book data
-func (k *Kraken) Connect(ctx context.Context, orderBooks map[string]*models.OrderBook, mu *sync.RWMutex) {
-        var err error
-        
+func (k *Kraken) Connect(ctx context.Context, store *models.OrderBookStore) {
         log.Infof("[Kraken] Connecting to %s", k.wsURL)
         
         // Setup custom dialer with longer timeouts
         dialer := websocket.DefaultDialer
         dialer.HandshakeTimeout = 10 * time.Second
         
-        k.conn, _, err = dialer.Dial(k.wsURL, nil)
+        conn, _, err := dialer.Dial(k.wsURL, nil)
         if err != nil {
                 log.Errorf("[Kraken] Failed to connect to websocket: %v", err)
-                
-                // Initialize with some dummy data so the simulation can work
-                log.Warn("[Kraken] Using simulation mode for demonstration purposes")
-                k.orderBook.Bid = 69550.0
-                k.orderBook.Ask = 69650.0
-                k.orderBook.LastUpdate = time.Now()
-                updateOrderBookMap(k.Name(), k.orderBook, orderBooks, mu)
-                
                 return
         }
+        k.connMutex.Lock()
+        k.conn = conn
+        k.connMutex.Unlock()
+        k.attach(store)
         
         defer k.Close()
         
@@ -83,22 +78,20 @@ func (k *Kraken) Connect(ctx context.Context, orderBooks map[string]*models.Orde
                 k.Close()
         }()
         
-        // Subscribe to ticker data
-        subscribeMsg := KrakenSubscribeMessage{
-                Name:  "subscribe",
-                ReqID: 1,
-                Pairs: []string{k.symbol},
-                Subscribe: KrakenSubscription{
-                        Name: "ticker",
-                },
-        }
-        
-        if err := k.conn.WriteJSON(subscribeMsg); err != nil {
+        // Subscribe to ticker data for every pair in a single request
+        if err := k.subscribe("subscribe", k.symbols()); err != nil {
                 log.Errorf("[Kraken] Failed to send subscription request: %v", err)
                 return
         }
         
-        log.Infof("[Kraken] Subscribed to ticker for %s", k.symbol)
+        log.Infof("[Kraken] Subscribed to book for %s", strings.Join(k.symbols(), ", "))
+        
+        // Each subscription starts with a snapshot, so depth books start out empty
+        symbols := k.symbols()
+        k.depth = make(map[string]*models.DepthBook, len(symbols))
+        for _, symbol := range symbols {
+                k.depth[symbol] = models.NewDepthBook()
+        }
         
         // Process incoming messages
         for {
@@ -107,7 +100,7 @@ func (k *Kraken) Connect(ctx context.Context, orderBooks map[string]*models.Orde
                         return
                 default:
                         // Read message from websocket
-                        _, message, err := k.conn.ReadMessage()
+                        _, message, err := conn.ReadMessage()
                         if err != nil {
                                 log.Errorf("[Kraken] Error reading from websocket: %v", err)
                                 
@@ -115,12 +108,15 @@ func (k *Kraken) Connect(ctx context.Context, orderBooks map[string]*models.Orde
                                 if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
                                         time.Sleep(5 * time.Second)
                                         log.Info("[Kraken] Attempting to reconnect...")
-                                        k.Connect(ctx, orderBooks, mu)
+                                        k.Connect(ctx, store)
                                         return
                                 }
                                 return
                         }
 
+                        // Keep the raw frame for the tick recorder
+                        k.recordFrame(message)
+
                         // First try handling as a system message (which is an object, not an array)
                         var systemMsg map[string]interface{}
                         if err := json.Unmarshal(message, &systemMsg); err == nil {
@@ -138,90 +134,149 @@ func (k *Kraken) Connect(ctx context.Context, orderBooks map[string]*models.Orde
                                 continue
                         }
                         
-                        // Check if it's a ticker message
-                        if len(data) < 2 {
+                        // Check if it's a book message
+                        // Format is [channelID, data, ("book-N", pair)] where updates touching
+                        // both sides carry separate ask and bid objects before the channel name
+                        if len(data) < 4 {
                                 log.Debugf("[Kraken] Received non-data message with length %d", len(data))
                                 continue // Not enough data
                         }
                         
-                        channelName, ok := data[1].(string)
-                        if !ok || channelName != "ticker" {
-                                log.Debugf("[Kraken] Received non-ticker message: %v", data[1])
-                                continue // Not a ticker message
+                        channelName, ok := data[len(data)-2].(string)
+                        if !ok || !strings.HasPrefix(channelName, "book") {
+                                log.Debugf("[Kraken] Received non-book message: %v", data[len(data)-2])
+                                continue // Not a book message
                         }
                         
-                        // Try to extract ticker data
-                        // Format is [channelID, "ticker", data, pair]
-                        if len(data) < 3 {
-                                log.Debugf("[Kraken] Ticker message has incorrect format")
+                        pairName, ok := data[len(data)-1].(string)
+                        if !ok {
+                                log.Debugf("[Kraken] Book message has incorrect format")
                                 continue
                         }
                         
-                        tickerData, ok := data[2].(map[string]interface{})
-                        if !ok {
-                                log.Error("[Kraken] Invalid ticker data format")
+                        book, exists := k.depth[pairName]
+                        if !k.tracks(pairName) {
+                                delete(k.depth, pairName)
+                                log.Debugf("[Kraken] Received book for unknown pair %s", pairName)
                                 continue
                         }
+                        if !exists {
+                                // The pair was added while connected; its subscription starts with a snapshot
+                                book = models.NewDepthBook()
+                                k.depth[pairName] = book
+                        }
                         
-                        log.Debugf("[Kraken] Successfully received ticker data")
-                        
-                        // Extract bid/ask prices
-                        bid, ask, err := extractKrakenPrices(tickerData)
-                        if err != nil {
-                                log.Errorf("[Kraken] Error extracting prices: %v", err)
-                                continue
+                        for _, payload := range data[1 : len(data)-2] {
+                                bookData, ok := payload.(map[string]interface{})
+                                if !ok {
+                                        log.Error("[Kraken] Invalid book data format")
+                                        continue
+                                }
+                                if err := applyKrakenBook(book, bookData); err != nil {
+                                        log.Errorf("[Kraken] Error applying book data: %v", err)
+                                }
                         }
                         
-                        // Update the order book
-                        k.orderBook.Bid = bid
-                        k.orderBook.Ask = ask
-                        k.orderBook.LastUpdate = time.Now()
+                        // Kraken only maintains the subscribed depth, so drop levels pushed out of range
+                        book.Truncate(bookDepth)
+                        
+                        log.Debugf("[Kraken] Successfully applied book data for %s", pairName)
                         
-                        // Update the shared map
-                        updateOrderBookMap(k.Name(), k.orderBook, orderBooks, mu)
+                        // Update the order book for the pair and the shared store
+                        k.updateOrderBook(pairName, book.Bids(bookDepth), book.Asks(bookDepth), store)
                 }
         }
 }
 
-// extractKrakenPrices extracts bid and ask prices from Kraken ticker data
-func extractKrakenPrices(tickerData map[string]interface{}) (float64, float64, error) {
-        // Get best bid price
-        bidData, ok := tickerData["b"].([]interface{})
-        if !ok || len(bidData) < 1 {
-                return 0, 0, fmt.Errorf("invalid bid data format")
-        }
-        
-        bidStr, ok := bidData[0].(string)
-        if !ok {
-                return 0, 0, fmt.Errorf("bid price not a string")
+// applyKrakenBook applies a book snapshot ("as"/"bs") or update ("a"/"b") to a depth book
+func applyKrakenBook(book *models.DepthBook, bookData map[string]interface{}) error {
+        if _, isSnapshot := bookData["as"]; isSnapshot {
+                book.Reset()
         }
         
-        bid, err := models.ParseFloat(bidStr)
-        if err != nil {
-                return 0, 0, fmt.Errorf("failed to parse bid price: %v", err)
+        for key, levels := range bookData {
+                var update func(price, quantity float64)
+                switch key {
+                case "a", "as":
+                        update = book.UpdateAsk
+                case "b", "bs":
+                        update = book.UpdateBid
+                default:
+                        continue // Checksum and other metadata
+                }
+                
+                entries, ok := levels.([]interface{})
+                if !ok {
+                        return fmt.Errorf("invalid %s levels format", key)
+                }
+                
+                // Each entry is [price, volume, timestamp] with an optional republish flag
+                for _, entry := range entries {
+                        fields, ok := entry.([]interface{})
+                        if !ok || len(fields) < 2 {
+                                return fmt.Errorf("invalid %s level format", key)
+                        }
+                        price, okPrice := fields[0].(string)
+                        volume, okVolume := fields[1].(string)
+                        if !okPrice || !okVolume {
+                                return fmt.Errorf("%s level is not a string pair", key)
+                        }
+                        level, err := parseLevel(price, volume)
+                        if err != nil {
+                                return err
+                        }
+                        update(level.Price, level.Quantity)
+                }
         }
         
-        // Get best ask price
-        askData, ok := tickerData["a"].([]interface{})
-        if !ok || len(askData) < 1 {
-                return 0, 0, fmt.Errorf("invalid ask data format")
+        return nil
+}
+
+// SetTradingPairs changes the streamed trading pairs, subscribing to the books of new pairs
+// and unsubscribing from removed ones on the open connection
+func (k *Kraken) SetTradingPairs(pairs []models.TradingPair) {
+        added, removed := k.setPairs(pairs)
+        if len(removed) > 0 {
+                if err := k.subscribe("unsubscribe", removed); err != nil {
+                        log.Errorf("[Kraken] Failed to unsubscribe from %s: %v", strings.Join(removed, ", "), err)
+                } else {
+                        log.Infof("[Kraken] Unsubscribed from book for %s", strings.Join(removed, ", "))
+                }
         }
-        
-        askStr, ok := askData[0].(string)
-        if !ok {
-                return 0, 0, fmt.Errorf("ask price not a string")
+        if len(added) > 0 {
+                if err := k.subscribe("subscribe", added); err != nil {
+                        log.Errorf("[Kraken] Failed to subscribe to %s: %v", strings.Join(added, ", "), err)
+                } else {
+                        log.Infof("[Kraken] Subscribed to book for %s", strings.Join(added, ", "))
+                }
         }
-        
-        ask, err := models.ParseFloat(askStr)
-        if err != nil {
-                return 0, 0, fmt.Errorf("failed to parse ask price: %v", err)
+}
+
+// subscribe sends a subscribe or unsubscribe request for the books of the pairs. Without a
+// connection it does nothing, since connecting subscribes to every pair.
+func (k *Kraken) subscribe(name string, pairs []string) error {
+        k.connMutex.Lock()
+        defer k.connMutex.Unlock()
+        if k.conn == nil {
+                return nil
         }
         
-        return bid, ask, nil
+        k.requestID++
+        return k.conn.WriteJSON(KrakenSubscribeMessage{
+                Name:  name,
+                ReqID: k.requestID,
+                Pairs: pairs,
+                Subscribe: KrakenSubscription{
+                        Name:  "book",
+                        Depth: bookDepth,
+                },
+        })
 }
 
 // Close closes the websocket connection
 func (k *Kraken) Close() error {
+        k.connMutex.Lock()
+        defer k.connMutex.Unlock()
         if k.conn != nil {
                 return k.conn.Close()
         }
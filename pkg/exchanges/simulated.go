@@ -0,0 +1,92 @@
+package exchanges
+
+import (
+        "context"
+        "strings"
+        "sync"
+
+        "apex-arbitrage/pkg/models"
+        "apex-arbitrage/pkg/simulator"
+
+        log "github.com/sirupsen/logrus"
+)
+
+// Simulated defines an exchange client that quotes synthetic order books from a
+// shared market simulator instead of connecting to a real venue
+type Simulated struct {
+        BaseExchange
+        sim  *simulator.Simulator
+        mu   sync.Mutex
+        stop context.CancelFunc
+}
+
+// NewSimulated creates a simulated exchange client that publishes books under the given
+// exchange name, so fees and detection treat it like the real venue
+func NewSimulated(name string, sim *simulator.Simulator, pairs []models.TradingPair, takerFee float64) (*Simulated, error) {
+        s := &Simulated{
+                sim: sim,
+        }
+        s.init(name, pairs, takerFee)
+        return s, nil
+}
+
+// Connect starts publishing simulated order book updates until the context is cancelled
+func (s *Simulated) Connect(ctx context.Context, store *models.OrderBookStore) {
+        ctx, cancel := context.WithCancel(ctx)
+        s.mu.Lock()
+        s.stop = cancel
+        s.mu.Unlock()
+        defer cancel()
+        s.attach(store)
+
+        log.Infof("[%s] Streaming simulated data for %s (scenario %s)", s.Name(), strings.Join(s.symbols(), ", "), s.sim.Scenario().Name)
+
+        ticker := s.clock.NewTicker(s.sim.Scenario().TickInterval)
+        defer ticker.Stop()
+
+        inOutage := false
+        for {
+                select {
+                case <-ctx.Done():
+                        log.Infof("[%s] Simulation stopped", s.Name())
+                        return
+                case now := <-ticker.C():
+                        for _, pair := range s.GetTradingPairs() {
+                                bids, asks, ok := s.sim.Quote(s.name, pair, now)
+                                if !ok {
+                                        if !inOutage {
+                                                log.Warnf("[%s] Simulated outage started", s.Name())
+                                                inOutage = true
+                                        }
+                                        break
+                                }
+                                if inOutage {
+                                        log.Infof("[%s] Simulated outage ended", s.Name())
+                                        inOutage = false
+                                }
+                                s.updateOrderBook(pair.GetSymbol(s.name), bids, asks, store)
+                        }
+                }
+        }
+}
+
+// SetTradingPairs changes the trading pairs quoted from the next tick on
+func (s *Simulated) SetTradingPairs(pairs []models.TradingPair) {
+        added, removed := s.setPairs(pairs)
+        if len(removed) > 0 {
+                log.Infof("[%s] Stopped simulating %s", s.Name(), strings.Join(removed, ", "))
+        }
+        if len(added) > 0 {
+                log.Infof("[%s] Simulating %s", s.Name(), strings.Join(added, ", "))
+        }
+}
+
+// Close stops generating simulated data
+func (s *Simulated) Close() error {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        if s.stop != nil {
+                s.stop()
+        }
+        return nil
+}
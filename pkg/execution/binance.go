@@ -0,0 +1,251 @@
+package execution
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// BinanceRESTURL is the production Binance REST endpoint
+const BinanceRESTURL = "https://api.binance.com"
+
+// Binance places orders through the Binance spot REST API
+// @author VrushankPatel
+// @description Signs requests with HMAC-SHA256 of the query string as Binance requires
+type Binance struct {
+	baseURL    string
+	apiKey     string
+	apiSecret  string
+	client     *http.Client
+	increments incrementCache
+}
+
+// binanceOrder is an order as returned by the Binance REST API
+type binanceOrder struct {
+	OrderID             int64  `json:"orderId"`
+	ClientOrderID       string `json:"clientOrderId"`
+	Status              string `json:"status"`
+	OrigQty             string `json:"origQty"`
+	ExecutedQty         string `json:"executedQty"`
+	CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+}
+
+// NewBinance creates a Binance venue
+// @author VrushankPatel
+// @description Creates a venue that sends orders to baseURL, BinanceRESTURL when empty
+// @param baseURL The REST base URL
+// @param apiKey The API key
+// @param apiSecret The API secret
+// @return A pointer to the newly created Binance venue
+func NewBinance(baseURL, apiKey, apiSecret string) *Binance {
+	if baseURL == "" {
+		baseURL = BinanceRESTURL
+	}
+	return &Binance{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		client:    newHTTPClient(),
+	}
+}
+
+// Name returns the exchange name
+func (b *Binance) Name() string {
+	return "Binance"
+}
+
+// PlaceOrder sends a new order
+func (b *Binance) PlaceOrder(ctx context.Context, req OrderRequest) (Order, error) {
+	params := url.Values{}
+	params.Set("symbol", req.Pair.GetSymbol("Binance"))
+	params.Set("side", strings.ToUpper(req.Side))
+	params.Set("quantity", formatAmount(req.Quantity, req.Increments.Quantity))
+	params.Set("newClientOrderId", req.ClientID)
+	params.Set("newOrderRespType", "RESULT")
+	if req.Type == TypeMarket {
+		params.Set("type", "MARKET")
+	} else {
+		params.Set("type", "LIMIT")
+		params.Set("timeInForce", "IOC")
+		params.Set("price", formatAmount(req.Price, req.Increments.Price))
+	}
+	return b.orderRequest(ctx, http.MethodPost, params)
+}
+
+// GetOrder returns the current state of an order
+func (b *Binance) GetOrder(ctx context.Context, pair models.TradingPair, id string) (Order, error) {
+	params := url.Values{}
+	params.Set("symbol", pair.GetSymbol("Binance"))
+	params.Set("orderId", id)
+	return b.orderRequest(ctx, http.MethodGet, params)
+}
+
+// GetOrderByClientID returns the current state of the order placed with a client id
+func (b *Binance) GetOrderByClientID(ctx context.Context, pair models.TradingPair, clientID string) (Order, error) {
+	params := url.Values{}
+	params.Set("symbol", pair.GetSymbol("Binance"))
+	params.Set("origClientOrderId", clientID)
+	order, err := b.orderRequest(ctx, http.MethodGet, params)
+	if err != nil && strings.Contains(err.Error(), "Order does not exist") {
+		return Order{}, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// CancelOrder cancels an order
+func (b *Binance) CancelOrder(ctx context.Context, pair models.TradingPair, id string) error {
+	params := url.Values{}
+	params.Set("symbol", pair.GetSymbol("Binance"))
+	params.Set("orderId", id)
+	_, err := b.orderRequest(ctx, http.MethodDelete, params)
+	if err != nil && strings.Contains(err.Error(), "Unknown order") {
+		// The order was already done
+		return nil
+	}
+	return err
+}
+
+// Increments returns the tick size and lot step of a pair from its exchange filters
+func (b *Binance) Increments(ctx context.Context, pair models.TradingPair) (Increments, error) {
+	symbol := pair.GetSymbol("Binance")
+	return b.increments.get(symbol, func() (Increments, error) {
+		params := url.Values{}
+		params.Set("symbol", symbol)
+		var resp struct {
+			Symbols []struct {
+				Symbol  string `json:"symbol"`
+				Filters []struct {
+					FilterType string `json:"filterType"`
+					TickSize   string `json:"tickSize"`
+					StepSize   string `json:"stepSize"`
+				} `json:"filters"`
+			} `json:"symbols"`
+		}
+		if err := b.keyed(ctx, http.MethodGet, "/api/v3/exchangeInfo", params, &resp); err != nil {
+			return Increments{}, err
+		}
+		for _, info := range resp.Symbols {
+			if info.Symbol != symbol {
+				continue
+			}
+			increments := Increments{}
+			for _, filter := range info.Filters {
+				switch filter.FilterType {
+				case "PRICE_FILTER":
+					increments.Price = parseAmount(filter.TickSize)
+				case "LOT_SIZE":
+					increments.Quantity = parseAmount(filter.StepSize)
+				}
+			}
+			return increments, nil
+		}
+		return Increments{}, fmt.Errorf("symbol %s not listed", symbol)
+	})
+}
+
+// Balances returns the free balance of every asset in the account and the time of the last
+// account update they reflect
+func (b *Binance) Balances(ctx context.Context) (map[string]float64, time.Time, error) {
+	var resp struct {
+		UpdateTime int64 `json:"updateTime"`
+		Balances   []struct {
+			Asset string `json:"asset"`
+			Free  string `json:"free"`
+		} `json:"balances"`
+	}
+	if err := b.signed(ctx, http.MethodGet, "/api/v3/account", url.Values{}, &resp); err != nil {
+		return nil, time.Time{}, err
+	}
+	balances := make(map[string]float64, len(resp.Balances))
+	for _, balance := range resp.Balances {
+		balances[balance.Asset] = parseAmount(balance.Free)
+	}
+	return balances, time.UnixMilli(resp.UpdateTime), nil
+}
+
+// ListenKey creates a user data stream and returns its listen key
+func (b *Binance) ListenKey(ctx context.Context) (string, error) {
+	var resp struct {
+		ListenKey string `json:"listenKey"`
+	}
+	if err := b.keyed(ctx, http.MethodPost, "/api/v3/userDataStream", url.Values{}, &resp); err != nil {
+		return "", err
+	}
+	return resp.ListenKey, nil
+}
+
+// KeepAliveListenKey extends the validity of a user data stream by 60 minutes
+func (b *Binance) KeepAliveListenKey(ctx context.Context, listenKey string) error {
+	params := url.Values{}
+	params.Set("listenKey", listenKey)
+	return b.keyed(ctx, http.MethodPut, "/api/v3/userDataStream", params, nil)
+}
+
+// orderRequest sends a signed request to the order endpoint and normalizes the returned order
+func (b *Binance) orderRequest(ctx context.Context, method string, params url.Values) (Order, error) {
+	var resp binanceOrder
+	if err := b.signed(ctx, method, "/api/v3/order", params, &resp); err != nil {
+		return Order{}, err
+	}
+
+	order := Order{
+		ID:             strconv.FormatInt(resp.OrderID, 10),
+		ClientID:       resp.ClientOrderID,
+		Quantity:       parseAmount(resp.OrigQty),
+		FilledQuantity: parseAmount(resp.ExecutedQty),
+	}
+	if order.FilledQuantity > 0 {
+		order.AvgPrice = parseAmount(resp.CummulativeQuoteQty) / order.FilledQuantity
+	}
+	switch resp.Status {
+	case "FILLED":
+		order.Status = OrderFilled
+	case "PARTIALLY_FILLED":
+		order.Status = OrderPartiallyFilled
+	case "CANCELED", "EXPIRED", "EXPIRED_IN_MATCH":
+		order.Status = OrderCanceled
+	case "REJECTED":
+		order.Status = OrderRejected
+	default:
+		order.Status = OrderNew
+	}
+	return order, nil
+}
+
+// signed sends a request signed with the API secret; the signature must follow the signed query
+func (b *Binance) signed(ctx context.Context, method, path string, params url.Values, out interface{}) error {
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	query := params.Encode()
+	mac := hmac.New(sha256.New, []byte(b.apiSecret))
+	mac.Write([]byte(query))
+	query += "&signature=" + hex.EncodeToString(mac.Sum(nil))
+	return b.send(ctx, method, b.baseURL+path+"?"+query, out)
+}
+
+// keyed sends a request authenticated with the API key only
+func (b *Binance) keyed(ctx context.Context, method, path string, params url.Values, out interface{}) error {
+	target := b.baseURL + path
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+	return b.send(ctx, method, target, out)
+}
+
+// send sends a request carrying the API key header
+func (b *Binance) send(ctx context.Context, method, target string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-MBX-APIKEY", b.apiKey)
+	return do(b.client, req, out)
+}
@@ -0,0 +1,154 @@
+package execution
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// binanceServer is a mock Binance REST API that checks the API key and request signatures
+type binanceServer struct {
+	t         *testing.T
+	secret    string
+	orders    []string // Query strings of the orders placed
+	infoCalls int
+}
+
+func (s *binanceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-MBX-APIKEY") != "key" {
+		http.Error(w, `{"code":-2014,"msg":"API-key format invalid."}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/v3/exchangeInfo":
+		s.infoCalls++
+		w.Write([]byte(`{"symbols":[{"symbol":"BTCUSDT","filters":[
+			{"filterType":"PRICE_FILTER","minPrice":"0.01000000","tickSize":"0.01000000"},
+			{"filterType":"LOT_SIZE","minQty":"0.00001000","stepSize":"0.00001000"}]}]}`))
+		return
+	case "/api/v3/order":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	query, signature, signed := strings.Cut(r.URL.RawQuery, "&signature=")
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte(query))
+	if !signed || signature != hex.EncodeToString(mac.Sum(nil)) || r.URL.Query().Get("timestamp") == "" {
+		http.Error(w, `{"code":-1022,"msg":"Signature for this request is not valid."}`, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.orders = append(s.orders, query)
+		w.Write([]byte(`{"orderId":42,"clientOrderId":"` + r.URL.Query().Get("newClientOrderId") + `","status":"FILLED",
+			"origQty":"0.50000000","executedQty":"0.50000000","cummulativeQuoteQty":"35000.00000000"}`))
+	case http.MethodGet:
+		w.Write([]byte(`{"orderId":42,"clientOrderId":"apex-1","status":"PARTIALLY_FILLED",
+			"origQty":"0.50000000","executedQty":"0.20000000","cummulativeQuoteQty":"14000.00000000"}`))
+	case http.MethodDelete:
+		http.Error(w, `{"code":-2011,"msg":"Unknown order sent."}`, http.StatusBadRequest)
+	}
+}
+
+func TestBinancePlaceOrderSignsAndNormalizes(t *testing.T) {
+	mock := &binanceServer{t: t, secret: "secret"}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	venue := NewBinance(server.URL, "key", "secret")
+	pair := models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"}
+	order, err := venue.PlaceOrder(context.Background(), OrderRequest{
+		Pair:       pair,
+		Side:       SideBuy,
+		Type:       TypeLimit,
+		Quantity:   0.5,
+		Price:      70000.12,
+		ClientID:   "apex-1",
+		Increments: Increments{Price: 0.01, Quantity: 0.00001},
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	if order.ID != "42" || order.ClientID != "apex-1" || order.Status != OrderFilled {
+		t.Errorf("unexpected order %+v", order)
+	}
+	if order.FilledQuantity != 0.5 || order.AvgPrice != 70000 {
+		t.Errorf("filled %v at %v, want 0.5 at 70000", order.FilledQuantity, order.AvgPrice)
+	}
+
+	if len(mock.orders) != 1 {
+		t.Fatalf("placed %d orders, want 1", len(mock.orders))
+	}
+	for _, param := range []string{"symbol=BTCUSDT", "side=BUY", "type=LIMIT", "timeInForce=IOC", "quantity=0.50000", "price=70000.12"} {
+		if !strings.Contains(mock.orders[0], param) {
+			t.Errorf("order %q is missing %s", mock.orders[0], param)
+		}
+	}
+}
+
+func TestBinanceRejectsBadSignature(t *testing.T) {
+	server := httptest.NewServer(&binanceServer{t: t, secret: "secret"})
+	defer server.Close()
+
+	venue := NewBinance(server.URL, "key", "wrong")
+	_, err := venue.GetOrder(context.Background(), models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"}, "42")
+	if err == nil || !strings.Contains(err.Error(), "Signature") {
+		t.Fatalf("expected a signature error, got %v", err)
+	}
+}
+
+func TestBinanceGetAndCancelOrder(t *testing.T) {
+	server := httptest.NewServer(&binanceServer{t: t, secret: "secret"})
+	defer server.Close()
+
+	venue := NewBinance(server.URL, "key", "secret")
+	pair := models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"}
+	order, err := venue.GetOrder(context.Background(), pair, "42")
+	if err != nil {
+		t.Fatalf("GetOrder failed: %v", err)
+	}
+	if order.Status != OrderPartiallyFilled || order.FilledQuantity != 0.2 || order.AvgPrice != 70000 {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	// Canceling an order that is already done is not an error
+	if err := venue.CancelOrder(context.Background(), pair, "42"); err != nil {
+		t.Errorf("CancelOrder of a done order failed: %v", err)
+	}
+}
+
+func TestBinanceIncrements(t *testing.T) {
+	mock := &binanceServer{t: t, secret: "secret"}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	venue := NewBinance(server.URL, "key", "secret")
+	pair := models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"}
+	for i := 0; i < 2; i++ {
+		increments, err := venue.Increments(context.Background(), pair)
+		if err != nil {
+			t.Fatalf("Increments failed: %v", err)
+		}
+		if increments != (Increments{Price: 0.01, Quantity: 0.00001}) {
+			t.Errorf("increments %+v, want tick 0.01 and step 0.00001", increments)
+		}
+	}
+	if mock.infoCalls != 1 {
+		t.Errorf("exchangeInfo requested %d times, want once", mock.infoCalls)
+	}
+
+	if _, err := venue.Increments(context.Background(), models.TradingPair{BaseCurrency: "ETH", QuoteCurrency: "USDT"}); err == nil {
+		t.Error("expected an error for a symbol that is not listed")
+	}
+}
@@ -0,0 +1,210 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// CoinbaseRESTURL is the production Coinbase Exchange REST endpoint
+const CoinbaseRESTURL = "https://api.exchange.coinbase.com"
+
+// Coinbase places orders through the Coinbase Exchange REST API
+// @author VrushankPatel
+// @description Signs requests with HMAC-SHA256 of timestamp, method, path and body, keyed with
+// the base64-decoded secret, and sends the API passphrase, as Coinbase Exchange requires
+type Coinbase struct {
+	baseURL    string
+	apiKey     string
+	apiSecret  string
+	passphrase string
+	client     *http.Client
+	increments incrementCache
+}
+
+// coinbaseOrder is an order as returned by the Coinbase Exchange REST API
+type coinbaseOrder struct {
+	ID            string `json:"id"`
+	ClientOID     string `json:"client_oid"`
+	Status        string `json:"status"`
+	DoneReason    string `json:"done_reason"`
+	Size          string `json:"size"`
+	FilledSize    string `json:"filled_size"`
+	ExecutedValue string `json:"executed_value"`
+}
+
+// NewCoinbase creates a Coinbase venue
+// @author VrushankPatel
+// @description Creates a venue that sends orders to baseURL, CoinbaseRESTURL when empty
+// @param baseURL The REST base URL
+// @param apiKey The API key
+// @param apiSecret The base64-encoded API secret
+// @param passphrase The API passphrase
+// @return A pointer to the newly created Coinbase venue
+func NewCoinbase(baseURL, apiKey, apiSecret, passphrase string) *Coinbase {
+	if baseURL == "" {
+		baseURL = CoinbaseRESTURL
+	}
+	return &Coinbase{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		apiSecret:  apiSecret,
+		passphrase: passphrase,
+		client:     newHTTPClient(),
+	}
+}
+
+// Name returns the exchange name
+func (c *Coinbase) Name() string {
+	return "Coinbase"
+}
+
+// PlaceOrder sends a new order
+func (c *Coinbase) PlaceOrder(ctx context.Context, req OrderRequest) (Order, error) {
+	body := map[string]string{
+		"product_id": req.Pair.GetSymbol("Coinbase"),
+		"side":       req.Side,
+		"size":       formatAmount(req.Quantity, req.Increments.Quantity),
+		"client_oid": req.ClientID,
+	}
+	if req.Type == TypeMarket {
+		body["type"] = "market"
+	} else {
+		body["type"] = "limit"
+		body["time_in_force"] = "IOC"
+		body["price"] = formatAmount(req.Price, req.Increments.Price)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return Order{}, err
+	}
+
+	var resp coinbaseOrder
+	if err := c.request(ctx, http.MethodPost, "/orders", payload, &resp); err != nil {
+		return Order{}, err
+	}
+	return resp.normalize(), nil
+}
+
+// GetOrder returns the current state of an order
+func (c *Coinbase) GetOrder(ctx context.Context, pair models.TradingPair, id string) (Order, error) {
+	var resp coinbaseOrder
+	if err := c.request(ctx, http.MethodGet, "/orders/"+id, nil, &resp); err != nil {
+		return Order{}, err
+	}
+	return resp.normalize(), nil
+}
+
+// GetOrderByClientID returns the current state of the order placed with a client id
+func (c *Coinbase) GetOrderByClientID(ctx context.Context, pair models.TradingPair, clientID string) (Order, error) {
+	var resp coinbaseOrder
+	err := c.request(ctx, http.MethodGet, "/orders/client:"+clientID, nil, &resp)
+	if err != nil && strings.Contains(err.Error(), "HTTP 404") {
+		return Order{}, ErrOrderNotFound
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	return resp.normalize(), nil
+}
+
+// CancelOrder cancels an order
+func (c *Coinbase) CancelOrder(ctx context.Context, pair models.TradingPair, id string) error {
+	err := c.request(ctx, http.MethodDelete, "/orders/"+id, nil, nil)
+	if err != nil && (strings.Contains(err.Error(), "Order already done") || strings.Contains(err.Error(), "HTTP 404")) {
+		// The order was already done
+		return nil
+	}
+	return err
+}
+
+// Increments returns the quote and base increments of a pair's product
+func (c *Coinbase) Increments(ctx context.Context, pair models.TradingPair) (Increments, error) {
+	product := pair.GetSymbol("Coinbase")
+	return c.increments.get(product, func() (Increments, error) {
+		var resp struct {
+			QuoteIncrement string `json:"quote_increment"`
+			BaseIncrement  string `json:"base_increment"`
+		}
+		if err := c.request(ctx, http.MethodGet, "/products/"+product, nil, &resp); err != nil {
+			return Increments{}, err
+		}
+		return Increments{Price: parseAmount(resp.QuoteIncrement), Quantity: parseAmount(resp.BaseIncrement)}, nil
+	})
+}
+
+// Balances returns the available balance of every currency in the profile
+func (c *Coinbase) Balances(ctx context.Context) (map[string]float64, error) {
+	var resp []struct {
+		Currency  string `json:"currency"`
+		Available string `json:"available"`
+	}
+	if err := c.request(ctx, http.MethodGet, "/accounts", nil, &resp); err != nil {
+		return nil, err
+	}
+	balances := make(map[string]float64, len(resp))
+	for _, account := range resp {
+		balances[account.Currency] = parseAmount(account.Available)
+	}
+	return balances, nil
+}
+
+// request sends a signed request
+func (c *Coinbase) request(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	secret, err := base64.StdEncoding.DecodeString(c.apiSecret)
+	if err != nil {
+		return fmt.Errorf("invalid Coinbase API secret: %v", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + method + path + string(body)))
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("CB-ACCESS-KEY", c.apiKey)
+	req.Header.Set("CB-ACCESS-SIGN", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("CB-ACCESS-TIMESTAMP", timestamp)
+	req.Header.Set("CB-ACCESS-PASSPHRASE", c.passphrase)
+	return do(c.client, req, out)
+}
+
+// normalize converts a Coinbase order to an Order
+func (o coinbaseOrder) normalize() Order {
+	order := Order{
+		ID:             o.ID,
+		ClientID:       o.ClientOID,
+		Quantity:       parseAmount(o.Size),
+		FilledQuantity: parseAmount(o.FilledSize),
+	}
+	if order.FilledQuantity > 0 {
+		order.AvgPrice = parseAmount(o.ExecutedValue) / order.FilledQuantity
+	}
+	switch o.Status {
+	case "done":
+		order.Status = OrderCanceled
+		if o.DoneReason == "filled" {
+			order.Status = OrderFilled
+		}
+	case "rejected":
+		order.Status = OrderRejected
+	default:
+		order.Status = OrderNew
+		if order.FilledQuantity > 0 {
+			order.Status = OrderPartiallyFilled
+		}
+	}
+	return order
+}
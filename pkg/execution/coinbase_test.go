@@ -0,0 +1,138 @@
+package execution
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// coinbaseSecret is a base64-encoded API secret, as Coinbase issues them
+var coinbaseSecret = base64.StdEncoding.EncodeToString([]byte("coinbase-secret"))
+
+// coinbaseServer is a mock Coinbase Exchange REST API that checks the API key, passphrase and
+// request signatures
+type coinbaseServer struct {
+	t      *testing.T
+	orders []map[string]string // Bodies of the orders placed
+}
+
+func (s *coinbaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	secret, _ := base64.StdEncoding.DecodeString(coinbaseSecret)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(r.Header.Get("CB-ACCESS-TIMESTAMP") + r.Method + r.URL.Path + string(body)))
+	if r.Header.Get("CB-ACCESS-KEY") != "key" || r.Header.Get("CB-ACCESS-PASSPHRASE") != "phrase" ||
+		r.Header.Get("CB-ACCESS-SIGN") != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		http.Error(w, `{"message":"invalid signature"}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/products/BTC-USD":
+		w.Write([]byte(`{"id":"BTC-USD","quote_increment":"0.01","base_increment":"0.00000001"}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/orders":
+		var order map[string]string
+		json.Unmarshal(body, &order)
+		s.orders = append(s.orders, order)
+		w.Write([]byte(`{"id":"c0ffee","client_oid":"` + order["client_oid"] + `","status":"pending","size":"` + order["size"] + `","filled_size":"0","executed_value":"0"}`))
+	case r.Method == http.MethodGet && r.URL.Path == "/orders/c0ffee":
+		w.Write([]byte(`{"id":"c0ffee","status":"done","done_reason":"canceled","size":"0.5","filled_size":"0.2","executed_value":"14000"}`))
+	case r.Method == http.MethodDelete:
+		http.Error(w, `{"message":"Order already done"}`, http.StatusBadRequest)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestCoinbasePlaceOrderSignsAndNormalizes(t *testing.T) {
+	mock := &coinbaseServer{t: t}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	venue := NewCoinbase(server.URL, "key", coinbaseSecret, "phrase")
+	order, err := venue.PlaceOrder(context.Background(), OrderRequest{
+		Pair:       models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USD"},
+		Side:       SideBuy,
+		Type:       TypeLimit,
+		Quantity:   0.5,
+		Price:      70000.25,
+		ClientID:   "apex-1",
+		Increments: Increments{Price: 0.01, Quantity: 0.00000001},
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	if order.ID != "c0ffee" || order.Status != OrderNew || order.Quantity != 0.5 {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	if len(mock.orders) != 1 {
+		t.Fatalf("placed %d orders, want 1", len(mock.orders))
+	}
+	for field, want := range map[string]string{
+		"product_id":    "BTC-USD",
+		"side":          "buy",
+		"type":          "limit",
+		"time_in_force": "IOC",
+		"size":          "0.50000000",
+		"price":         "70000.25",
+	} {
+		if mock.orders[0][field] != want {
+			t.Errorf("%s = %q, want %q", field, mock.orders[0][field], want)
+		}
+	}
+}
+
+func TestCoinbaseRejectsBadSignature(t *testing.T) {
+	server := httptest.NewServer(&coinbaseServer{t: t})
+	defer server.Close()
+
+	venue := NewCoinbase(server.URL, "key", base64.StdEncoding.EncodeToString([]byte("wrong")), "phrase")
+	_, err := venue.GetOrder(context.Background(), models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USD"}, "c0ffee")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Fatalf("expected an authentication error, got %v", err)
+	}
+}
+
+func TestCoinbaseGetAndCancelOrder(t *testing.T) {
+	server := httptest.NewServer(&coinbaseServer{t: t})
+	defer server.Close()
+
+	venue := NewCoinbase(server.URL, "key", coinbaseSecret, "phrase")
+	pair := models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USD"}
+	order, err := venue.GetOrder(context.Background(), pair, "c0ffee")
+	if err != nil {
+		t.Fatalf("GetOrder failed: %v", err)
+	}
+	if order.Status != OrderCanceled || order.FilledQuantity != 0.2 || order.AvgPrice != 70000 {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	// Canceling an order that is already done is not an error
+	if err := venue.CancelOrder(context.Background(), pair, "c0ffee"); err != nil {
+		t.Errorf("CancelOrder of a done order failed: %v", err)
+	}
+}
+
+func TestCoinbaseIncrements(t *testing.T) {
+	server := httptest.NewServer(&coinbaseServer{t: t})
+	defer server.Close()
+
+	venue := NewCoinbase(server.URL, "key", coinbaseSecret, "phrase")
+	increments, err := venue.Increments(context.Background(), models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USD"})
+	if err != nil {
+		t.Fatalf("Increments failed: %v", err)
+	}
+	if increments != (Increments{Price: 0.01, Quantity: 0.00000001}) {
+		t.Errorf("increments %+v, want tick 0.01 and step 0.00000001", increments)
+	}
+}
@@ -0,0 +1,602 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-arbitrage/pkg/clock"
+	"apex-arbitrage/pkg/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxExecutions is the number of executions kept for Executions
+	maxExecutions = 100
+
+	// fillTolerance is the relative difference below which two quantities count as equal
+	fillTolerance = 1e-6
+
+	// dust is the smallest open position worth reporting
+	dust = 1e-9
+)
+
+// Execution states
+const (
+	StatePlacing   = "placing"   // Sending both legs
+	StateWorking   = "working"   // Waiting for the legs to fill, cancelling them at the timeout
+	StateHedging   = "hedging"   // The legs filled different quantities; unwinding the difference
+	StateCompleted = "completed" // Both legs filled the same quantity
+	StateHedged    = "hedged"    // The hanging quantity was unwound
+	StateAborted   = "aborted"   // Neither leg filled anything
+	StateFailed    = "failed"    // An unhedged position may remain and needs manual attention
+)
+
+// transitions lists the states each state may move to; states without an entry are final
+var transitions = map[string][]string{
+	StatePlacing: {StateWorking, StateAborted},
+	StateWorking: {StateCompleted, StateHedging, StateAborted, StateFailed},
+	StateHedging: {StateHedged, StateFailed},
+}
+
+// Leg roles
+const (
+	RoleBuy   = "buy"
+	RoleSell  = "sell"
+	RoleHedge = "hedge"
+)
+
+// Config holds the executor settings
+// @author VrushankPatel
+// @description Timeouts apply to each leg separately; a leg still working at its timeout is canceled
+type Config struct {
+	// How long a leg may work before it is canceled
+	LegTimeout time.Duration
+	// How often working orders are polled
+	PollInterval time.Duration
+	// How far beyond the detected prices the limit prices of the legs may be, in basis points
+	MaxSlippageBps float64
+	// Taker fee rate per exchange as a decimal, charged on the value of every filled leg
+	Fees map[string]float64
+}
+
+// Leg is one order of an execution
+// @author VrushankPatel
+// @description Error is set when the order could not be placed or tracked
+type Leg struct {
+	Role     string  `json:"role"`
+	Exchange string  `json:"exchange"`
+	Side     string  `json:"side"`
+	Type     string  `json:"type"`
+	Quantity float64 `json:"quantity"`
+	Price    float64 `json:"price,omitempty"`
+	Order    Order   `json:"order"`
+	Error    string  `json:"error,omitempty"`
+
+	increments Increments // Of the pair on the leg's exchange
+	unknown    bool       // The order may have been placed, but the exchange could not tell
+}
+
+// Transition records when an execution entered a state
+type Transition struct {
+	State string    `json:"state"`
+	At    time.Time `json:"at"`
+}
+
+// Execution is the two-leg execution of one arbitrage opportunity
+// @author VrushankPatel
+// @description Moves from StatePlacing through StateWorking to a final state, via StateHedging when
+// the legs filled different quantities. Once completed or hedged, GrossPnL is the quote currency
+// received minus spent over all legs and NetPnL is GrossPnL less the taker fees. A failed execution
+// has no PnL; OpenPosition holds the base currency it left bought (positive) or sold (negative)
+// per exchange instead.
+type Execution struct {
+	ID            int                `json:"id"`
+	OpportunityID string             `json:"opportunity_id"`
+	BaseCurrency  string             `json:"base_currency"`
+	QuoteCurrency string             `json:"quote_currency"`
+	State         string             `json:"state"`
+	History       []Transition       `json:"history"`
+	Legs          []*Leg             `json:"legs"`
+	GrossPnL      float64            `json:"gross_pnl"`
+	Fees          float64            `json:"fees"`
+	NetPnL        float64            `json:"net_pnl"`
+	OpenPosition  map[string]float64 `json:"open_position,omitempty"`
+	Error         string             `json:"error,omitempty"`
+	StartedAt     time.Time          `json:"started_at"`
+	FinishedAt    time.Time          `json:"finished_at"`
+}
+
+// Executor places both legs of arbitrage opportunities on the exchanges
+// @author VrushankPatel
+// @description Registered as an opportunity handler, it executes newly opened cross-exchange
+// opportunities one at a time: both legs are sent at once as immediate-or-cancel limit orders,
+// polled until done or canceled at the timeout, and any difference in filled quantity is unwound
+// with a market order on the exchange that filled more. Opportunities arriving while an execution
+// is running are skipped.
+type Executor struct {
+	venues map[string]Venue
+	cfg    Config
+	clock  clock.Clock
+	queue  chan models.ArbitrageOpportunity
+	onPnL  func(pnl float64)
+	onSkip func(opp models.ArbitrageOpportunity)
+	onFail func(exec Execution)
+
+	mu         sync.Mutex
+	fees       map[string]float64
+	nextID     int
+	executions []Execution
+}
+
+// NewExecutor creates an executor for the given venues
+// @author VrushankPatel
+// @description Opportunities on exchanges without a venue are not executed
+// @param venues The venues orders can be placed on
+// @param cfg The executor settings
+// @return A pointer to the newly created Executor
+func NewExecutor(venues []Venue, cfg Config) *Executor {
+	x := &Executor{
+		venues: make(map[string]Venue, len(venues)),
+		cfg:    cfg,
+		clock:  clock.Real(),
+		queue:  make(chan models.ArbitrageOpportunity, 1),
+	}
+	x.SetFees(cfg.Fees)
+	for _, venue := range venues {
+		x.venues[venue.Name()] = venue
+	}
+	return x
+}
+
+// SetClock replaces the wall clock used for timeouts, polling and timestamps
+// @author VrushankPatel
+// @description Lets tests control time; call before Start
+// @param c The clock to use
+func (x *Executor) SetClock(c clock.Clock) {
+	x.clock = c
+}
+
+// SetFees replaces the taker fees charged on the legs of executions that finish from now on
+// @author VrushankPatel
+// @description Safe to call while the executor runs; exchanges missing from the map are not charged
+// @param exchangeFees Map of exchange names to their taker fee rates as decimals
+func (x *Executor) SetFees(exchangeFees map[string]float64) {
+	fees := make(map[string]float64, len(exchangeFees))
+	for exchange, fee := range exchangeFees {
+		fees[exchange] = fee
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.fees = fees
+}
+
+// SetPnLHandler registers a function called with the net PnL of every completed or hedged execution
+// @author VrushankPatel
+// @description Feeds the daily loss limit of the risk manager; call before Start
+// @param handler The function to call, with the PnL in quote currency after fees
+func (x *Executor) SetPnLHandler(handler func(pnl float64)) {
+	x.onPnL = handler
+}
+
+// SetFailureHandler registers a function called with every failed execution
+// @author VrushankPatel
+// @description A failed execution may have left a position open, so trading should stop until it
+// is dealt with; call before Start
+// @param handler The function to call with the failed execution and its open position
+func (x *Executor) SetFailureHandler(handler func(exec Execution)) {
+	x.onFail = handler
+}
+
+// SetDeclineHandler registers a function called with every opportunity the executor will not trade
+// @author VrushankPatel
+// @description Lets the risk manager release the exposure it reserved; call before Start
+// @param handler The function to call with the skipped or aborted opportunity
+func (x *Executor) SetDeclineHandler(handler func(opp models.ArbitrageOpportunity)) {
+	x.onSkip = handler
+}
+
+// HandleOpportunityEvent queues newly opened cross-exchange opportunities for execution
+// @author VrushankPatel
+// @description Skips the opportunity when an execution is already running or queued
+// @param event The opportunity lifecycle event
+func (x *Executor) HandleOpportunityEvent(event models.OpportunityEvent) {
+	opp := event.Opportunity
+	if event.Type != models.OpportunityEventOpened || opp.Type != models.OpportunityCrossExchange {
+		return
+	}
+	if x.venues[opp.BuyExchange] == nil || x.venues[opp.SellExchange] == nil {
+		x.decline(opp)
+		return
+	}
+
+	select {
+	case x.queue <- opp:
+	default:
+		log.Debugf("[Executor] Busy, skipping opportunity %s", opp.ID)
+		x.decline(opp)
+	}
+}
+
+// decline passes an opportunity that will not be traded to the decline handler, if one is set
+func (x *Executor) decline(opp models.ArbitrageOpportunity) {
+	if x.onSkip != nil {
+		x.onSkip(opp)
+	}
+}
+
+// Start executes queued opportunities until the context is cancelled
+// @author VrushankPatel
+// @description An execution that has started always runs to its final state, so shutdown never
+// leaves a leg unhedged on purpose
+// @param ctx Context used for cancellation and shutdown signals
+func (x *Executor) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case opp := <-x.queue:
+			if exec := x.Execute(context.Background(), opp); exec.State == StateAborted {
+				x.decline(opp)
+			}
+		}
+	}
+}
+
+// Executions returns the most recent executions, newest first
+// @author VrushankPatel
+// @description Safe to call while the executor is running
+// @return Copies of the executions
+func (x *Executor) Executions() []Execution {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	executions := make([]Execution, 0, len(x.executions))
+	for i := len(x.executions) - 1; i >= 0; i-- {
+		executions = append(executions, x.executions[i])
+	}
+	return executions
+}
+
+// Execute runs the two-leg execution of an opportunity to its final state
+// @author VrushankPatel
+// @description Trades the opportunity's Quantity (MaxQuantity when unset) with limit prices
+// MaxSlippageBps beyond its BuyPrice and SellPrice, rounded to each exchange's tick size and lot
+// step, and hedges a hanging leg
+// @param ctx Context for the exchange requests
+// @param opp The opportunity to execute
+// @return The finished execution
+func (x *Executor) Execute(ctx context.Context, opp models.ArbitrageOpportunity) Execution {
+	x.mu.Lock()
+	x.nextID++
+	exec := &Execution{
+		ID:            x.nextID,
+		OpportunityID: opp.ID,
+		BaseCurrency:  opp.BaseCurrency,
+		QuoteCurrency: opp.QuoteCurrency,
+		StartedAt:     x.clock.Now(),
+	}
+	x.mu.Unlock()
+	exec.enter(StatePlacing, exec.StartedAt)
+
+	x.run(ctx, exec, opp)
+
+	exec.FinishedAt = x.clock.Now()
+	switch exec.State {
+	case StateCompleted, StateHedged:
+		x.settle(exec)
+	case StateFailed:
+		exec.OpenPosition = openPosition(exec)
+	}
+	x.log(exec)
+
+	x.mu.Lock()
+	x.executions = append(x.executions, *exec)
+	if len(x.executions) > maxExecutions {
+		x.executions = x.executions[len(x.executions)-maxExecutions:]
+	}
+	x.mu.Unlock()
+
+	// Only a flat position has a realized PnL; an open one is reported as such
+	switch {
+	case (exec.State == StateCompleted || exec.State == StateHedged) && x.onPnL != nil:
+		x.onPnL(exec.NetPnL)
+	case exec.State == StateFailed && x.onFail != nil:
+		x.onFail(*exec)
+	}
+	return *exec
+}
+
+// settle computes the PnL of an execution that ended flat, charging each exchange's taker fee
+func (x *Executor) settle(exec *Execution) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, leg := range exec.Legs {
+		value := leg.Order.FilledQuantity * leg.Order.AvgPrice
+		if leg.Side == SideSell {
+			exec.GrossPnL += value
+		} else {
+			exec.GrossPnL -= value
+		}
+		exec.Fees += value * x.fees[leg.Exchange]
+	}
+	exec.NetPnL = exec.GrossPnL - exec.Fees
+}
+
+// openPosition returns the base currency an execution left bought or sold per exchange
+func openPosition(exec *Execution) map[string]float64 {
+	position := make(map[string]float64)
+	for _, leg := range exec.Legs {
+		if leg.Side == SideSell {
+			position[leg.Exchange] -= leg.Order.FilledQuantity
+		} else {
+			position[leg.Exchange] += leg.Order.FilledQuantity
+		}
+	}
+	for exchange, quantity := range position {
+		if math.Abs(quantity) <= dust {
+			delete(position, exchange)
+		}
+	}
+	return position
+}
+
+// run moves an execution through its states
+func (x *Executor) run(ctx context.Context, exec *Execution, opp models.ArbitrageOpportunity) {
+	pair := models.TradingPair{BaseCurrency: opp.BaseCurrency, QuoteCurrency: opp.QuoteCurrency}
+	buyVenue, sellVenue := x.venues[opp.BuyExchange], x.venues[opp.SellExchange]
+	quantity := opp.Quantity
+	if quantity <= 0 {
+		quantity = opp.MaxQuantity
+	}
+	if opp.BalanceStatus == models.BalanceInsufficient {
+		exec.Error = "account balances cannot fund the opportunity"
+		x.transition(exec, StateAborted)
+		return
+	}
+	if opp.BalanceStatus == models.BalancePartial {
+		// Trade only what the account balances can fund
+		quantity = opp.ExecutableQuantity
+	}
+	if buyVenue == nil || sellVenue == nil || quantity <= 0 {
+		exec.Error = "no venue or trade size for the opportunity"
+		x.transition(exec, StateAborted)
+		return
+	}
+
+	// Orders must be in whole ticks and lot steps: both legs trade the same quantity, floored to
+	// both steps, and prices are rounded away from the opportunity's prices so slippage only grows
+	buyIncrements, err := buyVenue.Increments(ctx, pair)
+	if err != nil {
+		exec.Error = fmt.Sprintf("increments on %s: %v", buyVenue.Name(), err)
+		x.transition(exec, StateAborted)
+		return
+	}
+	sellIncrements, err := sellVenue.Increments(ctx, pair)
+	if err != nil {
+		exec.Error = fmt.Sprintf("increments on %s: %v", sellVenue.Name(), err)
+		x.transition(exec, StateAborted)
+		return
+	}
+	quantity = roundDown(roundDown(quantity, buyIncrements.Quantity), sellIncrements.Quantity)
+	if quantity <= 0 {
+		exec.Error = "trade size is below the quantity step"
+		x.transition(exec, StateAborted)
+		return
+	}
+
+	slippage := x.cfg.MaxSlippageBps / 10000
+	buy := &Leg{Role: RoleBuy, Exchange: buyVenue.Name(), Side: SideBuy, Type: TypeLimit, Quantity: quantity, Price: roundUp(opp.BuyPrice*(1+slippage), buyIncrements.Price), increments: buyIncrements}
+	sell := &Leg{Role: RoleSell, Exchange: sellVenue.Name(), Side: SideSell, Type: TypeLimit, Quantity: quantity, Price: roundDown(opp.SellPrice*(1-slippage), sellIncrements.Price), increments: sellIncrements}
+	exec.Legs = []*Leg{buy, sell}
+
+	// Send both legs at once
+	x.parallel(func() { x.place(ctx, exec, buyVenue, pair, buy) }, func() { x.place(ctx, exec, sellVenue, pair, sell) })
+	if buy.unplaced() && sell.unplaced() {
+		exec.Error = "both legs failed to place"
+		x.transition(exec, StateAborted)
+		return
+	}
+	x.transition(exec, StateWorking)
+
+	// Wait for both legs, cancelling what is still working at the timeout
+	x.parallel(func() { x.await(ctx, buyVenue, pair, buy) }, func() { x.await(ctx, sellVenue, pair, sell) })
+
+	for _, leg := range exec.Legs {
+		if (leg.Order.ID != "" || leg.unknown) && leg.Error != "" {
+			exec.Error = fmt.Sprintf("%s leg on %s: %s", leg.Role, leg.Exchange, leg.Error)
+			x.transition(exec, StateFailed)
+			return
+		}
+	}
+
+	bought, sold := buy.Order.FilledQuantity, sell.Order.FilledQuantity
+	switch {
+	case bought <= 0 && sold <= 0:
+		x.transition(exec, StateAborted)
+		return
+	case sameQuantity(bought, sold):
+		x.transition(exec, StateCompleted)
+		return
+	}
+
+	// One leg filled more than the other: unwind the difference where it was filled
+	x.transition(exec, StateHedging)
+	hedge := &Leg{Role: RoleHedge, Type: TypeMarket, increments: buyIncrements}
+	hedgeVenue := buyVenue
+	hedge.Side = SideSell
+	if sold > bought {
+		hedgeVenue = sellVenue
+		hedge.Side = SideBuy
+		hedge.increments = sellIncrements
+	}
+	hedge.Exchange = hedgeVenue.Name()
+	hedge.Quantity = roundDown(math.Abs(bought-sold), hedge.increments.Quantity)
+	if hedge.Quantity <= 0 {
+		exec.Error = fmt.Sprintf("unhedged %.8f %s on %s is below the quantity step", math.Abs(bought-sold), opp.BaseCurrency, hedge.Exchange)
+		x.transition(exec, StateFailed)
+		return
+	}
+	exec.Legs = append(exec.Legs, hedge)
+
+	x.place(ctx, exec, hedgeVenue, pair, hedge)
+	if hedge.Error == "" {
+		x.await(ctx, hedgeVenue, pair, hedge)
+	}
+	if hedge.Error == "" && sameQuantity(hedge.Order.FilledQuantity, hedge.Quantity) {
+		x.transition(exec, StateHedged)
+		return
+	}
+	exec.Error = fmt.Sprintf("hedge filled %.8f of %.8f %s on %s", hedge.Order.FilledQuantity, hedge.Quantity, opp.BaseCurrency, hedge.Exchange)
+	x.transition(exec, StateFailed)
+}
+
+// place sends the order of a leg
+func (x *Executor) place(ctx context.Context, exec *Execution, venue Venue, pair models.TradingPair, leg *Leg) {
+	clientID := fmt.Sprintf("apex-%d-%d-%s", exec.StartedAt.Unix(), exec.ID, leg.Role)
+	order, err := venue.PlaceOrder(ctx, OrderRequest{
+		Pair:       pair,
+		Side:       leg.Side,
+		Type:       leg.Type,
+		Quantity:   leg.Quantity,
+		Price:      leg.Price,
+		ClientID:   clientID,
+		Increments: leg.increments,
+	})
+	if err == nil {
+		leg.Order = order
+		return
+	}
+
+	// The exchange may have accepted the order although the request failed, e.g. on a timeout
+	found, lookupErr := venue.GetOrderByClientID(ctx, pair, clientID)
+	switch {
+	case lookupErr == nil:
+		log.Warnf("[Executor] %s leg on %s was placed despite an error: %v", leg.Role, leg.Exchange, err)
+		leg.Order = found
+	case errors.Is(lookupErr, ErrOrderNotFound):
+		leg.Error = err.Error()
+		leg.Order.Status = OrderRejected
+		log.Errorf("[Executor] Failed to place %s leg on %s: %v", leg.Role, leg.Exchange, err)
+	default:
+		leg.Error = fmt.Sprintf("%v; order state unknown: %v", err, lookupErr)
+		leg.Order.ClientID = clientID
+		leg.unknown = true
+		log.Errorf("[Executor] %s leg on %s may have been placed: %s", leg.Role, leg.Exchange, leg.Error)
+	}
+}
+
+// unplaced reports whether the exchange is known not to have accepted the leg's order
+func (leg *Leg) unplaced() bool {
+	return leg.Error != "" && !leg.unknown
+}
+
+// await polls the order of a leg until it is done, cancelling it at the leg timeout
+func (x *Executor) await(ctx context.Context, venue Venue, pair models.TradingPair, leg *Leg) {
+	if leg.Error != "" || leg.Order.Terminal() {
+		return
+	}
+
+	deadline := x.clock.Now().Add(x.cfg.LegTimeout)
+	ticker := x.clock.NewTicker(x.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for !x.clock.Now().After(deadline) {
+		<-ticker.C()
+		order, err := venue.GetOrder(ctx, pair, leg.Order.ID)
+		if err != nil {
+			log.Warnf("[Executor] Failed to poll %s leg on %s: %v", leg.Role, leg.Exchange, err)
+			continue
+		}
+		leg.Order = order
+		if order.Terminal() {
+			return
+		}
+	}
+
+	// Timed out: cancel and take whatever filled until then
+	if err := venue.CancelOrder(ctx, pair, leg.Order.ID); err != nil {
+		log.Errorf("[Executor] Failed to cancel %s leg on %s: %v", leg.Role, leg.Exchange, err)
+	}
+	order, err := venue.GetOrder(ctx, pair, leg.Order.ID)
+	if err != nil {
+		leg.Error = fmt.Sprintf("order state unknown after cancel: %v", err)
+		return
+	}
+	leg.Order = order
+}
+
+// transition moves an execution to the next state
+func (x *Executor) transition(exec *Execution, state string) {
+	allowed := false
+	for _, next := range transitions[exec.State] {
+		if next == state {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		log.Errorf("[Executor] Invalid transition of execution %d from %s to %s", exec.ID, exec.State, state)
+	}
+	exec.enter(state, x.clock.Now())
+}
+
+// enter records the execution entering a state
+func (exec *Execution) enter(state string, at time.Time) {
+	exec.State = state
+	exec.History = append(exec.History, Transition{State: state, At: at})
+}
+
+// parallel runs the functions concurrently and waits for all of them
+func (x *Executor) parallel(fns ...func()) {
+	var wg sync.WaitGroup
+	for _, fn := range fns {
+		wg.Add(1)
+		go func(fn func()) {
+			defer wg.Done()
+			fn()
+		}(fn)
+	}
+	wg.Wait()
+}
+
+// log reports the outcome of an execution
+func (x *Executor) log(exec *Execution) {
+	entry := log.WithFields(log.Fields{
+		"execution":   exec.ID,
+		"opportunity": exec.OpportunityID,
+		"state":       exec.State,
+		"duration":    exec.FinishedAt.Sub(exec.StartedAt).String(),
+	})
+	if exec.State == StateCompleted || exec.State == StateHedged {
+		entry = entry.WithField("net_pnl", fmt.Sprintf("%.4f %s", exec.NetPnL, exec.QuoteCurrency))
+	}
+	for exchange, quantity := range exec.OpenPosition {
+		entry = entry.WithField("open_"+strings.ToLower(exchange), fmt.Sprintf("%+.8f %s", quantity, exec.BaseCurrency))
+	}
+	for _, leg := range exec.Legs {
+		entry = entry.WithField(leg.Role, fmt.Sprintf("%s %s %.8f/%.8f @ %.4f (%s)", leg.Exchange, leg.Side, leg.Order.FilledQuantity, leg.Quantity, leg.Order.AvgPrice, leg.Order.Status))
+	}
+	if exec.Error != "" {
+		entry = entry.WithField("error", exec.Error)
+	}
+
+	switch exec.State {
+	case StateFailed:
+		entry.Error("[Executor] Execution failed, position needs manual attention")
+	case StateHedged:
+		entry.Warn("[Executor] Execution hedged")
+	default:
+		entry.Info("[Executor] Execution finished")
+	}
+}
+
+// sameQuantity reports whether two filled quantities are equal within fillTolerance
+func sameQuantity(a, b float64) bool {
+	return math.Abs(a-b) <= fillTolerance*math.Max(math.Abs(a), math.Abs(b))
+}
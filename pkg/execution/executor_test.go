@@ -0,0 +1,312 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// fakeVenue fills orders as scripted per side, recording every request
+type fakeVenue struct {
+	name       string
+	increments Increments
+	fills      map[string]float64 // Quantity filled per side; the rest is canceled
+	fail       map[string]error   // Error returned when placing an order of a side
+	lost       map[string]error   // Error returned after accepting an order of a side, as on a timeout
+
+	mu       sync.Mutex
+	requests []OrderRequest
+	orders   map[string]Order
+}
+
+func newFakeVenue(name string) *fakeVenue {
+	return &fakeVenue{
+		name:   name,
+		fills:  make(map[string]float64),
+		fail:   make(map[string]error),
+		lost:   make(map[string]error),
+		orders: make(map[string]Order),
+	}
+}
+
+func (v *fakeVenue) Name() string {
+	return v.name
+}
+
+func (v *fakeVenue) PlaceOrder(ctx context.Context, req OrderRequest) (Order, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.requests = append(v.requests, req)
+	if err := v.fail[req.Side]; err != nil {
+		return Order{}, err
+	}
+
+	filled := req.Quantity
+	if scripted, exists := v.fills[req.Side]; exists {
+		filled = scripted
+	}
+	order := Order{
+		ID:             fmt.Sprintf("%s-%d", v.name, len(v.requests)),
+		ClientID:       req.ClientID,
+		Status:         OrderFilled,
+		Quantity:       req.Quantity,
+		FilledQuantity: filled,
+		AvgPrice:       req.Price,
+	}
+	if filled < req.Quantity {
+		order.Status = OrderCanceled
+	}
+	if req.Type == TypeMarket {
+		order.AvgPrice = 100
+	}
+	v.orders[order.ID] = order
+	if err := v.lost[req.Side]; err != nil {
+		return Order{}, err
+	}
+	return order, nil
+}
+
+func (v *fakeVenue) GetOrder(ctx context.Context, pair models.TradingPair, id string) (Order, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	order, exists := v.orders[id]
+	if !exists {
+		return Order{}, errors.New("unknown order")
+	}
+	return order, nil
+}
+
+func (v *fakeVenue) GetOrderByClientID(ctx context.Context, pair models.TradingPair, clientID string) (Order, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, order := range v.orders {
+		if order.ClientID == clientID {
+			return order, nil
+		}
+	}
+	return Order{}, ErrOrderNotFound
+}
+
+func (v *fakeVenue) CancelOrder(ctx context.Context, pair models.TradingPair, id string) error {
+	return nil
+}
+
+func (v *fakeVenue) Increments(ctx context.Context, pair models.TradingPair) (Increments, error) {
+	return v.increments, nil
+}
+
+// testOpportunity buys on Binance at 100 and sells on Kraken at 101
+func testOpportunity(quantity float64) models.ArbitrageOpportunity {
+	return models.ArbitrageOpportunity{
+		ID:            "BTC-USDT-Binance-Kraken",
+		Type:          models.OpportunityCrossExchange,
+		BaseCurrency:  "BTC",
+		QuoteCurrency: "USDT",
+		BuyExchange:   "Binance",
+		SellExchange:  "Kraken",
+		BuyPrice:      100,
+		SellPrice:     101,
+		Quantity:      quantity,
+	}
+}
+
+// testResults collects what an executor reports to the risk manager
+type testResults struct {
+	pnl      []float64
+	failures []Execution
+}
+
+// newTestExecutor creates an executor on the two venues charging 0.1% on Binance and 0.2% on Kraken
+func newTestExecutor(buyVenue, sellVenue *fakeVenue, results *testResults) *Executor {
+	executor := NewExecutor([]Venue{buyVenue, sellVenue}, Config{
+		LegTimeout:     50 * time.Millisecond,
+		PollInterval:   time.Millisecond,
+		MaxSlippageBps: 10,
+		Fees:           map[string]float64{"Binance": 0.001, "Kraken": 0.002},
+	})
+	executor.SetPnLHandler(func(pnl float64) { results.pnl = append(results.pnl, pnl) })
+	executor.SetFailureHandler(func(exec Execution) { results.failures = append(results.failures, exec) })
+	return executor
+}
+
+// runExecution executes the test opportunity on the two venues and checks the states it went through
+func runExecution(t *testing.T, buyVenue, sellVenue *fakeVenue, quantity float64, states ...string) (Execution, testResults) {
+	t.Helper()
+	results := testResults{}
+	exec := newTestExecutor(buyVenue, sellVenue, &results).Execute(context.Background(), testOpportunity(quantity))
+
+	visited := []string{}
+	for _, transition := range exec.History {
+		visited = append(visited, transition.State)
+	}
+	if !reflect.DeepEqual(visited, states) {
+		t.Errorf("states %v, want %v (error %q)", visited, states, exec.Error)
+	}
+	if exec.State != states[len(states)-1] {
+		t.Errorf("final state %s, want %s", exec.State, states[len(states)-1])
+	}
+	return exec, results
+}
+
+func TestExecutorBothLegsFill(t *testing.T) {
+	buyVenue, sellVenue := newFakeVenue("Binance"), newFakeVenue("Kraken")
+	exec, results := runExecution(t, buyVenue, sellVenue, 1, StatePlacing, StateWorking, StateCompleted)
+
+	if len(exec.Legs) != 2 {
+		t.Fatalf("%d legs, want 2", len(exec.Legs))
+	}
+	if exec.Error != "" {
+		t.Errorf("unexpected error %q", exec.Error)
+	}
+	// Limit prices are 10 bps beyond the detected prices
+	sold, bought := 101*0.999, 100*1.001
+	if want := sold - bought; !sameQuantity(exec.GrossPnL, want) {
+		t.Errorf("gross PnL %v, want %v", exec.GrossPnL, want)
+	}
+	fees := bought*0.001 + sold*0.002
+	if want := sold - bought - fees; !sameQuantity(exec.NetPnL, want) || !sameQuantity(exec.Fees, fees) {
+		t.Errorf("net PnL %v after fees %v, want %v after %v", exec.NetPnL, exec.Fees, want, fees)
+	}
+	if len(results.pnl) != 1 || results.pnl[0] != exec.NetPnL || len(results.failures) != 0 {
+		t.Errorf("reported PnL %v and failures %d, want the net PnL only", results.pnl, len(results.failures))
+	}
+}
+
+func TestExecutorHedgesOneFilledLeg(t *testing.T) {
+	buyVenue, sellVenue := newFakeVenue("Binance"), newFakeVenue("Kraken")
+	sellVenue.fills[SideSell] = 0
+	exec, results := runExecution(t, buyVenue, sellVenue, 1, StatePlacing, StateWorking, StateHedging, StateHedged)
+
+	if len(exec.Legs) != 3 {
+		t.Fatalf("%d legs, want 3", len(exec.Legs))
+	}
+	hedge := exec.Legs[2]
+	if hedge.Role != RoleHedge || hedge.Exchange != "Binance" || hedge.Side != SideSell || hedge.Type != TypeMarket || hedge.Quantity != 1 {
+		t.Errorf("unexpected hedge leg %+v", hedge)
+	}
+	if len(buyVenue.requests) != 2 {
+		t.Errorf("%d orders on the buy venue, want the buy leg and the hedge", len(buyVenue.requests))
+	}
+	// Bought at 100.1 and sold back at 100, paying 0.1% on both
+	if want := 100 - 100.1 - (100.1+100)*0.001; len(results.pnl) != 1 || !sameQuantity(results.pnl[0], want) {
+		t.Errorf("reported PnL %v, want %v", results.pnl, want)
+	}
+}
+
+func TestExecutorHedgeFails(t *testing.T) {
+	buyVenue, sellVenue := newFakeVenue("Binance"), newFakeVenue("Kraken")
+	buyVenue.fills[SideBuy] = 0.4
+	sellVenue.fills[SideSell] = 1
+	sellVenue.fail[SideBuy] = errors.New("insufficient balance")
+	exec, results := runExecution(t, buyVenue, sellVenue, 1, StatePlacing, StateWorking, StateHedging, StateFailed)
+
+	hedge := exec.Legs[2]
+	if hedge.Exchange != "Kraken" || hedge.Side != SideBuy || !sameQuantity(hedge.Quantity, 0.6) {
+		t.Errorf("unexpected hedge leg %+v", hedge)
+	}
+	if hedge.Error != "insufficient balance" || exec.Error == "" {
+		t.Errorf("hedge error %q, execution error %q", hedge.Error, exec.Error)
+	}
+
+	// The open position is reported instead of a PnL
+	if len(results.pnl) != 0 || exec.GrossPnL != 0 || exec.NetPnL != 0 {
+		t.Errorf("reported PnL %v for a failed execution", results.pnl)
+	}
+	if len(results.failures) != 1 {
+		t.Fatalf("%d failures reported, want 1", len(results.failures))
+	}
+	position := results.failures[0].OpenPosition
+	if len(position) != 2 || !sameQuantity(position["Binance"], 0.4) || !sameQuantity(position["Kraken"], -1) {
+		t.Errorf("open position %v, want Binance +0.4 and Kraken -1", position)
+	}
+}
+
+func TestExecutorBothLegsRejected(t *testing.T) {
+	buyVenue, sellVenue := newFakeVenue("Binance"), newFakeVenue("Kraken")
+	buyVenue.fail[SideBuy] = errors.New("rejected")
+	sellVenue.fail[SideSell] = errors.New("rejected")
+	exec, results := runExecution(t, buyVenue, sellVenue, 1, StatePlacing, StateAborted)
+
+	if exec.Error != "both legs failed to place" {
+		t.Errorf("error %q", exec.Error)
+	}
+	if exec.GrossPnL != 0 || len(results.pnl) != 0 || len(results.failures) != 0 {
+		t.Errorf("gross PnL %v, reported %v and %d failures, want nothing", exec.GrossPnL, results.pnl, len(results.failures))
+	}
+}
+
+func TestExecutorTracksOrderPlacedDespiteError(t *testing.T) {
+	buyVenue, sellVenue := newFakeVenue("Binance"), newFakeVenue("Kraken")
+	sellVenue.lost[SideSell] = errors.New("context deadline exceeded")
+	exec, results := runExecution(t, buyVenue, sellVenue, 1, StatePlacing, StateWorking, StateCompleted)
+
+	// The sell leg is found by its client id, so nothing is hedged
+	if len(exec.Legs) != 2 {
+		t.Fatalf("%d legs, want 2", len(exec.Legs))
+	}
+	if sell := exec.Legs[1]; sell.Error != "" || sell.Order.ID == "" || sell.Order.FilledQuantity != 1 {
+		t.Errorf("unexpected sell leg %+v", sell)
+	}
+	if len(results.pnl) != 1 || len(results.failures) != 0 {
+		t.Errorf("reported PnL %v and %d failures, want one PnL", results.pnl, len(results.failures))
+	}
+}
+
+func TestExecutorRoundsToIncrements(t *testing.T) {
+	buyVenue, sellVenue := newFakeVenue("Binance"), newFakeVenue("Kraken")
+	buyVenue.increments = Increments{Price: 0.01, Quantity: 0.001}
+	sellVenue.increments = Increments{Price: 0.25, Quantity: 0.01}
+	runExecution(t, buyVenue, sellVenue, 1.23456, StatePlacing, StateWorking, StateCompleted)
+
+	buy, sell := buyVenue.requests[0], sellVenue.requests[0]
+	// The quantity is floored to both steps; the buy price rounds up, the sell price down
+	if !sameQuantity(buy.Quantity, 1.23) || !sameQuantity(sell.Quantity, 1.23) {
+		t.Errorf("quantities %v and %v, want 1.23", buy.Quantity, sell.Quantity)
+	}
+	if !sameQuantity(buy.Price, 100.1) || !sameQuantity(sell.Price, 100.75) {
+		t.Errorf("prices %v and %v, want 100.1 and 100.75", buy.Price, sell.Price)
+	}
+	if buy.Increments != buyVenue.increments || sell.Increments != sellVenue.increments {
+		t.Errorf("requests carry increments %+v and %+v", buy.Increments, sell.Increments)
+	}
+}
+
+func TestExecutorAbortsBelowQuantityStep(t *testing.T) {
+	buyVenue, sellVenue := newFakeVenue("Binance"), newFakeVenue("Kraken")
+	sellVenue.increments = Increments{Quantity: 0.01}
+	runExecution(t, buyVenue, sellVenue, 0.005, StatePlacing, StateAborted)
+
+	if len(buyVenue.requests)+len(sellVenue.requests) != 0 {
+		t.Error("orders were placed for a trade below the quantity step")
+	}
+}
+
+func TestRounding(t *testing.T) {
+	for _, test := range []struct {
+		value, increment float64
+		up, down         float64
+		formatted        string
+	}{
+		{70012.345, 0.01, 70012.35, 70012.34, "70012.34"},
+		{0.3, 0.1, 0.3, 0.3, "0.3"},
+		{1.3, 0.25, 1.5, 1.25, "1.25"},
+		{0.123456789, 0, 0.123456789, 0.123456789, "0.12345679"},
+	} {
+		if up := roundUp(test.value, test.increment); !sameQuantity(up, test.up) {
+			t.Errorf("roundUp(%v, %v) = %v, want %v", test.value, test.increment, up, test.up)
+		}
+		down := roundDown(test.value, test.increment)
+		if !sameQuantity(down, test.down) {
+			t.Errorf("roundDown(%v, %v) = %v, want %v", test.value, test.increment, down, test.down)
+		}
+		if formatted := formatAmount(down, test.increment); formatted != test.formatted {
+			t.Errorf("formatAmount(%v, %v) = %q, want %q", down, test.increment, formatted, test.formatted)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package execution
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// KrakenRESTURL is the production Kraken REST endpoint
+const KrakenRESTURL = "https://api.kraken.com"
+
+// Kraken places orders through the Kraken spot REST API
+// @author VrushankPatel
+// @description Signs requests with HMAC-SHA512 of the path and the SHA-256 of nonce and body,
+// keyed with the base64-decoded secret, as Kraken requires
+type Kraken struct {
+	baseURL    string
+	apiKey     string
+	apiSecret  string
+	client     *http.Client
+	increments incrementCache
+
+	// Nonces must strictly increase per API key
+	nonceMu   sync.Mutex
+	lastNonce int64
+}
+
+// krakenResponse is the envelope of every Kraken REST response
+type krakenResponse[T any] struct {
+	Error  []string `json:"error"`
+	Result T        `json:"result"`
+}
+
+// krakenOrder is an order as returned by QueryOrders
+type krakenOrder struct {
+	Status  string `json:"status"`
+	Vol     string `json:"vol"`
+	VolExec string `json:"vol_exec"`
+	Price   string `json:"price"`
+	ClOrdID string `json:"cl_ord_id"`
+}
+
+// NewKraken creates a Kraken venue
+// @author VrushankPatel
+// @description Creates a venue that sends orders to baseURL, KrakenRESTURL when empty
+// @param baseURL The REST base URL
+// @param apiKey The API key
+// @param apiSecret The base64-encoded API secret
+// @return A pointer to the newly created Kraken venue
+func NewKraken(baseURL, apiKey, apiSecret string) *Kraken {
+	if baseURL == "" {
+		baseURL = KrakenRESTURL
+	}
+	return &Kraken{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		client:    newHTTPClient(),
+	}
+}
+
+// Name returns the exchange name
+func (k *Kraken) Name() string {
+	return "Kraken"
+}
+
+// PlaceOrder sends a new order and queries its state. An order whose state cannot be queried is
+// returned as new, so it is still tracked.
+func (k *Kraken) PlaceOrder(ctx context.Context, req OrderRequest) (Order, error) {
+	params := url.Values{}
+	params.Set("pair", krakenPair(req.Pair))
+	params.Set("type", req.Side)
+	params.Set("volume", formatAmount(req.Quantity, req.Increments.Quantity))
+	params.Set("cl_ord_id", req.ClientID)
+	if req.Type == TypeMarket {
+		params.Set("ordertype", "market")
+	} else {
+		params.Set("ordertype", "limit")
+		params.Set("timeinforce", "IOC")
+		params.Set("price", formatAmount(req.Price, req.Increments.Price))
+	}
+
+	var resp krakenResponse[struct {
+		TxID []string `json:"txid"`
+	}]
+	if err := k.private(ctx, "/0/private/AddOrder", params, &resp); err != nil {
+		return Order{}, err
+	}
+	if len(resp.Result.TxID) == 0 {
+		return Order{}, fmt.Errorf("AddOrder returned no order id")
+	}
+	id := resp.Result.TxID[0]
+	order, err := k.GetOrder(ctx, req.Pair, id)
+	if err != nil {
+		// The order was accepted; polling will catch up with its state
+		return Order{ID: id, ClientID: req.ClientID, Status: OrderNew, Quantity: req.Quantity}, nil
+	}
+	return order, nil
+}
+
+// GetOrder returns the current state of an order
+func (k *Kraken) GetOrder(ctx context.Context, pair models.TradingPair, id string) (Order, error) {
+	params := url.Values{}
+	params.Set("txid", id)
+
+	var resp krakenResponse[map[string]krakenOrder]
+	if err := k.private(ctx, "/0/private/QueryOrders", params, &resp); err != nil {
+		return Order{}, err
+	}
+	raw, exists := resp.Result[id]
+	if !exists {
+		return Order{}, fmt.Errorf("order %s not found", id)
+	}
+	return raw.normalize(id), nil
+}
+
+// GetOrderByClientID returns the current state of the order placed with a client id, looking
+// through the open orders first and the closed ones after
+func (k *Kraken) GetOrderByClientID(ctx context.Context, pair models.TradingPair, clientID string) (Order, error) {
+	for _, list := range []struct{ path, key string }{
+		{"/0/private/OpenOrders", "open"},
+		{"/0/private/ClosedOrders", "closed"},
+	} {
+		params := url.Values{}
+		params.Set("cl_ord_id", clientID)
+		var resp krakenResponse[map[string]map[string]krakenOrder]
+		if err := k.private(ctx, list.path, params, &resp); err != nil {
+			return Order{}, err
+		}
+		for id, raw := range resp.Result[list.key] {
+			if raw.ClOrdID == clientID {
+				return raw.normalize(id), nil
+			}
+		}
+	}
+	return Order{}, ErrOrderNotFound
+}
+
+// normalize converts a Kraken order to an Order
+func (raw krakenOrder) normalize(id string) Order {
+	order := Order{
+		ID:             id,
+		ClientID:       raw.ClOrdID,
+		Quantity:       parseAmount(raw.Vol),
+		FilledQuantity: parseAmount(raw.VolExec),
+		AvgPrice:       parseAmount(raw.Price),
+	}
+	switch raw.Status {
+	case "closed":
+		order.Status = OrderFilled
+		if order.FilledQuantity < order.Quantity {
+			order.Status = OrderCanceled
+		}
+	case "canceled", "expired":
+		order.Status = OrderCanceled
+	case "open":
+		order.Status = OrderNew
+		if order.FilledQuantity > 0 {
+			order.Status = OrderPartiallyFilled
+		}
+	default:
+		order.Status = OrderNew
+	}
+	return order
+}
+
+// CancelOrder cancels an order
+func (k *Kraken) CancelOrder(ctx context.Context, pair models.TradingPair, id string) error {
+	params := url.Values{}
+	params.Set("txid", id)
+
+	var resp krakenResponse[struct {
+		Count int `json:"count"`
+	}]
+	err := k.private(ctx, "/0/private/CancelOrder", params, &resp)
+	if err != nil && strings.Contains(err.Error(), "Unknown order") {
+		// The order was already done
+		return nil
+	}
+	return err
+}
+
+// Increments returns the tick size and lot step of a pair, from its tick size or price decimals
+// and its lot decimals
+func (k *Kraken) Increments(ctx context.Context, pair models.TradingPair) (Increments, error) {
+	name := krakenPair(pair)
+	return k.increments.get(name, func() (Increments, error) {
+		params := url.Values{}
+		params.Set("pair", name)
+		var resp krakenResponse[map[string]struct {
+			PairDecimals int    `json:"pair_decimals"`
+			LotDecimals  int    `json:"lot_decimals"`
+			TickSize     string `json:"tick_size"`
+		}]
+		if err := k.public(ctx, "/0/public/AssetPairs", params, &resp); err != nil {
+			return Increments{}, err
+		}
+		// The result is keyed by Kraken's own name of the pair, which may differ from the request
+		for _, info := range resp.Result {
+			increments := Increments{
+				Price:    math.Pow10(-info.PairDecimals),
+				Quantity: math.Pow10(-info.LotDecimals),
+			}
+			if tick := parseAmount(info.TickSize); tick > 0 {
+				increments.Price = tick
+			}
+			return increments, nil
+		}
+		return Increments{}, fmt.Errorf("pair %s not listed", name)
+	})
+}
+
+// WebSocketsToken returns a token for subscribing to private websocket channels
+func (k *Kraken) WebSocketsToken(ctx context.Context) (string, error) {
+	var resp krakenResponse[struct {
+		Token string `json:"token"`
+	}]
+	if err := k.private(ctx, "/0/private/GetWebSocketsToken", url.Values{}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Result.Token, nil
+}
+
+// private sends a signed request to a private endpoint and fails on Kraken errors
+func (k *Kraken) private(ctx context.Context, path string, params url.Values, out interface{ errors() []string }) error {
+	params.Set("nonce", strconv.FormatInt(k.nonce(), 10))
+	body := params.Encode()
+
+	secret, err := base64.StdEncoding.DecodeString(k.apiSecret)
+	if err != nil {
+		return fmt.Errorf("invalid Kraken API secret: %v", err)
+	}
+	digest := sha256.Sum256([]byte(params.Get("nonce") + body))
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(append([]byte(path), digest[:]...))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.baseURL+path, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("API-Key", k.apiKey)
+	req.Header.Set("API-Sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+	if err := do(k.client, req, out); err != nil {
+		return err
+	}
+	if errs := out.errors(); len(errs) > 0 {
+		return fmt.Errorf("%s: %s", path, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// public sends a request to a public endpoint and fails on Kraken errors
+func (k *Kraken) public(ctx context.Context, path string, params url.Values, out interface{ errors() []string }) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if err := do(k.client, req, out); err != nil {
+		return err
+	}
+	if errs := out.errors(); len(errs) > 0 {
+		return fmt.Errorf("%s: %s", path, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// errors returns the errors reported in the response
+func (r *krakenResponse[T]) errors() []string {
+	return r.Error
+}
+
+// nonce returns a strictly increasing nonce based on the current time
+func (k *Kraken) nonce() int64 {
+	k.nonceMu.Lock()
+	defer k.nonceMu.Unlock()
+	nonce := time.Now().UnixMicro()
+	if nonce <= k.lastNonce {
+		nonce = k.lastNonce + 1
+	}
+	k.lastNonce = nonce
+	return nonce
+}
+
+// krakenPair returns the REST pair name, e.g. "XBTUSDT"
+func krakenPair(pair models.TradingPair) string {
+	return strings.ReplaceAll(pair.GetSymbol("Kraken"), "/", "")
+}
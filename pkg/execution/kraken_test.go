@@ -0,0 +1,199 @@
+package execution
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// krakenSecret is a base64-encoded API secret, as Kraken issues them
+var krakenSecret = base64.StdEncoding.EncodeToString([]byte("kraken-secret"))
+
+// krakenServer is a mock Kraken REST API that checks the API key, nonces and request signatures
+type krakenServer struct {
+	t         *testing.T
+	lastNonce int64
+	orders    []url.Values // Parameters of the orders placed
+	queryDown bool         // QueryOrders fails, as when the exchange is overloaded
+}
+
+func (s *krakenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/0/public/AssetPairs" {
+		if r.URL.Query().Get("pair") != "XBTUSDT" {
+			w.Write([]byte(`{"error":["EQuery:Unknown asset pair"]}`))
+			return
+		}
+		w.Write([]byte(`{"error":[],"result":{"XBTUSDT":{"altname":"XBTUSDT","pair_decimals":1,"lot_decimals":8}}}`))
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	params, _ := url.ParseQuery(string(body))
+	secret, _ := base64.StdEncoding.DecodeString(krakenSecret)
+	digest := sha256.Sum256([]byte(params.Get("nonce") + string(body)))
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(append([]byte(r.URL.Path), digest[:]...))
+	if r.Header.Get("API-Key") != "key" || r.Header.Get("API-Sign") != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		w.Write([]byte(`{"error":["EAPI:Invalid signature"]}`))
+		return
+	}
+	nonce, _ := strconv.ParseInt(params.Get("nonce"), 10, 64)
+	if nonce <= s.lastNonce {
+		w.Write([]byte(`{"error":["EAPI:Invalid nonce"]}`))
+		return
+	}
+	s.lastNonce = nonce
+
+	switch r.URL.Path {
+	case "/0/private/AddOrder":
+		s.orders = append(s.orders, params)
+		w.Write([]byte(`{"error":[],"result":{"txid":["OABC-123"]}}`))
+	case "/0/private/QueryOrders":
+		if s.queryDown {
+			w.Write([]byte(`{"error":["EService:Unavailable"]}`))
+			return
+		}
+		w.Write([]byte(`{"error":[],"result":{"` + params.Get("txid") + `":{"status":"closed","vol":"0.50000000","vol_exec":"0.50000000","price":"70000.1","cl_ord_id":"apex-1"}}}`))
+	case "/0/private/OpenOrders":
+		w.Write([]byte(`{"error":[],"result":{"open":{}}}`))
+	case "/0/private/ClosedOrders":
+		if params.Get("cl_ord_id") != "apex-1" {
+			w.Write([]byte(`{"error":[],"result":{"closed":{}}}`))
+			return
+		}
+		w.Write([]byte(`{"error":[],"result":{"closed":{"OABC-123":{"status":"canceled","vol":"0.50000000","vol_exec":"0.20000000","price":"70000.1","cl_ord_id":"apex-1"}}}}`))
+	case "/0/private/CancelOrder":
+		w.Write([]byte(`{"error":["EOrder:Unknown order"]}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestKrakenPlaceOrderSignsAndNormalizes(t *testing.T) {
+	mock := &krakenServer{t: t}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	venue := NewKraken(server.URL, "key", krakenSecret)
+	order, err := venue.PlaceOrder(context.Background(), OrderRequest{
+		Pair:       models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"},
+		Side:       SideSell,
+		Type:       TypeLimit,
+		Quantity:   0.5,
+		Price:      70000.1,
+		ClientID:   "apex-1",
+		Increments: Increments{Price: 0.1, Quantity: 0.00000001},
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	if order.ID != "OABC-123" || order.Status != OrderFilled || order.FilledQuantity != 0.5 || order.AvgPrice != 70000.1 {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	if len(mock.orders) != 1 {
+		t.Fatalf("placed %d orders, want 1", len(mock.orders))
+	}
+	placed := mock.orders[0]
+	for param, want := range map[string]string{
+		"pair":        "XBTUSDT",
+		"type":        "sell",
+		"ordertype":   "limit",
+		"timeinforce": "IOC",
+		"volume":      "0.50000000",
+		"price":       "70000.1",
+		"cl_ord_id":   "apex-1",
+	} {
+		if placed.Get(param) != want {
+			t.Errorf("%s = %q, want %q", param, placed.Get(param), want)
+		}
+	}
+}
+
+func TestKrakenPlaceOrderKeepsIDWhenQueryFails(t *testing.T) {
+	server := httptest.NewServer(&krakenServer{t: t, queryDown: true})
+	defer server.Close()
+
+	venue := NewKraken(server.URL, "key", krakenSecret)
+	order, err := venue.PlaceOrder(context.Background(), OrderRequest{
+		Pair:     models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"},
+		Side:     SideBuy,
+		Type:     TypeMarket,
+		Quantity: 0.5,
+		ClientID: "apex-1",
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	if order.ID != "OABC-123" || order.ClientID != "apex-1" || order.Status != OrderNew || order.Quantity != 0.5 {
+		t.Errorf("unexpected order %+v", order)
+	}
+}
+
+func TestKrakenGetOrderByClientID(t *testing.T) {
+	server := httptest.NewServer(&krakenServer{t: t})
+	defer server.Close()
+
+	venue := NewKraken(server.URL, "key", krakenSecret)
+	pair := models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"}
+	order, err := venue.GetOrderByClientID(context.Background(), pair, "apex-1")
+	if err != nil {
+		t.Fatalf("GetOrderByClientID failed: %v", err)
+	}
+	if order.ID != "OABC-123" || order.Status != OrderCanceled || order.FilledQuantity != 0.2 {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	if _, err := venue.GetOrderByClientID(context.Background(), pair, "apex-2"); err != ErrOrderNotFound {
+		t.Errorf("expected ErrOrderNotFound for an unknown client id, got %v", err)
+	}
+}
+
+func TestKrakenRejectsBadSignature(t *testing.T) {
+	server := httptest.NewServer(&krakenServer{t: t})
+	defer server.Close()
+
+	venue := NewKraken(server.URL, "key", base64.StdEncoding.EncodeToString([]byte("wrong")))
+	_, err := venue.GetOrder(context.Background(), models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"}, "OABC-123")
+	if err == nil || err.Error() != "/0/private/QueryOrders: EAPI:Invalid signature" {
+		t.Fatalf("expected a signature error, got %v", err)
+	}
+}
+
+func TestKrakenCancelDoneOrder(t *testing.T) {
+	server := httptest.NewServer(&krakenServer{t: t})
+	defer server.Close()
+
+	venue := NewKraken(server.URL, "key", krakenSecret)
+	if err := venue.CancelOrder(context.Background(), models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"}, "OABC-123"); err != nil {
+		t.Errorf("CancelOrder of a done order failed: %v", err)
+	}
+}
+
+func TestKrakenIncrements(t *testing.T) {
+	server := httptest.NewServer(&krakenServer{t: t})
+	defer server.Close()
+
+	venue := NewKraken(server.URL, "key", krakenSecret)
+	increments, err := venue.Increments(context.Background(), models.TradingPair{BaseCurrency: "BTC", QuoteCurrency: "USDT"})
+	if err != nil {
+		t.Fatalf("Increments failed: %v", err)
+	}
+	if !sameQuantity(increments.Price, 0.1) || !sameQuantity(increments.Quantity, 0.00000001) {
+		t.Errorf("increments %+v, want tick 0.1 and step 0.00000001", increments)
+	}
+
+	if _, err := venue.Increments(context.Background(), models.TradingPair{BaseCurrency: "DOGE", QuoteCurrency: "USDT"}); err == nil {
+		t.Error("expected an error for an unknown pair")
+	}
+}
@@ -0,0 +1,226 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// httpTimeout bounds every REST request to an exchange
+const httpTimeout = 10 * time.Second
+
+// Order sides and types
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+
+	TypeLimit  = "limit"  // Immediate-or-cancel limit order
+	TypeMarket = "market" // Market order, used to hedge
+)
+
+// ErrOrderNotFound is returned by GetOrderByClientID when the exchange has no such order
+var ErrOrderNotFound = errors.New("order not found")
+
+// Order statuses, normalized across exchanges
+const (
+	OrderNew             = "new"              // Accepted, nothing filled yet
+	OrderPartiallyFilled = "partially_filled" // Accepted, partly filled and still working
+	OrderFilled          = "filled"           // Completely filled
+	OrderCanceled        = "canceled"         // Canceled or expired, possibly after a partial fill
+	OrderRejected        = "rejected"         // Refused by the exchange
+)
+
+// Increments are the smallest steps of an order's price and quantity for a pair on an exchange
+// @author VrushankPatel
+// @description Orders must have a price that is a multiple of Price (the tick size) and a quantity
+// that is a multiple of Quantity (the lot step); zero means any value is accepted
+type Increments struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// OrderRequest describes an order to place
+// @author VrushankPatel
+// @description Limit orders are sent immediate-or-cancel so they never rest on the book;
+// Price is ignored for market orders. Price and Quantity are already rounded to Increments,
+// which set how many decimals are sent.
+type OrderRequest struct {
+	Pair       models.TradingPair
+	Side       string
+	Type       string
+	Quantity   float64
+	Price      float64
+	ClientID   string
+	Increments Increments
+}
+
+// Order is the state of an order as reported by the exchange
+// @author VrushankPatel
+// @description AvgPrice is the volume-weighted price of the filled quantity
+type Order struct {
+	ID             string  `json:"id"`
+	ClientID       string  `json:"client_id"`
+	Status         string  `json:"status"`
+	Quantity       float64 `json:"quantity"`
+	FilledQuantity float64 `json:"filled_quantity"`
+	AvgPrice       float64 `json:"avg_price"`
+}
+
+// Terminal reports whether the order can no longer change
+// @author VrushankPatel
+// @description Filled, canceled and rejected orders are terminal
+// @return True if the order is done
+func (o Order) Terminal() bool {
+	return o.Status == OrderFilled || o.Status == OrderCanceled || o.Status == OrderRejected
+}
+
+// Venue places and tracks orders on one exchange through its authenticated REST API
+// @author VrushankPatel
+// @description Implemented per exchange; the base URL is configurable so the executor can run
+// against local mock exchange servers
+type Venue interface {
+	// Name returns the exchange name (e.g., "Binance")
+	Name() string
+
+	// PlaceOrder sends a new order and returns its state right after acceptance
+	PlaceOrder(ctx context.Context, req OrderRequest) (Order, error)
+
+	// GetOrder returns the current state of an order
+	GetOrder(ctx context.Context, pair models.TradingPair, id string) (Order, error)
+
+	// GetOrderByClientID looks an order up by the client id it was placed with, returning
+	// ErrOrderNotFound when the exchange never accepted it
+	GetOrderByClientID(ctx context.Context, pair models.TradingPair, clientID string) (Order, error)
+
+	// CancelOrder cancels an order; canceling an order that is already done is not an error
+	CancelOrder(ctx context.Context, pair models.TradingPair, id string) error
+
+	// Increments returns the price and quantity increments of a pair, loaded once per pair
+	Increments(ctx context.Context, pair models.TradingPair) (Increments, error)
+}
+
+// incrementCache holds the increments of every pair a venue has loaded
+type incrementCache struct {
+	mu    sync.Mutex
+	pairs map[string]Increments
+}
+
+// get returns the increments of a pair, calling load the first time they are needed
+func (c *incrementCache) get(pair string, load func() (Increments, error)) (Increments, error) {
+	c.mu.Lock()
+	increments, exists := c.pairs[pair]
+	c.mu.Unlock()
+	if exists {
+		return increments, nil
+	}
+
+	increments, err := load()
+	if err != nil {
+		return Increments{}, fmt.Errorf("failed to load the increments of %s: %v", pair, err)
+	}
+	if increments.Price < 0 || increments.Quantity < 0 {
+		return Increments{}, fmt.Errorf("invalid increments of %s: price %v, quantity %v", pair, increments.Price, increments.Quantity)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pairs == nil {
+		c.pairs = make(map[string]Increments)
+	}
+	c.pairs[pair] = increments
+	return increments, nil
+}
+
+// newHTTPClient returns the HTTP client used by the venues
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: httpTimeout}
+}
+
+// do sends a request and decodes the JSON response into out, turning non-2xx responses into errors
+func do(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: HTTP %d: %s", req.Method, req.URL.Path, resp.StatusCode, string(body))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	return nil
+}
+
+// formatAmount formats a quantity or price for an order request with as many decimals as its
+// increment has, 8 when the increment is not known
+func formatAmount(value, increment float64) string {
+	return strconv.FormatFloat(value, 'f', decimals(increment), 64)
+}
+
+// decimals returns the number of decimals of an increment, e.g. 2 for 0.01, at most 8
+func decimals(increment float64) int {
+	if increment <= 0 {
+		return 8
+	}
+	for places := 0; places < 8; places++ {
+		scaled := increment * math.Pow10(places)
+		if math.Abs(scaled-math.Round(scaled)) <= 1e-9*scaled {
+			return places
+		}
+	}
+	return 8
+}
+
+// roundUp rounds a value up to a multiple of increment; a zero increment leaves it unchanged
+func roundUp(value, increment float64) float64 {
+	if increment <= 0 {
+		return value
+	}
+	steps := value / increment
+	return math.Ceil(steps-stepTolerance(steps)) * increment
+}
+
+// roundDown rounds a value down to a multiple of increment; a zero increment leaves it unchanged
+func roundDown(value, increment float64) float64 {
+	if increment <= 0 {
+		return value
+	}
+	steps := value / increment
+	return math.Floor(steps+stepTolerance(steps)) * increment
+}
+
+// stepTolerance is how far a number of steps may be from a whole number and still count as one,
+// so that values already on a multiple never move a step through floating point error
+func stepTolerance(steps float64) float64 {
+	return 1e-9 * math.Max(1, math.Abs(steps))
+}
+
+// parseAmount parses a quantity or price from an exchange response, treating empty strings as 0
+func parseAmount(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return parsed
+}
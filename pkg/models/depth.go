@@ -0,0 +1,119 @@
+package models
+
+import (
+        "sort"
+)
+
+// PriceLevel represents the aggregated quantity resting at one price in an order book
+// @author VrushankPatel
+// @description Struct representing a single level of market depth
+type PriceLevel struct {
+        Price    float64 `json:"price"`    // Price of the level in quote currency
+        Quantity float64 `json:"quantity"` // Quantity available at this price in base currency
+}
+
+// DepthBook maintains a level-2 order book from snapshots and incremental updates
+// @author VrushankPatel
+// @description Price-indexed bid and ask levels that exchange clients keep in sync with depth streams.
+// DepthBook is not safe for concurrent use; each exchange connection owns its books.
+type DepthBook struct {
+        bids map[float64]float64
+        asks map[float64]float64
+}
+
+// NewDepthBook creates an empty depth book
+// @author VrushankPatel
+// @description Creates and initializes a new DepthBook
+// @return A pointer to the newly created DepthBook
+func NewDepthBook() *DepthBook {
+        return &DepthBook{
+                bids: make(map[float64]float64),
+                asks: make(map[float64]float64),
+        }
+}
+
+// Reset removes all levels, typically before applying a fresh snapshot
+// @author VrushankPatel
+// @description Clears both sides of the depth book
+func (d *DepthBook) Reset() {
+        d.bids = make(map[float64]float64)
+        d.asks = make(map[float64]float64)
+}
+
+// UpdateBid sets the quantity at a bid price, removing the level when quantity is zero
+// @author VrushankPatel
+// @description Applies a single bid level change from a snapshot or diff
+// @param price The bid price
+// @param quantity The new total quantity at that price
+func (d *DepthBook) UpdateBid(price, quantity float64) {
+        updateLevel(d.bids, price, quantity)
+}
+
+// UpdateAsk sets the quantity at an ask price, removing the level when quantity is zero
+// @author VrushankPatel
+// @description Applies a single ask level change from a snapshot or diff
+// @param price The ask price
+// @param quantity The new total quantity at that price
+func (d *DepthBook) UpdateAsk(price, quantity float64) {
+        updateLevel(d.asks, price, quantity)
+}
+
+// Bids returns up to n bid levels, best (highest) first
+// @author VrushankPatel
+// @description Produces a sorted copy of the top of the bid side
+// @param n The maximum number of levels to return
+// @return The bid levels sorted by descending price
+func (d *DepthBook) Bids(n int) []PriceLevel {
+        return topLevels(d.bids, n, func(a, b float64) bool { return a > b })
+}
+
+// Asks returns up to n ask levels, best (lowest) first
+// @author VrushankPatel
+// @description Produces a sorted copy of the top of the ask side
+// @param n The maximum number of levels to return
+// @return The ask levels sorted by ascending price
+func (d *DepthBook) Asks(n int) []PriceLevel {
+        return topLevels(d.asks, n, func(a, b float64) bool { return a < b })
+}
+
+// Truncate drops every level beyond the best n on each side
+// @author VrushankPatel
+// @description Keeps the book within the depth the exchange maintains for a subscription
+// @param n The number of levels to keep per side
+func (d *DepthBook) Truncate(n int) {
+        d.bids = levelMap(d.Bids(n))
+        d.asks = levelMap(d.Asks(n))
+}
+
+// updateLevel sets or removes a price level on one side of a depth book
+func updateLevel(side map[float64]float64, price, quantity float64) {
+        if quantity <= 0 {
+                delete(side, price)
+                return
+        }
+        side[price] = quantity
+}
+
+// topLevels returns up to n levels of one side sorted with the given price ordering
+func topLevels(side map[float64]float64, n int, better func(a, b float64) bool) []PriceLevel {
+        levels := make([]PriceLevel, 0, len(side))
+        for price, quantity := range side {
+                levels = append(levels, PriceLevel{Price: price, Quantity: quantity})
+        }
+        sort.Slice(levels, func(i, j int) bool {
+                return better(levels[i].Price, levels[j].Price)
+        })
+        if n > 0 && len(levels) > n {
+                levels = levels[:n]
+        }
+        return levels
+}
+
+// levelMap converts sorted levels back into a price-indexed map
+func levelMap(levels []PriceLevel) map[float64]float64 {
+        side := make(map[float64]float64, len(levels))
+        for _, level := range levels {
+                side[level.Price] = level.Quantity
+        }
+        return side
+}
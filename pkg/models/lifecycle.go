@@ -0,0 +1,49 @@
+package models
+
+import (
+        "fmt"
+        "strings"
+)
+
+// Opportunity statuses reported in ArbitrageOpportunity.Status
+const (
+        OpportunityStatusOpen   = "open"   // The opportunity was seen on the latest evaluation of its route
+        OpportunityStatusClosed = "closed" // The opportunity has disappeared
+)
+
+// Opportunity event types reported in OpportunityEvent.Type
+const (
+        OpportunityEventOpened  = "opened"  // First observation of an opportunity
+        OpportunityEventUpdated = "updated" // Later observation while the opportunity stays open
+        OpportunityEventClosed  = "closed"  // The opportunity is gone; carries its final state
+)
+
+// OpportunityEvent is one step in the lifecycle of an arbitrage opportunity
+// @author VrushankPatel
+// @description Published by the detector when an opportunity opens, changes or closes
+type OpportunityEvent struct {
+        Type        string               `json:"type"`        // OpportunityEventOpened, OpportunityEventUpdated or OpportunityEventClosed
+        Opportunity ArbitrageOpportunity `json:"opportunity"` // State of the opportunity after the event
+}
+
+// RouteID returns the stable identifier of the route an opportunity trades
+// @author VrushankPatel
+// @description Cross-exchange opportunities are identified by type, pair, buy and sell exchange
+// (e.g., "cross_exchange:BTC/USDT:Binance>Kraken"); multi-leg opportunities by type and legs
+// (e.g., "triangular:Binance:USDT>BTC|Binance:BTC>ETH|Binance:ETH>USDT")
+// @return The route identifier
+func (o ArbitrageOpportunity) RouteID() string {
+        if len(o.Legs) == 0 {
+                return fmt.Sprintf("%s:%s/%s:%s>%s", o.Type, o.BaseCurrency, o.QuoteCurrency, o.BuyExchange, o.SellExchange)
+        }
+
+        legs := make([]string, 0, len(o.Legs))
+        for _, leg := range o.Legs {
+                exchange := leg.Exchange
+                if leg.ToExchange != "" {
+                        exchange += ">" + leg.ToExchange
+                }
+                legs = append(legs, fmt.Sprintf("%s:%s>%s", exchange, leg.From, leg.To))
+        }
+        return o.Type + ":" + strings.Join(legs, "|")
+}
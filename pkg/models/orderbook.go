@@ -15,14 +15,74 @@ type OrderBook struct {
         QuoteCurrency string    `json:"quote_currency"`// Quote currency of the trading pair (e.g., "USDT")
         Bid           float64   `json:"bid"`           // Current best bid price (highest buy offer)
         Ask           float64   `json:"ask"`           // Current best ask price (lowest sell offer)
+        Bids          []PriceLevel `json:"bids,omitempty"` // Bid depth, best first (empty when only top of book is known)
+        Asks          []PriceLevel `json:"asks,omitempty"` // Ask depth, best first (empty when only top of book is known)
         LastUpdate    time.Time `json:"last_update"`   // Timestamp of the last update to this order book
 }
 
+// Key returns the identifier of the order book, combining exchange and trading pair
+// @author VrushankPatel
+// @description Builds the key used by OrderBookStore so each exchange can publish several symbols
+// @return The OrderBookKey for this order book
+func (ob OrderBook) Key() OrderBookKey {
+        return OrderBookKey{
+                Exchange:      ob.Exchange,
+                BaseCurrency:  ob.BaseCurrency,
+                QuoteCurrency: ob.QuoteCurrency,
+        }
+}
+
+// Pair returns the trading pair of the order book
+// @author VrushankPatel
+// @description Extracts the standardized trading pair from an order book
+// @return The TradingPair quoted by this order book
+func (ob OrderBook) Pair() TradingPair {
+        return TradingPair{BaseCurrency: ob.BaseCurrency, QuoteCurrency: ob.QuoteCurrency}
+}
+
+// Opportunity types reported in ArbitrageOpportunity.Type
+const (
+        OpportunityCrossExchange = "cross_exchange" // Buy on one exchange, sell on another
+        OpportunityTriangular    = "triangular"     // Three-leg cycle within a single exchange
+        OpportunityCycle         = "cycle"          // Multi-leg cycle across exchanges and assets
+)
+
+// Balance statuses reported in ArbitrageOpportunity.BalanceStatus when account balances are tracked
+const (
+        BalanceSufficient   = "sufficient"   // The balances fund the whole Quantity
+        BalancePartial      = "partial"      // The balances fund only ExecutableQuantity
+        BalanceInsufficient = "insufficient" // The balances fund nothing; the opportunity cannot be acted on
+)
+
+// TradeLeg represents one conversion step of a multi-leg arbitrage opportunity
+// @author VrushankPatel
+// @description Struct describing a single trade in the leg sequence of an opportunity
+type TradeLeg struct {
+        Exchange      string  `json:"exchange"`       // Exchange the leg trades on (source exchange for transfers)
+        ToExchange    string  `json:"to_exchange,omitempty"` // Destination exchange for transfer legs
+        BaseCurrency  string  `json:"base_currency"`  // Base currency of the market traded
+        QuoteCurrency string  `json:"quote_currency"` // Quote currency of the market traded
+        Side          string  `json:"side"`           // "buy" or "sell" of the base currency, or "transfer" between exchanges
+        Price         float64 `json:"price"`          // Top of book price the leg executes at
+        From          string  `json:"from"`           // Currency spent
+        To            string  `json:"to"`             // Currency received
+        Rate          float64 `json:"rate"`           // Units of To received per unit of From, after fees
+}
+
 // ArbitrageOpportunity represents a potential arbitrage opportunity between exchanges
 // @author VrushankPatel
 // @description Struct representing an arbitrage opportunity detected between two exchanges
+// or, for triangular opportunities, along a cycle of markets on one exchange
 type ArbitrageOpportunity struct {
-        Timestamp        time.Time `json:"timestamp"`       // Time when the opportunity was detected
+        ID               string    `json:"id"`              // Stable identifier of the route (see RouteID)
+        Status           string    `json:"status"`          // OpportunityStatusOpen while the window lasts, OpportunityStatusClosed afterwards
+        Type             string    `json:"type"`            // Kind of opportunity (OpportunityCrossExchange, OpportunityTriangular)
+        Timestamp        time.Time `json:"timestamp"`       // Time when the opportunity was last observed
+        OpenedAt         time.Time `json:"opened_at"`       // Time when the opportunity was first observed
+        ClosedAt         *time.Time `json:"closed_at,omitempty"` // Time when the opportunity disappeared
+        LifetimeSeconds  float64   `json:"lifetime_seconds"` // Seconds from OpenedAt to the last observation or close
+        Observations     int       `json:"observations"`    // Number of detection passes that saw the opportunity
+        PeakProfitPercentage float64 `json:"peak_profit_percentage"` // Highest ProfitPercentage observed while open
         BaseCurrency     string    `json:"base_currency"`   // Base currency of the trading pair (e.g., "BTC")
         QuoteCurrency    string    `json:"quote_currency"`  // Quote currency of the trading pair (e.g., "USDT")
         BuyExchange      string    `json:"buy_exchange"`    // Exchange where the asset should be bought
@@ -30,7 +90,33 @@ type ArbitrageOpportunity struct {
         BuyPrice         float64   `json:"buy_price"`       // Price to buy at on the buy exchange
         SellPrice        float64   `json:"sell_price"`      // Price to sell at on the sell exchange
         ProfitPercentage float64   `json:"profit_percentage"`// Profit as a percentage (e.g., 1.5 means 1.5%)
-        NetProfit        float64   `json:"net_profit"`      // Net profit in quote currency (e.g., USDT)
+        Quantity         float64   `json:"quantity"`        // Trade size in base currency that NetProfit is for (0 for multi-leg opportunities)
+        NetProfit        float64   `json:"net_profit"`      // Net profit in quote currency (e.g., USDT) for Quantity; per unit of the starting currency for multi-leg opportunities
+        MaxQuantity      float64   `json:"max_quantity"`    // Largest size in base currency that stays above the profit threshold (0 when depth is unknown)
+        BuyVWAP          float64   `json:"buy_vwap"`        // Volume-weighted buy price over MaxQuantity
+        SellVWAP         float64   `json:"sell_vwap"`       // Volume-weighted sell price over MaxQuantity
+        MaxProfit        float64   `json:"max_profit"`      // Total net profit in quote currency when trading MaxQuantity
+        ExecutableQuantity float64 `json:"executable_quantity,omitempty"` // Part of Quantity the account balances can fund (with BalanceStatus)
+        BalanceStatus    string    `json:"balance_status,omitempty"` // Whether the account balances fund Quantity; empty when balances are not tracked
+        Legs             []TradeLeg `json:"legs,omitempty"` // Leg sequence for multi-leg opportunities
+        Costs            *CostBreakdown `json:"costs,omitempty"` // Itemized costs behind NetProfit (cross-exchange opportunities)
+        Simulated        bool      `json:"simulated,omitempty"` // True when detected on simulated market data
+}
+
+// CostBreakdown itemizes what an opportunity costs to execute, so it is visible which
+// component eats the edge
+// @author VrushankPatel
+// @description All amounts are in the quote currency, for the traded Quantity. Transfer fees
+// are charged in the transferred asset and valued at the sell price.
+type CostBreakdown struct {
+        Quantity            float64 `json:"quantity"`              // Size the costs apply to, in base currency
+        GrossProfit         float64 `json:"gross_profit"`          // Proceeds minus purchase cost before any fees
+        TradingFees         float64 `json:"trading_fees"`          // Taker fees of the buy and sell legs
+        WithdrawalFee       float64 `json:"withdrawal_fee"`        // Fee charged by the buy exchange to withdraw the asset
+        NetworkFee          float64 `json:"network_fee"`           // On-chain fee to move the asset to the sell exchange
+        TotalCost           float64 `json:"total_cost"`            // Sum of all fees
+        TransferAsset       string  `json:"transfer_asset"`        // Asset moved between the exchanges
+        TransferTimeSeconds float64 `json:"transfer_time_seconds"` // Time until the deposit is credited on the sell exchange
 }
 
 // TradingPair represents a cryptocurrency trading pair
@@ -41,6 +127,14 @@ type TradingPair struct {
         QuoteCurrency string // The quote currency (e.g., USDT, USD)
 }
 
+// String returns the trading pair in BASE/QUOTE notation
+// @author VrushankPatel
+// @description Formats the trading pair for logs and display
+// @return The trading pair as a string (e.g., "BTC/USDT")
+func (tp TradingPair) String() string {
+        return tp.BaseCurrency + "/" + tp.QuoteCurrency
+}
+
 // GetSymbol returns the formatted symbol for a trading pair based on the exchange format
 // @author VrushankPatel
 // @description Converts a standard trading pair to the specific format required by different exchanges
@@ -0,0 +1,30 @@
+package models
+
+// TradeSize is the amount traded per opportunity on a trading pair
+// @author VrushankPatel
+// @description Expressed in either the base or the quote currency of the pair; quote amounts
+// are converted to base quantities at the buy price
+type TradeSize struct {
+        Amount   float64 `json:"amount"`   // Size in units of Currency
+        Currency string  `json:"currency"` // Base or quote currency of the pair
+}
+
+// BaseQuantity returns the trade size in base currency
+// @author VrushankPatel
+// @description Converts a quote currency amount at the given price; base amounts are returned as is
+// @param pair The trading pair traded
+// @param price Price of one unit of base currency in quote currency
+// @return The quantity in base currency, or 0 if it cannot be determined
+func (ts TradeSize) BaseQuantity(pair TradingPair, price float64) float64 {
+        switch ts.Currency {
+        case pair.BaseCurrency:
+                return ts.Amount
+        case pair.QuoteCurrency:
+                if price <= 0 {
+                        return 0
+                }
+                return ts.Amount / price
+        default:
+                return 0
+        }
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+        "sort"
+        "sync"
+)
+
+// OrderBookKey identifies an order book by exchange and trading pair
+// @author VrushankPatel
+// @description Composite key used by OrderBookStore so an exchange can publish one book per symbol
+type OrderBookKey struct {
+        Exchange      string // Name of the exchange (e.g., "Binance")
+        BaseCurrency  string // Base currency of the trading pair (e.g., "BTC")
+        QuoteCurrency string // Quote currency of the trading pair (e.g., "USDT")
+}
+
+// String returns the key in the form "Exchange:BASE/QUOTE"
+// @author VrushankPatel
+// @description Formats the key for logs and JSON map keys
+// @return The key as a string (e.g., "Binance:BTC/USDT")
+func (k OrderBookKey) String() string {
+        return k.Exchange + ":" + k.BaseCurrency + "/" + k.QuoteCurrency
+}
+
+// OrderBookStore is a thread-safe store of the latest order book per exchange and trading pair
+// @author VrushankPatel
+// @description Shared store written by exchange clients and read by the detector and web server.
+// All accessors hand out copies so callers never observe a book while it is being updated.
+type OrderBookStore struct {
+        mu        sync.RWMutex
+        books     map[OrderBookKey]*OrderBook
+        listeners []OrderBookListener
+}
+
+// OrderBookListener is called with a copy of every order book stored in an OrderBookStore
+type OrderBookListener func(book OrderBook)
+
+// NewOrderBookStore creates an empty order book store
+// @author VrushankPatel
+// @description Creates and initializes a new OrderBookStore
+// @return A pointer to the newly created OrderBookStore
+func NewOrderBookStore() *OrderBookStore {
+        return &OrderBookStore{
+                books: make(map[OrderBookKey]*OrderBook),
+        }
+}
+
+// Update stores a copy of the order book, replacing any previous book for the same key
+// @author VrushankPatel
+// @description Inserts or replaces the order book for its exchange and trading pair
+// @param book The order book to store
+func (s *OrderBookStore) Update(book OrderBook) {
+        s.mu.Lock()
+        stored := book
+        s.books[book.Key()] = &stored
+        listeners := s.listeners
+        s.mu.Unlock()
+
+        for _, listener := range listeners {
+                listener(book)
+        }
+}
+
+// Remove deletes the order book of an exchange and trading pair
+// @author VrushankPatel
+// @description Used when an exchange stops streaming a pair, so its last book is not mistaken for a live one
+// @param exchange The name of the exchange (e.g., "Binance")
+// @param pair The trading pair
+func (s *OrderBookStore) Remove(exchange string, pair TradingPair) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        delete(s.books, OrderBookKey{
+                Exchange:      exchange,
+                BaseCurrency:  pair.BaseCurrency,
+                QuoteCurrency: pair.QuoteCurrency,
+        })
+}
+
+// Subscribe registers a listener that is called after every order book update
+// @author VrushankPatel
+// @description Listeners run synchronously on the updating exchange's goroutine, outside the
+// store lock, so they must return quickly and hand slow work off to their own goroutine
+// @param listener The function to call with each updated order book
+func (s *OrderBookStore) Subscribe(listener OrderBookListener) {
+        s.mu.Lock()
+        defer s.mu.Unlock()
+        s.listeners = append(s.listeners, listener)
+}
+
+// Get returns the order book for an exchange and trading pair
+// @author VrushankPatel
+// @description Looks up a single order book by exchange and trading pair
+// @param exchange The name of the exchange (e.g., "Binance")
+// @param pair The trading pair
+// @return A copy of the order book and whether it was found
+func (s *OrderBookStore) Get(exchange string, pair TradingPair) (OrderBook, bool) {
+        s.mu.RLock()
+        defer s.mu.RUnlock()
+        book, exists := s.books[OrderBookKey{
+                Exchange:      exchange,
+                BaseCurrency:  pair.BaseCurrency,
+                QuoteCurrency: pair.QuoteCurrency,
+        }]
+        if !exists {
+                return OrderBook{}, false
+        }
+        return *book, true
+}
+
+// Len returns the number of order books in the store
+// @author VrushankPatel
+// @description Counts the stored order books across all exchanges and pairs
+// @return The number of order books
+func (s *OrderBookStore) Len() int {
+        s.mu.RLock()
+        defer s.mu.RUnlock()
+        return len(s.books)
+}
+
+// Snapshot returns copies of all order books sorted by pair and exchange
+// @author VrushankPatel
+// @description Takes a consistent point-in-time copy of every stored order book
+// @return The order books sorted by base, quote and exchange
+func (s *OrderBookStore) Snapshot() []OrderBook {
+        s.mu.RLock()
+        books := make([]OrderBook, 0, len(s.books))
+        for _, book := range s.books {
+                books = append(books, *book)
+        }
+        s.mu.RUnlock()
+
+        sort.Slice(books, func(i, j int) bool {
+                if books[i].BaseCurrency != books[j].BaseCurrency {
+                        return books[i].BaseCurrency < books[j].BaseCurrency
+                }
+                if books[i].QuoteCurrency != books[j].QuoteCurrency {
+                        return books[i].QuoteCurrency < books[j].QuoteCurrency
+                }
+                return books[i].Exchange < books[j].Exchange
+        })
+        return books
+}
+
+// SnapshotForPair returns copies of the order books of every exchange quoting a trading pair
+// @author VrushankPatel
+// @description Collects one trading pair's order books across exchanges
+// @param pair The trading pair
+// @return The order books sorted by exchange name
+func (s *OrderBookStore) SnapshotForPair(pair TradingPair) []OrderBook {
+        books := make([]OrderBook, 0)
+        for _, book := range s.Snapshot() {
+                if book.Pair() == pair {
+                        books = append(books, book)
+                }
+        }
+        return books
+}
+
+// SnapshotByPair returns copies of all order books grouped by trading pair
+// @author VrushankPatel
+// @description Groups a point-in-time copy of the store by trading pair for cross-exchange comparison
+// @return Map of trading pairs to their order books sorted by exchange name
+func (s *OrderBookStore) SnapshotByPair() map[TradingPair][]OrderBook {
+        grouped := make(map[TradingPair][]OrderBook)
+        for _, book := range s.Snapshot() {
+                grouped[book.Pair()] = append(grouped[book.Pair()], book)
+        }
+        return grouped
+}
+
+// SnapshotMap returns copies of all order books keyed by their string key
+// @author VrushankPatel
+// @description Builds a JSON-friendly view of the store for the web API
+// @return Map of "Exchange:BASE/QUOTE" keys to order books
+func (s *OrderBookStore) SnapshotMap() map[string]OrderBook {
+        s.mu.RLock()
+        defer s.mu.RUnlock()
+        books := make(map[string]OrderBook, len(s.books))
+        for key, book := range s.books {
+                books[key.String()] = *book
+        }
+        return books
+}
+
+// Pairs returns the distinct trading pairs present in the store
+// @author VrushankPatel
+// @description Lists every trading pair quoted by at least one exchange
+// @return The trading pairs sorted by base and quote currency
+func (s *OrderBookStore) Pairs() []TradingPair {
+        pairs := make([]TradingPair, 0)
+        for _, book := range s.Snapshot() {
+                if len(pairs) == 0 || pairs[len(pairs)-1] != book.Pair() {
+                        pairs = append(pairs, book.Pair())
+                }
+        }
+        return pairs
+}
+
+// ForEach calls fn with a copy of every order book, sorted by pair and exchange
+// @author VrushankPatel
+// @description Iterates over a snapshot of the store without holding its lock during fn
+// @param fn The function to call for each order book
+func (s *OrderBookStore) ForEach(fn func(OrderBook)) {
+        for _, book := range s.Snapshot() {
+                fn(book)
+        }
+}
@@ -0,0 +1,109 @@
+package paper
+
+import (
+	"time"
+)
+
+// Trade statuses reported in Trade.Status
+const (
+	TradeFilled   = "filled"   // Both legs filled the requested quantity
+	TradePartial  = "partial"  // Both legs filled less than requested, limited by size or balances
+	TradeRejected = "rejected" // Nothing was traded, see Trade.Reason
+)
+
+// Leg is one side of a paper trade
+// @author VrushankPatel
+// @description Price is the volume-weighted fill price; the fee is in quote currency
+type Leg struct {
+	Exchange string  `json:"exchange"`
+	Side     string  `json:"side"`
+	Price    float64 `json:"price"`
+	Fee      float64 `json:"fee"`
+}
+
+// Trade is one simulated execution of an arbitrage opportunity
+// @author VrushankPatel
+// @description Both legs always fill the same quantity, so the engine never ends up holding
+// an unhedged position. ExpectedProfit is what the detector reported, RealizedPnL what the
+// fills at execution time gave after fees.
+type Trade struct {
+	ID                int       `json:"id"`
+	OpportunityID     string    `json:"opportunity_id"`
+	DetectedAt        time.Time `json:"detected_at"`
+	ExecutedAt        time.Time `json:"executed_at"`
+	BaseCurrency      string    `json:"base_currency"`
+	QuoteCurrency     string    `json:"quote_currency"`
+	Status            string    `json:"status"`
+	Reason            string    `json:"reason,omitempty"`
+	RequestedQuantity float64   `json:"requested_quantity"`
+	Quantity          float64   `json:"quantity"`
+	Buy               Leg       `json:"buy"`
+	Sell              Leg       `json:"sell"`
+	ExpectedProfit    float64   `json:"expected_profit"`
+	RealizedPnL       float64   `json:"realized_pnl"`
+}
+
+// Ledger is a snapshot of the paper trading account
+// @author VrushankPatel
+// @description Balances and drift are per exchange and asset. Drift is how far each balance has
+// moved from where it started: realized PnL shows up in the quote currencies, while base assets
+// pile up on the exchanges that are bought on and drain from the ones that are sold on.
+type Ledger struct {
+	StartedAt       time.Time                     `json:"started_at"`
+	LatencyMs       float64                       `json:"latency_ms"`
+	InitialBalances map[string]map[string]float64 `json:"initial_balances"`
+	Balances        map[string]map[string]float64 `json:"balances"`
+	Drift           map[string]map[string]float64 `json:"drift"`
+	RealizedPnL     map[string]float64            `json:"realized_pnl"`
+	Filled          int                           `json:"filled"`
+	Partial         int                           `json:"partial"`
+	Rejected        int                           `json:"rejected"`
+	Trades          []Trade                       `json:"trades"`
+}
+
+// Ledger returns a snapshot of the balances, PnL and recent trades
+// @author VrushankPatel
+// @description Safe to call while the engine is running; trades are newest first
+// @return The ledger snapshot
+func (e *Engine) Ledger() Ledger {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ledger := Ledger{
+		StartedAt:       e.startedAt,
+		LatencyMs:       float64(e.latency) / float64(time.Millisecond),
+		InitialBalances: copyBalances(e.initial),
+		Balances:        copyBalances(e.balances),
+		Drift:           make(map[string]map[string]float64),
+		RealizedPnL:     make(map[string]float64, len(e.realized)),
+		Filled:          e.filled,
+		Partial:         e.partial,
+		Rejected:        e.rejected,
+		Trades:          make([]Trade, 0, len(e.trades)),
+	}
+	for exchange, balances := range e.balances {
+		ledger.Drift[exchange] = make(map[string]float64, len(balances))
+		for asset, balance := range balances {
+			ledger.Drift[exchange][asset] = balance - e.initial[exchange][asset]
+		}
+	}
+	for currency, pnl := range e.realized {
+		ledger.RealizedPnL[currency] = pnl
+	}
+	for i := len(e.trades) - 1; i >= 0; i-- {
+		ledger.Trades = append(ledger.Trades, e.trades[i])
+	}
+	return ledger
+}
+
+// copyBalances deep copies balances per exchange and asset
+func copyBalances(balances map[string]map[string]float64) map[string]map[string]float64 {
+	copied := make(map[string]map[string]float64, len(balances))
+	for exchange, assets := range balances {
+		copied[exchange] = make(map[string]float64, len(assets))
+		for asset, balance := range assets {
+			copied[exchange][asset] = balance
+		}
+	}
+	return copied
+}
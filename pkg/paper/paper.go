@@ -0,0 +1,336 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"apex-arbitrage/pkg/clock"
+	"apex-arbitrage/pkg/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// pollInterval is how often orders whose latency has passed are executed
+	pollInterval = 10 * time.Millisecond
+
+	// maxTrades is the number of trades kept in the ledger
+	maxTrades = 500
+
+	// dust is the smallest quantity worth trading
+	dust = 1e-9
+)
+
+// Engine simulates executing arbitrage opportunities against virtual balances
+// @author VrushankPatel
+// @description Registered as an opportunity handler, it sends both legs of every newly opened
+// cross-exchange opportunity after the configured latency, filling them against the order books
+// as they are at that moment. Each exchange starts with the same virtual balance of every asset;
+// the buy leg spends quote currency on the buy exchange and the sell leg sells base currency held
+// on the sell exchange, so no transfers are simulated and inventory drifts between exchanges.
+type Engine struct {
+	orderBooks *models.OrderBookStore
+	fees       map[string]float64
+	latency    time.Duration
+	clock      clock.Clock
+	onPnL      func(pnl float64)
+	onReject   func(opp models.ArbitrageOpportunity)
+
+	mu        sync.Mutex
+	startedAt time.Time
+	initial   map[string]map[string]float64
+	balances  map[string]map[string]float64
+	pending   []order
+	trades    []Trade
+	nextID    int
+	realized  map[string]float64
+	filled    int
+	partial   int
+	rejected  int
+}
+
+// order is an opportunity waiting for its simulated latency to pass
+type order struct {
+	opportunity models.ArbitrageOpportunity
+	due         time.Time
+}
+
+// New creates a paper trading engine
+// @author VrushankPatel
+// @description Creates an engine holding the starting balances on every exchange with a fee
+// @param orderBooks The order books the legs are filled against
+// @param exchangeFees Map of exchange names to their taker fee rates as decimals
+// @param balances Starting balance per asset, given to every exchange
+// @param latency Delay between an opportunity opening and its legs executing
+// @return A pointer to the newly created Engine
+func New(orderBooks *models.OrderBookStore, exchangeFees map[string]float64, balances map[string]float64, latency time.Duration) *Engine {
+	e := &Engine{
+		orderBooks: orderBooks,
+		fees:       make(map[string]float64, len(exchangeFees)),
+		latency:    latency,
+		clock:      clock.Real(),
+		initial:    make(map[string]map[string]float64, len(exchangeFees)),
+		realized:   make(map[string]float64),
+	}
+	for exchange, fee := range exchangeFees {
+		e.fees[exchange] = fee
+		e.initial[exchange] = make(map[string]float64, len(balances))
+		for asset, balance := range balances {
+			e.initial[exchange][asset] = balance
+		}
+	}
+	e.balances = copyBalances(e.initial)
+	e.startedAt = e.clock.Now()
+	return e
+}
+
+// SetClock replaces the wall clock used for latency and trade timestamps
+// @author VrushankPatel
+// @description Lets tests and replays drive the engine on a controlled clock; call before Start
+// @param c The clock to use
+func (e *Engine) SetClock(c clock.Clock) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.clock = c
+	e.startedAt = c.Now()
+}
+
+// SetFees replaces the taker fees charged on the legs of trades executed from now on
+// @author VrushankPatel
+// @description Safe to call while the engine runs; trades already executed keep their fees.
+// Exchanges missing from the map are no longer charged a fee.
+// @param exchangeFees Map of exchange names to their taker fee rates as decimals
+func (e *Engine) SetFees(exchangeFees map[string]float64) {
+	fees := make(map[string]float64, len(exchangeFees))
+	for exchange, fee := range exchangeFees {
+		fees[exchange] = fee
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fees = fees
+}
+
+// SetPnLHandler registers a function called with the realized PnL of every executed trade
+// @author VrushankPatel
+// @description Feeds the daily loss limit of the risk manager; call before Start
+// @param handler The function to call, with the PnL in quote currency
+func (e *Engine) SetPnLHandler(handler func(pnl float64)) {
+	e.onPnL = handler
+}
+
+// SetDeclineHandler registers a function called with every opportunity whose trade is rejected
+// @author VrushankPatel
+// @description Lets the risk manager release the exposure it reserved; call before Start
+// @param handler The function to call with the rejected opportunity
+func (e *Engine) SetDeclineHandler(handler func(opp models.ArbitrageOpportunity)) {
+	e.onReject = handler
+}
+
+// HandleOpportunityEvent queues newly opened cross-exchange opportunities for execution
+// @author VrushankPatel
+// @description Updates and closes are ignored, so each opportunity is traded at most once.
+// Multi-leg opportunities are not traded.
+// @param event The opportunity lifecycle event
+func (e *Engine) HandleOpportunityEvent(event models.OpportunityEvent) {
+	if event.Type != models.OpportunityEventOpened || event.Opportunity.Type != models.OpportunityCrossExchange {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending = append(e.pending, order{
+		opportunity: event.Opportunity,
+		due:         e.clock.Now().Add(e.latency),
+	})
+}
+
+// Start executes queued orders once their latency has passed
+// @author VrushankPatel
+// @description Runs until the context is cancelled
+// @param ctx Context used for cancellation and shutdown signals
+func (e *Engine) Start(ctx context.Context) {
+	ticker := e.clock.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.logSummary()
+			return
+		case <-ticker.C():
+			e.executeDue()
+		}
+	}
+}
+
+// executeDue executes every pending order whose latency has passed
+func (e *Engine) executeDue() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.clock.Now()
+	remaining := e.pending[:0]
+	for _, o := range e.pending {
+		if o.due.After(now) {
+			remaining = append(remaining, o)
+			continue
+		}
+		trade := e.execute(o.opportunity, now)
+		e.record(trade)
+		if trade.Status == TradeRejected && e.onReject != nil {
+			e.onReject(o.opportunity)
+		}
+	}
+	e.pending = remaining
+}
+
+// execute fills both legs of an opportunity against the current order books and balances
+func (e *Engine) execute(opp models.ArbitrageOpportunity, now time.Time) Trade {
+	trade := Trade{
+		OpportunityID:     opp.ID,
+		DetectedAt:        opp.OpenedAt,
+		ExecutedAt:        now,
+		BaseCurrency:      opp.BaseCurrency,
+		QuoteCurrency:     opp.QuoteCurrency,
+		RequestedQuantity: opp.Quantity,
+		ExpectedProfit:    opp.NetProfit,
+		Buy:               Leg{Exchange: opp.BuyExchange, Side: "buy"},
+		Sell:              Leg{Exchange: opp.SellExchange, Side: "sell"},
+	}
+	if trade.RequestedQuantity <= 0 {
+		trade.RequestedQuantity = opp.MaxQuantity
+	}
+	if trade.RequestedQuantity <= 0 {
+		return reject(trade, "opportunity has no trade size")
+	}
+
+	pair := models.TradingPair{BaseCurrency: opp.BaseCurrency, QuoteCurrency: opp.QuoteCurrency}
+	buyBook, buyOk := e.orderBooks.Get(opp.BuyExchange, pair)
+	sellBook, sellOk := e.orderBooks.Get(opp.SellExchange, pair)
+	if !buyOk || !sellOk || buyBook.Ask <= 0 || sellBook.Bid <= 0 {
+		return reject(trade, "order book unavailable")
+	}
+	if e.balances[opp.BuyExchange] == nil || e.balances[opp.SellExchange] == nil {
+		return reject(trade, "no paper account on exchange")
+	}
+	buyFee := e.fees[opp.BuyExchange]
+	sellFee := e.fees[opp.SellExchange]
+
+	// Fill what both books offer, then shrink to what the balances allow: quote currency
+	// on the buy exchange including the fee, and base currency on the sell exchange
+	quantity, _ := fill(buyBook.Asks, buyBook.Ask, trade.RequestedQuantity)
+	if sold, _ := fill(sellBook.Bids, sellBook.Bid, quantity); sold < quantity {
+		quantity = sold
+	}
+	if held := e.balances[opp.SellExchange][opp.BaseCurrency]; held < quantity {
+		quantity = held
+	}
+	if quantity > dust {
+		_, cost := fill(buyBook.Asks, buyBook.Ask, quantity)
+		if budget := e.balances[opp.BuyExchange][opp.QuoteCurrency]; cost*(1+buyFee) > budget {
+			// The average price only falls as the quantity shrinks, so this stays within budget
+			quantity = budget / (cost / quantity * (1 + buyFee))
+		}
+	}
+	if quantity <= dust {
+		return reject(trade, "no liquidity or insufficient balance")
+	}
+
+	_, cost := fill(buyBook.Asks, buyBook.Ask, quantity)
+	_, proceeds := fill(sellBook.Bids, sellBook.Bid, quantity)
+
+	trade.Quantity = quantity
+	trade.Buy.Price = cost / quantity
+	trade.Buy.Fee = cost * buyFee
+	trade.Sell.Price = proceeds / quantity
+	trade.Sell.Fee = proceeds * sellFee
+	trade.RealizedPnL = proceeds - trade.Sell.Fee - cost - trade.Buy.Fee
+	trade.Status = TradeFilled
+	if quantity < trade.RequestedQuantity*(1-1e-9) {
+		trade.Status = TradePartial
+	}
+
+	e.balances[opp.BuyExchange][opp.QuoteCurrency] -= cost + trade.Buy.Fee
+	e.balances[opp.BuyExchange][opp.BaseCurrency] += quantity
+	e.balances[opp.SellExchange][opp.BaseCurrency] -= quantity
+	e.balances[opp.SellExchange][opp.QuoteCurrency] += proceeds - trade.Sell.Fee
+	e.realized[opp.QuoteCurrency] += trade.RealizedPnL
+	if e.onPnL != nil {
+		e.onPnL(trade.RealizedPnL)
+	}
+	return trade
+}
+
+// record adds an executed or rejected trade to the ledger
+func (e *Engine) record(trade Trade) {
+	e.nextID++
+	trade.ID = e.nextID
+	e.trades = append(e.trades, trade)
+	if len(e.trades) > maxTrades {
+		e.trades = e.trades[len(e.trades)-maxTrades:]
+	}
+
+	fields := log.Fields{
+		"id":        trade.OpportunityID,
+		"status":    trade.Status,
+		"requested": fmt.Sprintf("%.8f %s", trade.RequestedQuantity, trade.BaseCurrency),
+		"latency":   trade.ExecutedAt.Sub(trade.DetectedAt).String(),
+		"expected":  fmt.Sprintf("%.2f %s", trade.ExpectedProfit, trade.QuoteCurrency),
+	}
+	switch trade.Status {
+	case TradeRejected:
+		e.rejected++
+		fields["reason"] = trade.Reason
+		log.WithFields(fields).Debug("[Paper] Trade rejected")
+		return
+	case TradePartial:
+		e.partial++
+	default:
+		e.filled++
+	}
+	fields["quantity"] = fmt.Sprintf("%.8f %s", trade.Quantity, trade.BaseCurrency)
+	fields["buy"] = fmt.Sprintf("%s @ %.4f", trade.Buy.Exchange, trade.Buy.Price)
+	fields["sell"] = fmt.Sprintf("%s @ %.4f", trade.Sell.Exchange, trade.Sell.Price)
+	fields["realized_pnl"] = fmt.Sprintf("%.2f %s", trade.RealizedPnL, trade.QuoteCurrency)
+	log.WithFields(fields).Info("[Paper] Trade executed")
+}
+
+// logSummary logs the realized PnL when the engine stops
+func (e *Engine) logSummary() {
+	ledger := e.Ledger()
+	for currency, pnl := range ledger.RealizedPnL {
+		log.Infof("[Paper] Realized PnL: %.4f %s (%d filled, %d partial, %d rejected)", pnl, currency, ledger.Filled, ledger.Partial, ledger.Rejected)
+	}
+}
+
+// reject marks a trade as rejected for the given reason
+func reject(trade Trade, reason string) Trade {
+	trade.Status = TradeRejected
+	trade.Reason = reason
+	return trade
+}
+
+// fill walks the levels of one side of a book to fill up to quantity, returning the quantity
+// filled and its total value. Without depth the whole quantity fills at the best price.
+func fill(levels []models.PriceLevel, best, quantity float64) (float64, float64) {
+	if len(levels) == 0 {
+		return quantity, quantity * best
+	}
+
+	filled, value := 0.0, 0.0
+	for _, level := range levels {
+		take := quantity - filled
+		if take <= 0 {
+			break
+		}
+		if level.Quantity < take {
+			take = level.Quantity
+		}
+		filled += take
+		value += take * level.Price
+	}
+	return filled, value
+}
@@ -0,0 +1,153 @@
+package rebalance
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Allocation is the balance of one asset on one exchange compared to its target
+// @author VrushankPatel
+// @description Shares are fractions of the asset's total across exchanges
+type Allocation struct {
+	Exchange    string  `json:"exchange"`
+	Asset       string  `json:"asset"`
+	Balance     float64 `json:"balance"`
+	Target      float64 `json:"target"`
+	Share       float64 `json:"share"`
+	TargetShare float64 `json:"target_share"`
+}
+
+// Transfer is one recommended move of an asset between exchanges
+// @author VrushankPatel
+// @description Amount is withdrawn from From; Amount minus Fee arrives on To. Fee is in units of
+// the asset and FeeValue in the quote currency, 0 when the asset has no price.
+type Transfer struct {
+	Asset          string  `json:"asset"`
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	Amount         float64 `json:"amount"`
+	Fee            float64 `json:"fee"`
+	FeeValue       float64 `json:"fee_value"`
+	CostPercentage float64 `json:"cost_percentage"`
+	TimeSeconds    float64 `json:"time_seconds"`
+	SkipReason     string  `json:"skip_reason,omitempty"` // Why the transfer is not recommended
+}
+
+// Plan is the set of transfers that restores the target allocations
+// @author VrushankPatel
+// @description Only assets whose share on some exchange is more than Threshold away from its
+// target get transfers; Allocations lists every asset so the drift is visible either way.
+// Transfers whose fees cost too much of the amount are listed in Skipped instead of Transfers
+// and left out of TotalFeeValue.
+type Plan struct {
+	GeneratedAt   time.Time    `json:"generated_at"`
+	Source        string       `json:"source"`
+	QuoteCurrency string       `json:"quote_currency"`
+	Threshold     float64      `json:"threshold"`
+	Allocations   []Allocation `json:"allocations"`
+	Transfers     []Transfer   `json:"transfers"`
+	Skipped       []Transfer   `json:"skipped"`
+	TotalFeeValue float64      `json:"total_fee_value"`
+}
+
+// position is an exchange's surplus or deficit of an asset
+type position struct {
+	exchange string
+	amount   float64
+}
+
+// allocate computes the allocations of one asset and the moves that restore its targets.
+// The moves match the largest surplus with the largest deficit until both sides are used up.
+func allocate(asset string, balances map[string]float64, weights map[string]float64, threshold float64) ([]Allocation, []position, []position) {
+	total := 0.0
+	for _, balance := range balances {
+		total += balance
+	}
+	if total <= 0 {
+		return nil, nil, nil
+	}
+
+	allocations := make([]Allocation, 0, len(balances))
+	surpluses, deficits := []position{}, []position{}
+	drifted := false
+	for _, exchange := range sortedExchanges(balances) {
+		allocation := Allocation{
+			Exchange:    exchange,
+			Asset:       asset,
+			Balance:     balances[exchange],
+			Target:      total * weights[exchange],
+			Share:       balances[exchange] / total,
+			TargetShare: weights[exchange],
+		}
+		allocations = append(allocations, allocation)
+
+		if math.Abs(allocation.Share-allocation.TargetShare) > threshold {
+			drifted = true
+		}
+		if difference := allocation.Balance - allocation.Target; difference > 0 {
+			surpluses = append(surpluses, position{exchange: exchange, amount: difference})
+		} else if difference < 0 {
+			deficits = append(deficits, position{exchange: exchange, amount: -difference})
+		}
+	}
+	if !drifted {
+		return allocations, nil, nil
+	}
+	return allocations, surpluses, deficits
+}
+
+// match pairs surpluses with deficits, largest first, and returns the amounts to move
+func match(surpluses, deficits []position) []Transfer {
+	sort.SliceStable(surpluses, func(i, j int) bool { return surpluses[i].amount > surpluses[j].amount })
+	sort.SliceStable(deficits, func(i, j int) bool { return deficits[i].amount > deficits[j].amount })
+
+	transfers := []Transfer{}
+	for i, j := 0, 0; i < len(surpluses) && j < len(deficits); {
+		amount := surpluses[i].amount
+		if deficits[j].amount < amount {
+			amount = deficits[j].amount
+		}
+		transfers = append(transfers, Transfer{From: surpluses[i].exchange, To: deficits[j].exchange, Amount: amount})
+
+		surpluses[i].amount -= amount
+		deficits[j].amount -= amount
+		if surpluses[i].amount <= 1e-12 {
+			i++
+		}
+		if deficits[j].amount <= 1e-12 {
+			j++
+		}
+	}
+	return transfers
+}
+
+// weightsFor returns the target share of each exchange holding balances, normalized to sum to 1.
+// Exchanges without a configured target get none; without any targets every exchange gets an
+// equal share.
+func weightsFor(balances map[string]float64, targets map[string]float64) map[string]float64 {
+	weights := make(map[string]float64, len(balances))
+	sum := 0.0
+	for exchange := range balances {
+		weights[exchange] = targets[exchange]
+		sum += targets[exchange]
+	}
+	for exchange := range weights {
+		if sum > 0 {
+			weights[exchange] /= sum
+		} else {
+			weights[exchange] = 1 / float64(len(weights))
+		}
+	}
+	return weights
+}
+
+// sortedExchanges returns the exchange names of a balance map in order
+func sortedExchanges(balances map[string]float64) []string {
+	exchanges := make([]string, 0, len(balances))
+	for exchange := range balances {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+	return exchanges
+}
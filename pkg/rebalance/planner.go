@@ -0,0 +1,231 @@
+package rebalance
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"apex-arbitrage/pkg/clock"
+	"apex-arbitrage/pkg/costs"
+	"apex-arbitrage/pkg/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BalanceSource returns the current balance per exchange and asset
+type BalanceSource func() map[string]map[string]float64
+
+// Config configures the planner
+// @author VrushankPatel
+// @description Targets are the share of every asset each exchange should hold; they are
+// normalized over the exchanges with balances, and without any targets assets are split evenly
+type Config struct {
+	// Target share per exchange, e.g. {"Binance": 0.5, "Kraken": 0.5}
+	Targets map[string]float64
+	// Largest drift of an exchange's share from its target before transfers are recommended
+	Threshold float64
+	// Currency transfer fees are valued in, e.g. "USDT"
+	QuoteCurrency string
+	// Largest share of a transfer its fees may take, in percent (0 for no limit); transfers whose
+	// fees take all of it are skipped regardless
+	MaxCost float64
+}
+
+// Planner recommends transfers that restore the target allocation of every asset across exchanges
+// @author VrushankPatel
+// @description Arbitrage moves quote currency to the sell exchanges and base currency to the buy
+// exchanges. The planner compares the balances with the targets, matches the exchanges holding
+// too much of an asset with those holding too little, and prices each transfer with the
+// withdrawal and network fees of the cost model.
+type Planner struct {
+	source     BalanceSource
+	sourceName string
+	orderBooks *models.OrderBookStore
+	costModel  *costs.Model
+	cfg        Config
+	clock      clock.Clock
+}
+
+// New creates a rebalancing planner
+// @author VrushankPatel
+// @description Fees are valued at the mid price of the asset against the quote currency
+// @param source The balances to plan for
+// @param sourceName Where the balances come from, reported in the plan (e.g., "accounts", "paper")
+// @param orderBooks The order books used to value transfer fees
+// @param costModel The withdrawal and network fees
+// @param cfg The target allocation and drift threshold
+// @return A pointer to the newly created Planner
+func New(source BalanceSource, sourceName string, orderBooks *models.OrderBookStore, costModel *costs.Model, cfg Config) *Planner {
+	return &Planner{
+		source:     source,
+		sourceName: sourceName,
+		orderBooks: orderBooks,
+		costModel:  costModel,
+		cfg:        cfg,
+		clock:      clock.Real(),
+	}
+}
+
+// SetClock replaces the wall clock used for plan timestamps and the check interval
+// @author VrushankPatel
+// @description Lets tests and replays control time; call before Start
+// @param c The clock to use
+func (p *Planner) SetClock(c clock.Clock) {
+	p.clock = c
+}
+
+// Plan computes the transfers that restore the targets from the current balances
+// @author VrushankPatel
+// @description Safe to call at any time; every call reads the balances afresh
+// @return The plan, with no transfers when every asset is within the threshold
+func (p *Planner) Plan() Plan {
+	plan := Plan{
+		GeneratedAt:   p.clock.Now(),
+		Source:        p.sourceName,
+		QuoteCurrency: p.cfg.QuoteCurrency,
+		Threshold:     p.cfg.Threshold,
+		Allocations:   []Allocation{},
+		Transfers:     []Transfer{},
+		Skipped:       []Transfer{},
+	}
+
+	snapshot := p.source()
+	for _, asset := range assetsOf(snapshot) {
+		balances := balancesOf(snapshot, asset)
+		allocations, surpluses, deficits := allocate(asset, balances, weightsFor(balances, p.cfg.Targets), p.cfg.Threshold)
+		plan.Allocations = append(plan.Allocations, allocations...)
+
+		price := p.price(asset)
+		for _, transfer := range match(surpluses, deficits) {
+			cost := p.costModel.Transfer(asset, transfer.From, transfer.To)
+			transfer.Asset = asset
+			transfer.Fee = cost.WithdrawalFee + cost.NetworkFee
+			transfer.FeeValue = transfer.Fee * price
+			transfer.CostPercentage = transfer.Fee / transfer.Amount * 100
+			transfer.TimeSeconds = cost.Time.Seconds()
+			if transfer.SkipReason = p.skipReason(transfer); transfer.SkipReason != "" {
+				plan.Skipped = append(plan.Skipped, transfer)
+				continue
+			}
+			plan.Transfers = append(plan.Transfers, transfer)
+			plan.TotalFeeValue += transfer.FeeValue
+		}
+	}
+	return plan
+}
+
+// skipReason explains why a transfer is not worth making, or returns "" when it is
+func (p *Planner) skipReason(transfer Transfer) string {
+	if transfer.Fee >= transfer.Amount {
+		return "fees take the whole amount"
+	}
+	if p.cfg.MaxCost > 0 && transfer.CostPercentage > p.cfg.MaxCost {
+		return fmt.Sprintf("fees take more than %g%% of the amount", p.cfg.MaxCost)
+	}
+	return ""
+}
+
+// Start logs the recommendations whenever they change, checking every interval
+// @author VrushankPatel
+// @description Runs until the context is cancelled
+// @param ctx Context for cancellation
+// @param interval Time between checks
+func (p *Planner) Start(ctx context.Context, interval time.Duration) {
+	ticker := p.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		plan := p.Plan()
+		if routes := routesOf(plan); routes != last {
+			logPlan(plan)
+			last = routes
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// assetsOf returns every asset held on any exchange, in order
+func assetsOf(snapshot map[string]map[string]float64) []string {
+	seen := map[string]bool{}
+	for _, assets := range snapshot {
+		for asset := range assets {
+			seen[asset] = true
+		}
+	}
+	assets := make([]string, 0, len(seen))
+	for asset := range seen {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	return assets
+}
+
+// balancesOf returns the balance of an asset on every exchange, 0 where it is not held
+func balancesOf(snapshot map[string]map[string]float64, asset string) map[string]float64 {
+	balances := make(map[string]float64, len(snapshot))
+	for exchange, assets := range snapshot {
+		balances[exchange] = assets[asset]
+	}
+	return balances
+}
+
+// price returns the average mid price of an asset in the quote currency across exchanges,
+// 1 for the quote currency itself and 0 when no order book quotes it
+func (p *Planner) price(asset string) float64 {
+	if asset == p.cfg.QuoteCurrency {
+		return 1
+	}
+	sum, count := 0.0, 0
+	for _, book := range p.orderBooks.SnapshotForPair(models.TradingPair{BaseCurrency: asset, QuoteCurrency: p.cfg.QuoteCurrency}) {
+		if book.Bid > 0 && book.Ask > 0 {
+			sum += (book.Bid + book.Ask) / 2
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// routesOf identifies the recommended and skipped routes of a plan, ignoring amounts that move
+// with every trade
+func routesOf(plan Plan) string {
+	routes := make([]string, 0, len(plan.Transfers)+len(plan.Skipped))
+	for _, transfer := range plan.Transfers {
+		routes = append(routes, fmt.Sprintf("%s:%s->%s", transfer.Asset, transfer.From, transfer.To))
+	}
+	for _, transfer := range plan.Skipped {
+		routes = append(routes, fmt.Sprintf("skipped %s:%s->%s", transfer.Asset, transfer.From, transfer.To))
+	}
+	return strings.Join(routes, ",")
+}
+
+// logPlan logs every recommended and skipped transfer, or that the balances are within their targets
+func logPlan(plan Plan) {
+	if len(plan.Transfers) == 0 && len(plan.Skipped) == 0 {
+		log.Infof("[Rebalance] %s balances are within %.0f%% of their targets", plan.Source, plan.Threshold*100)
+		return
+	}
+	for _, transfer := range plan.Skipped {
+		log.WithFields(log.Fields{
+			"fee":  fmt.Sprintf("%.8f %s", transfer.Fee, transfer.Asset),
+			"cost": fmt.Sprintf("%.2f %s (%.3f%%)", transfer.FeeValue, plan.QuoteCurrency, transfer.CostPercentage),
+		}).Infof("[Rebalance] Not moving %.8f %s from %s to %s: %s", transfer.Amount, transfer.Asset, transfer.From, transfer.To, transfer.SkipReason)
+	}
+	for _, transfer := range plan.Transfers {
+		log.WithFields(log.Fields{
+			"fee":     fmt.Sprintf("%.8f %s", transfer.Fee, transfer.Asset),
+			"cost":    fmt.Sprintf("%.2f %s (%.3f%%)", transfer.FeeValue, plan.QuoteCurrency, transfer.CostPercentage),
+			"arrives": time.Duration(transfer.TimeSeconds * float64(time.Second)).String(),
+		}).Warnf("[Rebalance] Move %.8f %s from %s to %s", transfer.Amount, transfer.Asset, transfer.From, transfer.To)
+	}
+}
@@ -0,0 +1,235 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxLineSize bounds a single recorded line, which holds one order book with its depth
+const maxLineSize = 4 * 1024 * 1024
+
+// BookReader streams the order book records of one recorded books file
+// @author VrushankPatel
+// @description Decompresses a file written by Recorder and decodes it line by line
+type BookReader struct {
+	path    string
+	file    *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+	line    int
+}
+
+// OpenBooks opens a recorded books file for reading
+// @author VrushankPatel
+// @description Opens the gzip stream; files appended to by several runs are read as one stream
+// @param path The path of the books file
+// @return A pointer to the BookReader, or an error if the file is not a readable gzip file
+func OpenBooks(path string) (*BookReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to decompress %s: %v", path, err)
+	}
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &BookReader{path: path, file: file, gz: gz, scanner: scanner}, nil
+}
+
+// Next returns the next record, or false at the end of the file
+// @author VrushankPatel
+// @description Skips blank lines; a truncated last line, as left by a crash, ends the file
+// @return The record, whether one was read, and any decoding error
+func (r *BookReader) Next() (BookRecord, bool, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec BookRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return BookRecord{}, false, fmt.Errorf("%s:%d: %v", r.path, r.line, err)
+		}
+		return rec, true, nil
+	}
+	if err := r.scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return BookRecord{}, false, fmt.Errorf("%s: %v", r.path, err)
+	}
+	return BookRecord{}, false, nil
+}
+
+// Close closes the underlying file
+// @author VrushankPatel
+// @description Releases the decompressor and the file handle
+// @return Any error from closing the file
+func (r *BookReader) Close() error {
+	r.gz.Close()
+	return r.file.Close()
+}
+
+// BookFiles lists the recorded books files per UTC day within a date range
+// @author VrushankPatel
+// @description Scans <dir>/<exchange>/ for books files; a zero from or to leaves that side open
+// @param dir The root directory of the recorded files
+// @param exchanges The exchanges to include, or nil for all recorded exchanges
+// @param from The first day to include
+// @param to The last day to include
+// @return The days in order with the files of each day, or an error if the directory cannot be read
+func BookFiles(dir string, exchanges []string, from, to time.Time) ([]string, map[string][]string, error) {
+	if len(exchanges) == 0 {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read recorder directory: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				exchanges = append(exchanges, entry.Name())
+			}
+		}
+	}
+
+	suffix := "-" + KindBooks + ".jsonl.gz"
+	fromDay, toDay := "", ""
+	if !from.IsZero() {
+		fromDay = from.UTC().Format(dayLayout)
+	}
+	if !to.IsZero() {
+		toDay = to.UTC().Format(dayLayout)
+	}
+
+	files := make(map[string][]string)
+	for _, exchange := range exchanges {
+		matches, err := filepath.Glob(filepath.Join(dir, exchange, "*"+suffix))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, path := range matches {
+			day := strings.TrimSuffix(filepath.Base(path), suffix)
+			if _, err := time.Parse(dayLayout, day); err != nil {
+				continue
+			}
+			if (fromDay != "" && day < fromDay) || (toDay != "" && day > toDay) {
+				continue
+			}
+			files[day] = append(files[day], path)
+		}
+	}
+
+	days := make([]string, 0, len(files))
+	for day := range files {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days, files, nil
+}
+
+// Replay calls fn with every recorded order book in receive time order
+// @author VrushankPatel
+// @description Merges the books files of all selected exchanges day by day, so memory use stays
+// bounded by one record per exchange regardless of how much data was recorded
+// @param dir The root directory of the recorded files
+// @param exchanges The exchanges to include, or nil for all recorded exchanges
+// @param from Records received before this time are skipped (zero for no bound)
+// @param to Records received after this time are skipped (zero for no bound)
+// @param fn The function called for each record; returning an error stops the replay
+// @return The number of records replayed, and the first error from reading or from fn
+func Replay(dir string, exchanges []string, from, to time.Time, fn func(BookRecord) error) (int, error) {
+	days, files, err := BookFiles(dir, exchanges, from, to)
+	if err != nil {
+		return 0, err
+	}
+	if len(days) == 0 {
+		return 0, fmt.Errorf("no recorded order books found in %s", dir)
+	}
+
+	replayed := 0
+	for _, day := range days {
+		n, err := replayDay(files[day], from, to, fn)
+		replayed += n
+		if err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
+
+// replayDay merges the files of one day by receive time
+func replayDay(paths []string, from, to time.Time, fn func(BookRecord) error) (int, error) {
+	readers := make([]*BookReader, 0, len(paths))
+	defer func() {
+		for _, reader := range readers {
+			reader.Close()
+		}
+	}()
+
+	heads := make([]*BookRecord, 0, len(paths))
+	for _, path := range paths {
+		reader, err := OpenBooks(path)
+		if err != nil {
+			return 0, err
+		}
+		readers = append(readers, reader)
+		heads = append(heads, nil)
+	}
+
+	// advance loads the next record of reader i, or clears its head at the end of the file
+	advance := func(i int) error {
+		rec, ok, err := readers[i].Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			heads[i] = nil
+			return nil
+		}
+		heads[i] = &rec
+		return nil
+	}
+	for i := range readers {
+		if err := advance(i); err != nil {
+			return 0, err
+		}
+	}
+
+	replayed := 0
+	for {
+		// There are only a handful of exchanges, so a linear scan finds the earliest head
+		next := -1
+		for i, head := range heads {
+			if head != nil && (next < 0 || head.Received.Before(heads[next].Received)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return replayed, nil
+		}
+
+		rec := *heads[next]
+		if err := advance(next); err != nil {
+			return replayed, err
+		}
+		if !from.IsZero() && rec.Received.Before(from) {
+			continue
+		}
+		if !to.IsZero() && rec.Received.After(to) {
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+}
@@ -0,0 +1,301 @@
+package recorder
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"apex-arbitrage/pkg/clock"
+	"apex-arbitrage/pkg/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// queueSize is the number of records buffered between the exchange goroutines and the writer
+	queueSize = 8192
+
+	// flushInterval bounds how much recorded data is lost if the process dies
+	flushInterval = time.Second
+
+	// dayLayout names the file of each UTC day
+	dayLayout = "2006-01-02"
+)
+
+// Record kinds, used as the file name suffix
+const (
+	KindBooks  = "books"
+	KindFrames = "frames"
+)
+
+// BookRecord is one normalized order book update as written to a books file
+// @author VrushankPatel
+// @description Pairs the published order book with the time the recorder received it
+type BookRecord struct {
+	Received time.Time        `json:"received"`
+	Book     models.OrderBook `json:"book"`
+}
+
+// FrameRecord is one raw websocket frame as written to a frames file
+// @author VrushankPatel
+// @description Keeps the exchange payload untouched so parsing bugs can be investigated later
+type FrameRecord struct {
+	Received time.Time `json:"received"`
+	Exchange string    `json:"exchange"`
+	Frame    string    `json:"frame"`
+}
+
+// record is a queued line waiting to be written
+type record struct {
+	exchange string
+	kind     string
+	received time.Time
+	line     []byte
+}
+
+// fileKey identifies one output stream
+type fileKey struct {
+	exchange string
+	kind     string
+}
+
+// dayFile is an open gzip file for one exchange, kind and day
+type dayFile struct {
+	day  string
+	file *os.File
+	gz   *gzip.Writer
+}
+
+// Recorder writes order book updates and raw frames to gzip compressed JSON lines files
+// @author VrushankPatel
+// @description Writes one file per exchange, record kind and UTC day under
+// <dir>/<exchange>/<day>-<kind>.jsonl.gz and rotates to a new file at midnight UTC. Records are
+// queued and written by a single goroutine so exchange clients are never blocked on disk; when the
+// queue is full records are dropped and counted rather than stalling the market data feeds.
+type Recorder struct {
+	dir          string
+	recordFrames bool
+	queue        chan record
+	files        map[fileKey]*dayFile
+	clock        clock.Clock
+
+	mu      sync.Mutex
+	dropped int
+	written int
+}
+
+// New creates a recorder writing below the given directory
+// @author VrushankPatel
+// @description Creates the output directory and an idle recorder; call Start to begin writing
+// @param dir The root directory of the recorded files
+// @param recordFrames Whether raw websocket frames are recorded in addition to order books
+// @return A pointer to the newly created Recorder, or an error if the directory cannot be created
+func New(dir string, recordFrames bool) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recorder directory: %v", err)
+	}
+	return &Recorder{
+		dir:          dir,
+		recordFrames: recordFrames,
+		queue:        make(chan record, queueSize),
+		files:        make(map[fileKey]*dayFile),
+		clock:        clock.Real(),
+	}, nil
+}
+
+// SetClock replaces the wall clock used for receive times and the flush interval
+// @author VrushankPatel
+// @description Keeps recorded receive times consistent with the rest of the system; call before Start
+// @param c The clock to use
+func (r *Recorder) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
+// RecordFrames reports whether raw websocket frames are recorded
+// @author VrushankPatel
+// @description Lets callers skip wiring exchanges to the recorder when frames are not wanted
+// @return True if frames are recorded
+func (r *Recorder) RecordFrames() bool {
+	return r.recordFrames
+}
+
+// RecordBook queues an order book update; it matches models.OrderBookListener
+// @author VrushankPatel
+// @description Stamps the book with its receive time and queues it for writing
+// @param book The order book published by an exchange
+func (r *Recorder) RecordBook(book models.OrderBook) {
+	received := r.clock.Now()
+	line, err := json.Marshal(BookRecord{Received: received, Book: book})
+	if err != nil {
+		log.Errorf("[Recorder] Failed to encode order book: %v", err)
+		return
+	}
+	r.enqueue(record{exchange: book.Exchange, kind: KindBooks, received: received, line: line})
+}
+
+// RecordFrame queues a raw websocket frame; it satisfies exchanges.FrameRecorder
+// @author VrushankPatel
+// @description Copies the frame, since websocket readers may reuse their buffers
+// @param exchange The exchange the frame came from
+// @param received The time the frame was read from the connection
+// @param frame The raw frame payload
+func (r *Recorder) RecordFrame(exchange string, received time.Time, frame []byte) {
+	if !r.recordFrames {
+		return
+	}
+	line, err := json.Marshal(FrameRecord{Received: received, Exchange: exchange, Frame: string(frame)})
+	if err != nil {
+		log.Errorf("[Recorder] Failed to encode frame: %v", err)
+		return
+	}
+	r.enqueue(record{exchange: exchange, kind: KindFrames, received: received, line: line})
+}
+
+// enqueue hands a record to the writer goroutine, dropping it if the queue is full
+func (r *Recorder) enqueue(rec record) {
+	select {
+	case r.queue <- rec:
+	default:
+		r.mu.Lock()
+		r.dropped++
+		r.mu.Unlock()
+	}
+}
+
+// Start writes queued records until the context is cancelled, then flushes and closes all files
+// @author VrushankPatel
+// @description Runs the single writer loop; blocks until shutdown
+// @param ctx Context used to stop the recorder
+func (r *Recorder) Start(ctx context.Context) {
+	log.Infof("[Recorder] Recording market data to %s (raw frames: %t)", r.dir, r.recordFrames)
+
+	flushTicker := r.clock.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+	statsTicker := r.clock.NewTicker(time.Minute)
+	defer statsTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Drain what is already queued so a clean shutdown loses nothing
+			for {
+				select {
+				case rec := <-r.queue:
+					r.write(rec)
+				default:
+					r.closeFiles()
+					log.Info("[Recorder] Stopped")
+					return
+				}
+			}
+		case rec := <-r.queue:
+			r.write(rec)
+		case <-flushTicker.C():
+			r.flush()
+		case <-statsTicker.C():
+			r.logStats()
+		}
+	}
+}
+
+// write appends one record to its day file, rotating the file when the day changes
+func (r *Recorder) write(rec record) {
+	key := fileKey{exchange: rec.exchange, kind: rec.kind}
+	day := rec.received.UTC().Format(dayLayout)
+
+	f := r.files[key]
+	if f != nil && f.day != day {
+		r.closeFile(f)
+		f = nil
+	}
+	if f == nil {
+		opened, err := r.openFile(rec.exchange, rec.kind, day)
+		if err != nil {
+			log.Errorf("[Recorder] %v", err)
+			return
+		}
+		f = opened
+		r.files[key] = f
+	}
+
+	if _, err := f.gz.Write(append(rec.line, '\n')); err != nil {
+		log.Errorf("[Recorder] Failed to write %s record for %s: %v", rec.kind, rec.exchange, err)
+		return
+	}
+	r.mu.Lock()
+	r.written++
+	r.mu.Unlock()
+}
+
+// openFile opens the file of an exchange, kind and day for appending. Appending starts a new
+// gzip member, which standard gzip readers concatenate transparently.
+func (r *Recorder) openFile(exchange, kind, day string) (*dayFile, error) {
+	dir := filepath.Join(r.dir, exchange)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %v", exchange, err)
+	}
+	path := FilePath(r.dir, exchange, kind, day)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	log.Debugf("[Recorder] Writing %s", path)
+	return &dayFile{day: day, file: file, gz: gzip.NewWriter(file)}, nil
+}
+
+// flush pushes buffered compressed data of every open file to disk
+func (r *Recorder) flush() {
+	for key, f := range r.files {
+		if err := f.gz.Flush(); err != nil {
+			log.Errorf("[Recorder] Failed to flush %s %s: %v", key.exchange, key.kind, err)
+		}
+	}
+}
+
+// closeFile finishes the gzip stream of a file and closes it
+func (r *Recorder) closeFile(f *dayFile) {
+	if err := f.gz.Close(); err != nil {
+		log.Errorf("[Recorder] Failed to finish %s: %v", f.file.Name(), err)
+	}
+	if err := f.file.Close(); err != nil {
+		log.Errorf("[Recorder] Failed to close %s: %v", f.file.Name(), err)
+	}
+}
+
+// closeFiles closes every open file
+func (r *Recorder) closeFiles() {
+	for key, f := range r.files {
+		r.closeFile(f)
+		delete(r.files, key)
+	}
+	r.logStats()
+}
+
+// logStats reports how many records were written and dropped
+func (r *Recorder) logStats() {
+	r.mu.Lock()
+	written, dropped := r.written, r.dropped
+	r.mu.Unlock()
+	if dropped > 0 {
+		log.Warnf("[Recorder] %d records written, %d dropped because the queue was full", written, dropped)
+		return
+	}
+	log.Debugf("[Recorder] %d records written", written)
+}
+
+// FilePath returns the path of the file holding one exchange's records of a kind for a UTC day
+// @author VrushankPatel
+// @description Shared by the recorder and readers of recorded data
+// @param dir The root directory of the recorded files
+// @param exchange The exchange name
+// @param kind KindBooks or KindFrames
+// @param day The UTC day in YYYY-MM-DD format
+// @return The file path
+func FilePath(dir, exchange, kind, day string) string {
+	return filepath.Join(dir, exchange, day+"-"+kind+".jsonl.gz")
+}
@@ -0,0 +1,371 @@
+package risk
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"apex-arbitrage/pkg/clock"
+	"apex-arbitrage/pkg/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Rejection reasons, counted in Status.Rejected
+const (
+	ReasonKillSwitch    = "kill_switch"    // The kill switch is engaged
+	ReasonUnsupported   = "unsupported"    // Multi-leg opportunities are never executed
+	ReasonTradeNotional = "trade_notional" // The trade is larger than MaxTradeNotional
+	ReasonExposure      = "exposure"       // The trade would exceed MaxExposure on a venue
+	ReasonDailyLoss     = "daily_loss"     // Today's realized loss reached DailyLossLimit
+	ReasonTradeRate     = "trade_rate"     // MaxTradesPerMinute trades were approved in the last minute
+)
+
+// Limits are the pre-trade risk limits; a zero limit is not enforced
+// @author VrushankPatel
+// @description Notional amounts and losses are in quote currency, assumed to be the same for all
+// pairs (e.g., USDT)
+type Limits struct {
+	// Largest notional of a single trade
+	MaxTradeNotional float64 `json:"max_trade_notional"`
+	// Largest notional of an asset in open trades per venue
+	MaxExposure float64 `json:"max_exposure"`
+	// Realized loss per UTC day after which trading stops until the next day
+	DailyLossLimit float64 `json:"daily_loss_limit"`
+	// Most trades approved within any minute
+	MaxTradesPerMinute int `json:"max_trades_per_minute"`
+}
+
+// Status is a snapshot of the risk manager
+// @author VrushankPatel
+// @description Exposure is the notional per venue and asset of approved opportunities still open
+// that an engine may still trade
+type Status struct {
+	Limits           Limits                        `json:"limits"`
+	KillSwitch       bool                          `json:"kill_switch"`
+	KillSwitchReason string                        `json:"kill_switch_reason,omitempty"`
+	KillSwitchAt     *time.Time                    `json:"kill_switch_at,omitempty"`
+	Day              string                        `json:"day"`
+	DailyPnL         float64                       `json:"daily_pnl"`
+	TradesLastMinute int                           `json:"trades_last_minute"`
+	Exposure         map[string]map[string]float64 `json:"exposure"`
+	Approved         int                           `json:"approved"`
+	Rejected         map[string]int                `json:"rejected"`
+}
+
+// exposure is the notional an approved opportunity holds on its venues
+type exposure struct {
+	asset    string
+	venues   []string
+	notional float64
+	declined int // Engines that will not trade the opportunity
+}
+
+// Manager checks every opportunity against the risk limits before it reaches an execution engine
+// @author VrushankPatel
+// @description Registered as the detector's opportunity handler in front of the paper and live
+// engines: newly opened opportunities are forwarded to the engines only when every limit allows
+// them, and every rejection is logged with its reason. Updates and closes are forwarded only for
+// approved opportunities whose exposure is still reserved.
+type Manager struct {
+	limits   Limits
+	clock    clock.Clock
+	handlers []func(models.OpportunityEvent)
+
+	mu               sync.Mutex
+	killSwitch       bool
+	killSwitchReason string
+	killSwitchAt     time.Time
+	day              string
+	dailyPnL         float64
+	approvedAt       []time.Time
+	open             map[string]exposure
+	exposure         map[string]map[string]float64
+	approved         int
+	rejected         map[string]int
+}
+
+// New creates a risk manager
+// @author VrushankPatel
+// @description Creates a manager enforcing the given limits, with the kill switch released
+// @param limits The risk limits
+// @return A pointer to the newly created Manager
+func New(limits Limits) *Manager {
+	return &Manager{
+		limits:   limits,
+		clock:    clock.Real(),
+		open:     make(map[string]exposure),
+		exposure: make(map[string]map[string]float64),
+		rejected: make(map[string]int),
+	}
+}
+
+// SetClock replaces the wall clock used for the trade rate and daily loss windows
+// @author VrushankPatel
+// @description Lets tests control time; call before registering the manager
+// @param c The clock to use
+func (m *Manager) SetClock(c clock.Clock) {
+	m.clock = c
+}
+
+// RegisterHandler adds an engine that receives the opportunities the manager approves
+// @author VrushankPatel
+// @description Handlers are called in registration order
+// @param handler The engine's opportunity handler
+func (m *Manager) RegisterHandler(handler func(models.OpportunityEvent)) {
+	m.handlers = append(m.handlers, handler)
+}
+
+// HandleOpportunityEvent checks an opportunity event and forwards it to the engines if allowed
+// @author VrushankPatel
+// @description Opened opportunities are checked against the kill switch and every limit; closing
+// an approved opportunity releases its exposure. Events of other opportunities are dropped.
+// @param event The opportunity lifecycle event
+func (m *Manager) HandleOpportunityEvent(event models.OpportunityEvent) {
+	switch event.Type {
+	case models.OpportunityEventOpened:
+		if reason, detail := m.check(event.Opportunity); reason != "" {
+			log.WithFields(log.Fields{
+				"id":     event.Opportunity.ID,
+				"reason": reason,
+			}).Warnf("[Risk] Opportunity rejected: %s", detail)
+			return
+		}
+	case models.OpportunityEventClosed:
+		if !m.release(event.Opportunity) {
+			return
+		}
+	default:
+		if !m.approvedOpen(event.Opportunity) {
+			return
+		}
+	}
+
+	for _, handler := range m.handlers {
+		handler(event)
+	}
+}
+
+// Decline records that an engine will not trade an approved opportunity
+// @author VrushankPatel
+// @description Called by the execution engines when they skip an opportunity or trade none of it.
+// Once every engine declined, its exposure is released without waiting for it to close.
+// @param opp The declined opportunity
+func (m *Manager) Decline(opp models.ArbitrageOpportunity) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := opportunityKey(opp)
+	held, exists := m.open[key]
+	if !exists {
+		return
+	}
+	held.declined++
+	m.open[key] = held
+	if held.declined >= len(m.handlers) {
+		m.unreserve(key, held)
+	}
+}
+
+// RecordPnL adds the realized PnL of an executed trade to today's total
+// @author VrushankPatel
+// @description Called by the execution engines after every trade
+// @param pnl The realized PnL in quote currency
+func (m *Manager) RecordPnL(pnl float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollDay()
+	m.dailyPnL += pnl
+}
+
+// SetKillSwitch engages or releases the kill switch
+// @author VrushankPatel
+// @description While engaged every new opportunity is rejected
+// @param engaged True to stop trading, false to resume
+// @param reason Why the switch was toggled, for logs and the status
+func (m *Manager) SetKillSwitch(engaged bool, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setKillSwitch(engaged, reason)
+}
+
+// ToggleKillSwitch flips the kill switch
+// @author VrushankPatel
+// @description Used by the signal handler
+// @param reason Why the switch was toggled
+func (m *Manager) ToggleKillSwitch(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setKillSwitch(!m.killSwitch, reason)
+}
+
+// Status returns a snapshot of the limits, kill switch, daily PnL and exposure
+// @author VrushankPatel
+// @description Safe to call at any time
+// @return The status
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollDay()
+
+	status := Status{
+		Limits:           m.limits,
+		KillSwitch:       m.killSwitch,
+		KillSwitchReason: m.killSwitchReason,
+		Day:              m.day,
+		DailyPnL:         m.dailyPnL,
+		TradesLastMinute: m.tradesLastMinute(),
+		Exposure:         make(map[string]map[string]float64, len(m.exposure)),
+		Approved:         m.approved,
+		Rejected:         make(map[string]int, len(m.rejected)),
+	}
+	if !m.killSwitchAt.IsZero() {
+		at := m.killSwitchAt
+		status.KillSwitchAt = &at
+	}
+	for venue, assets := range m.exposure {
+		status.Exposure[venue] = make(map[string]float64, len(assets))
+		for asset, notional := range assets {
+			status.Exposure[venue][asset] = notional
+		}
+	}
+	for reason, count := range m.rejected {
+		status.Rejected[reason] = count
+	}
+	return status
+}
+
+// check applies every limit to an opportunity and reserves its exposure if it passes.
+// It returns the rejection reason and a description, or an empty reason if approved.
+func (m *Manager) check(opp models.ArbitrageOpportunity) (string, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollDay()
+
+	reason, detail := m.violation(opp)
+	if reason != "" {
+		m.rejected[reason]++
+		return reason, detail
+	}
+
+	notional := opp.Quantity * opp.BuyPrice
+	held := exposure{asset: opp.BaseCurrency, venues: []string{opp.BuyExchange, opp.SellExchange}, notional: notional}
+	for _, venue := range held.venues {
+		if m.exposure[venue] == nil {
+			m.exposure[venue] = make(map[string]float64)
+		}
+		m.exposure[venue][held.asset] += notional
+	}
+	m.open[opportunityKey(opp)] = held
+	m.approvedAt = append(m.approvedAt, m.clock.Now())
+	m.approved++
+	return "", ""
+}
+
+// violation returns the first limit an opportunity breaks
+func (m *Manager) violation(opp models.ArbitrageOpportunity) (string, string) {
+	if m.killSwitch {
+		return ReasonKillSwitch, "kill switch is engaged"
+	}
+	if opp.Type != models.OpportunityCrossExchange {
+		return ReasonUnsupported, fmt.Sprintf("%s opportunities are not executed", opp.Type)
+	}
+
+	notional := opp.Quantity * opp.BuyPrice
+	if m.limits.MaxTradeNotional > 0 && notional > m.limits.MaxTradeNotional {
+		return ReasonTradeNotional, fmt.Sprintf("trade notional %.2f %s exceeds %.2f", notional, opp.QuoteCurrency, m.limits.MaxTradeNotional)
+	}
+	if m.limits.MaxExposure > 0 {
+		for _, venue := range []string{opp.BuyExchange, opp.SellExchange} {
+			if total := m.exposure[venue][opp.BaseCurrency] + notional; total > m.limits.MaxExposure {
+				return ReasonExposure, fmt.Sprintf("%s exposure on %s would be %.2f %s, above %.2f", opp.BaseCurrency, venue, total, opp.QuoteCurrency, m.limits.MaxExposure)
+			}
+		}
+	}
+	if m.limits.DailyLossLimit > 0 && -m.dailyPnL >= m.limits.DailyLossLimit {
+		return ReasonDailyLoss, fmt.Sprintf("daily loss %.2f reached the limit of %.2f", -m.dailyPnL, m.limits.DailyLossLimit)
+	}
+	if m.limits.MaxTradesPerMinute > 0 && m.tradesLastMinute() >= m.limits.MaxTradesPerMinute {
+		return ReasonTradeRate, fmt.Sprintf("%d trades in the last minute", m.limits.MaxTradesPerMinute)
+	}
+	return "", ""
+}
+
+// approvedOpen reports whether an opportunity was approved and still holds its exposure
+func (m *Manager) approvedOpen(opp models.ArbitrageOpportunity) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, exists := m.open[opportunityKey(opp)]
+	return exists
+}
+
+// release frees the exposure of an approved opportunity once it closes. It returns false when
+// the opportunity holds no exposure, having been rejected or declined by every engine.
+func (m *Manager) release(opp models.ArbitrageOpportunity) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := opportunityKey(opp)
+	held, exists := m.open[key]
+	if !exists {
+		return false
+	}
+	m.unreserve(key, held)
+	return true
+}
+
+// unreserve removes an opportunity's exposure from the totals; the caller holds the lock
+func (m *Manager) unreserve(key string, held exposure) {
+	delete(m.open, key)
+	for _, venue := range held.venues {
+		m.exposure[venue][held.asset] -= held.notional
+		if m.exposure[venue][held.asset] <= 1e-9 {
+			delete(m.exposure[venue], held.asset)
+		}
+		if len(m.exposure[venue]) == 0 {
+			delete(m.exposure, venue)
+		}
+	}
+}
+
+// setKillSwitch changes the kill switch and logs it; the caller holds the lock
+func (m *Manager) setKillSwitch(engaged bool, reason string) {
+	if m.killSwitch == engaged {
+		return
+	}
+	m.killSwitch = engaged
+	m.killSwitchReason = reason
+	m.killSwitchAt = m.clock.Now()
+	if engaged {
+		log.Warnf("[Risk] Kill switch ENGAGED (%s): no new trades", reason)
+	} else {
+		log.Warnf("[Risk] Kill switch released (%s): trading resumes", reason)
+	}
+}
+
+// tradesLastMinute drops approvals older than a minute and counts the rest; the caller holds the lock
+func (m *Manager) tradesLastMinute() int {
+	cutoff := m.clock.Now().Add(-time.Minute)
+	recent := m.approvedAt[:0]
+	for _, at := range m.approvedAt {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+	m.approvedAt = recent
+	return len(recent)
+}
+
+// rollDay resets the daily PnL at the start of a new UTC day; the caller holds the lock
+func (m *Manager) rollDay() {
+	day := m.clock.Now().UTC().Format("2006-01-02")
+	if day != m.day {
+		m.day = day
+		m.dailyPnL = 0
+	}
+}
+
+// opportunityKey identifies one opening of an opportunity
+func opportunityKey(opp models.ArbitrageOpportunity) string {
+	return opp.ID + "@" + opp.OpenedAt.Format(time.RFC3339Nano)
+}
@@ -1,17 +1,35 @@
 package server
 
 import (
+        "crypto/subtle"
         "encoding/json"
+        "mime"
+        "net"
         "net/http"
         "sync"
         "time"
 
+        "apex-arbitrage/pkg/clock"
+        "apex-arbitrage/pkg/config"
+        "apex-arbitrage/pkg/execution"
         "apex-arbitrage/pkg/models"
+        "apex-arbitrage/pkg/paper"
+        "apex-arbitrage/pkg/rebalance"
+        "apex-arbitrage/pkg/risk"
 
         "github.com/gorilla/websocket"
         log "github.com/sirupsen/logrus"
 )
 
+const (
+        // clientQueueSize is how many messages may wait for a WebSocket client before it is
+        // dropped as too slow
+        clientQueueSize = 64
+
+        // writeTimeout is how long writing one message to a WebSocket client may take
+        writeTimeout = 10 * time.Second
+)
+
 // WebSocketMessage represents a message sent over WebSocket
 type WebSocketMessage struct {
         Type        string      `json:"type"`
@@ -19,25 +37,42 @@ type WebSocketMessage struct {
         Timestamp   int64       `json:"timestamp"`
 }
 
+// ConfigUpdate describes the settings in effect, sent when they are reloaded
+type ConfigUpdate struct {
+        MinProfitThreshold float64            `json:"min_profit_threshold"`
+        ExchangeFees       map[string]float64 `json:"exchange_fees"`
+        TradingPairs       []string           `json:"trading_pairs"`
+        // Settings applied by the last reload (empty at startup)
+        Changes []config.Change `json:"changes"`
+        // Settings changed by the last reload that only take effect after a restart
+        RestartRequired []string `json:"restart_required"`
+}
+
 // WebServer handles HTTP requests and WebSocket connections
 type WebServer struct {
         port             string
-        orderBooks       map[string]*models.OrderBook
-        orderBookMutex   *sync.RWMutex
-        clients          map[*websocket.Conn]bool
+        orderBooks       *models.OrderBookStore
+        clients          map[*websocket.Conn]chan WebSocketMessage // Messages queued per client
         clientsMutex     sync.Mutex
         opportunities    []models.ArbitrageOpportunity
         opportunitiesMutex sync.Mutex
         upgrader         websocket.Upgrader
+        clock            clock.Clock
+        paperTrader      *paper.Engine
+        executor         *execution.Executor
+        riskManager      *risk.Manager
+        killSwitchToken  string
+        rebalancer       *rebalance.Planner
+        settings         *ConfigUpdate
+        settingsMutex    sync.Mutex
 }
 
 // NewWebServer creates a new web server instance
-func NewWebServer(port string, orderBooks map[string]*models.OrderBook, orderBookMutex *sync.RWMutex) *WebServer {
+func NewWebServer(port string, orderBooks *models.OrderBookStore) *WebServer {
         return &WebServer{
                 port:             port,
                 orderBooks:       orderBooks,
-                orderBookMutex:   orderBookMutex,
-                clients:          make(map[*websocket.Conn]bool),
+                clients:          make(map[*websocket.Conn]chan WebSocketMessage),
                 opportunities:    make([]models.ArbitrageOpportunity, 0),
                 upgrader: websocket.Upgrader{
                         ReadBufferSize:  1024,
@@ -46,9 +81,52 @@ func NewWebServer(port string, orderBooks map[string]*models.OrderBook, orderBoo
                                 return true // Allow all origins for WebSocket connections
                         },
                 },
+                clock: clock.Real(),
         }
 }
 
+// SetClock replaces the wall clock used for broadcasts and message timestamps
+func (s *WebServer) SetClock(c clock.Clock) {
+        s.clock = c
+}
+
+// SetPaperTrader exposes the ledger of a paper trading engine at /api/paper
+func (s *WebServer) SetPaperTrader(engine *paper.Engine) {
+        s.paperTrader = engine
+}
+
+// SetExecutor exposes the recent executions of the live executor at /api/executions
+func (s *WebServer) SetExecutor(executor *execution.Executor) {
+        s.executor = executor
+}
+
+// SetRiskManager exposes the risk status at /api/risk and the kill switch at /api/risk/kill-switch.
+// Releasing the kill switch takes the token as a bearer token; without a token it is only
+// released from the local machine.
+func (s *WebServer) SetRiskManager(manager *risk.Manager, token string) {
+        s.riskManager = manager
+        s.killSwitchToken = token
+}
+
+// SetRebalancer exposes the rebalancing recommendations at /api/rebalance
+func (s *WebServer) SetRebalancer(planner *rebalance.Planner) {
+        s.rebalancer = planner
+}
+
+// PublishConfig sends the settings in effect to all connected clients as a "config" message,
+// and to every client that connects later
+func (s *WebServer) PublishConfig(update ConfigUpdate) {
+        s.settingsMutex.Lock()
+        s.settings = &update
+        s.settingsMutex.Unlock()
+
+        s.broadcast(WebSocketMessage{
+                Type:      "config",
+                Data:      update,
+                Timestamp: s.clock.Now().Unix(),
+        })
+}
+
 // Start initializes and runs the web server
 func (s *WebServer) Start() error {
         // Add CORS middleware
@@ -56,7 +134,7 @@ func (s *WebServer) Start() error {
                 return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
                         w.Header().Set("Access-Control-Allow-Origin", "*")
                         w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-                        w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type")
+                        w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
 
                         if r.Method == "OPTIONS" {
                                 w.WriteHeader(http.StatusOK)
@@ -89,6 +167,11 @@ func (s *WebServer) Start() error {
         // API endpoints
         http.HandleFunc("/api/opportunities", corsMiddleware(http.HandlerFunc(s.handleOpportunitiesAPI)).ServeHTTP)
         http.HandleFunc("/api/market", corsMiddleware(http.HandlerFunc(s.handleMarketAPI)).ServeHTTP)
+        http.HandleFunc("/api/paper", corsMiddleware(http.HandlerFunc(s.handlePaperAPI)).ServeHTTP)
+        http.HandleFunc("/api/executions", corsMiddleware(http.HandlerFunc(s.handleExecutionsAPI)).ServeHTTP)
+        http.HandleFunc("/api/risk", corsMiddleware(http.HandlerFunc(s.handleRiskAPI)).ServeHTTP)
+        http.HandleFunc("/api/risk/kill-switch", corsMiddleware(http.HandlerFunc(s.handleKillSwitchAPI)).ServeHTTP)
+        http.HandleFunc("/api/rebalance", corsMiddleware(http.HandlerFunc(s.handleRebalanceAPI)).ServeHTTP)
 
         // Start market data broadcast
         go s.broadcastMarketData()
@@ -98,21 +181,32 @@ func (s *WebServer) Start() error {
         return http.ListenAndServe("0.0.0.0:"+s.port, nil)
 }
 
-// AddOpportunity adds a new arbitrage opportunity and broadcasts it to clients
-func (s *WebServer) AddOpportunity(opportunity models.ArbitrageOpportunity) {
+// HandleOpportunityEvent records an opportunity lifecycle event and broadcasts it to clients
+func (s *WebServer) HandleOpportunityEvent(event models.OpportunityEvent) {
         s.opportunitiesMutex.Lock()
         defer s.opportunitiesMutex.Unlock()
 
-        // Add to opportunities list
-        s.opportunities = append(s.opportunities, opportunity)
+        opportunity := event.Opportunity
+        if event.Type == models.OpportunityEventOpened {
+                // Add to opportunities list
+                s.opportunities = append(s.opportunities, opportunity)
 
-        // Keep only the last 100 opportunities
-        if len(s.opportunities) > 100 {
-                s.opportunities = s.opportunities[len(s.opportunities)-100:]
+                // Keep only the last 100 opportunities
+                if len(s.opportunities) > 100 {
+                        s.opportunities = s.opportunities[len(s.opportunities)-100:]
+                }
+        } else {
+                // Replace the entry of the same opportunity, newest first
+                for i := len(s.opportunities) - 1; i >= 0; i-- {
+                        if s.opportunities[i].ID == opportunity.ID && s.opportunities[i].OpenedAt.Equal(opportunity.OpenedAt) {
+                                s.opportunities[i] = opportunity
+                                break
+                        }
+                }
         }
 
         // Broadcast to connected clients
-        s.broadcastOpportunity(opportunity)
+        s.broadcastOpportunity(event)
 }
 
 // handleWebSocket handles WebSocket connections
@@ -124,25 +218,21 @@ func (s *WebServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
                 return
         }
 
-        // Register the new client
+        // Register the new client; its writer sends the initial data, then whatever is queued
+        queue := make(chan WebSocketMessage, clientQueueSize)
         s.clientsMutex.Lock()
-        s.clients[conn] = true
+        s.clients[conn] = queue
         s.clientsMutex.Unlock()
+        go s.writeMessages(conn, queue)
 
         log.Infof("New WebSocket client connected: %s", conn.RemoteAddr())
 
         // Handle disconnection
         defer func() {
-                s.clientsMutex.Lock()
-                delete(s.clients, conn)
-                s.clientsMutex.Unlock()
-                conn.Close()
+                s.removeClient(conn)
                 log.Infof("WebSocket client disconnected: %s", conn.RemoteAddr())
         }()
 
-        // Send initial data
-        s.sendInitialData(conn)
-
         // Handle incoming messages (though we don't expect many)
         for {
                 _, _, err := conn.ReadMessage()
@@ -155,20 +245,44 @@ func (s *WebServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
         }
 }
 
+// writeMessages writes the initial data and then the queued messages to a WebSocket client,
+// until its queue is closed or a write fails
+func (s *WebServer) writeMessages(conn *websocket.Conn, queue chan WebSocketMessage) {
+        defer conn.Close()
+
+        s.sendInitialData(conn)
+        for msg := range queue {
+                conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+                if err := conn.WriteJSON(msg); err != nil {
+                        log.Errorf("Failed to send %s data: %v", msg.Type, err)
+                        s.removeClient(conn)
+                        return
+                }
+        }
+}
+
+// removeClient unregisters a WebSocket client and closes its queue, which stops its writer
+func (s *WebServer) removeClient(conn *websocket.Conn) {
+        s.clientsMutex.Lock()
+        defer s.clientsMutex.Unlock()
+
+        if queue, exists := s.clients[conn]; exists {
+                delete(s.clients, conn)
+                close(queue)
+        }
+}
+
 // sendInitialData sends the initial data to a new WebSocket client
 func (s *WebServer) sendInitialData(conn *websocket.Conn) {
+        conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
         // Send market data
-        s.orderBookMutex.RLock()
-        marketData := make(map[string]*models.OrderBook)
-        for k, v := range s.orderBooks {
-                marketData[k] = v
-        }
-        s.orderBookMutex.RUnlock()
+        marketData := s.orderBooks.SnapshotMap()
 
         marketMsg := WebSocketMessage{
                 Type:      "market",
                 Data:      marketData,
-                Timestamp: time.Now().Unix(),
+                Timestamp: s.clock.Now().Unix(),
         }
 
         if err := conn.WriteJSON(marketMsg); err != nil {
@@ -184,26 +298,38 @@ func (s *WebServer) sendInitialData(conn *websocket.Conn) {
         oppMsg := WebSocketMessage{
                 Type:      "opportunities",
                 Data:      opportunities,
-                Timestamp: time.Now().Unix(),
+                Timestamp: s.clock.Now().Unix(),
         }
 
         if err := conn.WriteJSON(oppMsg); err != nil {
                 log.Errorf("Failed to send opportunities data: %v", err)
         }
+
+        // Send the settings in effect
+        s.settingsMutex.Lock()
+        settings := s.settings
+        s.settingsMutex.Unlock()
+
+        if settings != nil {
+                configMsg := WebSocketMessage{
+                        Type:      "config",
+                        Data:      *settings,
+                        Timestamp: s.clock.Now().Unix(),
+                }
+
+                if err := conn.WriteJSON(configMsg); err != nil {
+                        log.Errorf("Failed to send config data: %v", err)
+                }
+        }
 }
 
 // broadcastMarketData periodically broadcasts market data to all connected clients
 func (s *WebServer) broadcastMarketData() {
-        ticker := time.NewTicker(2 * time.Second)
+        ticker := s.clock.NewTicker(2 * time.Second)
         defer ticker.Stop()
 
-        for range ticker.C {
-                s.orderBookMutex.RLock()
-                marketData := make(map[string]*models.OrderBook)
-                for k, v := range s.orderBooks {
-                        marketData[k] = v
-                }
-                s.orderBookMutex.RUnlock()
+        for range ticker.C() {
+                marketData := s.orderBooks.SnapshotMap()
 
                 // Skip if no data or no clients
                 if len(marketData) == 0 {
@@ -211,53 +337,49 @@ func (s *WebServer) broadcastMarketData() {
                 }
 
                 s.clientsMutex.Lock()
-                if len(s.clients) == 0 {
-                        s.clientsMutex.Unlock()
+                clients := len(s.clients)
+                s.clientsMutex.Unlock()
+                if clients == 0 {
                         continue
                 }
 
-                // Create message
-                msg := WebSocketMessage{
+                s.broadcast(WebSocketMessage{
                         Type:      "market",
                         Data:      marketData,
-                        Timestamp: time.Now().Unix(),
-                }
-
-                // Send to all clients
-                for client := range s.clients {
-                        if err := client.WriteJSON(msg); err != nil {
-                                log.Errorf("Failed to send market data: %v", err)
-                                client.Close()
-                                delete(s.clients, client)
-                        }
-                }
-                s.clientsMutex.Unlock()
+                        Timestamp: s.clock.Now().Unix(),
+                })
         }
 }
 
-// broadcastOpportunity broadcasts an arbitrage opportunity to all connected clients
-func (s *WebServer) broadcastOpportunity(opp models.ArbitrageOpportunity) {
-        s.clientsMutex.Lock()
-        defer s.clientsMutex.Unlock()
+// opportunityMessageTypes maps opportunity events to WebSocket message types
+var opportunityMessageTypes = map[string]string{
+        models.OpportunityEventOpened:  "opportunity",
+        models.OpportunityEventUpdated: "opportunity_updated",
+        models.OpportunityEventClosed:  "opportunity_closed",
+}
 
-        // Skip if no clients
-        if len(s.clients) == 0 {
-                return
-        }
+// broadcastOpportunity broadcasts an opportunity lifecycle event to all connected clients
+func (s *WebServer) broadcastOpportunity(event models.OpportunityEvent) {
+        s.broadcast(WebSocketMessage{
+                Type:      opportunityMessageTypes[event.Type],
+                Data:      event.Opportunity,
+                Timestamp: s.clock.Now().Unix(),
+        })
+}
 
-        // Create message
-        msg := WebSocketMessage{
-                Type:      "opportunity",
-                Data:      opp,
-                Timestamp: time.Now().Unix(),
-        }
+// broadcast queues a message for all connected clients without waiting for the writes.
+// A client whose queue is full is too slow to keep up and is dropped.
+func (s *WebServer) broadcast(msg WebSocketMessage) {
+        s.clientsMutex.Lock()
+        defer s.clientsMutex.Unlock()
 
-        // Send to all clients
-        for client := range s.clients {
-                if err := client.WriteJSON(msg); err != nil {
-                        log.Errorf("Failed to send opportunity data: %v", err)
-                        client.Close()
+        for client, queue := range s.clients {
+                select {
+                case queue <- msg:
+                default:
+                        log.Warnf("WebSocket client %s is too slow, disconnecting it", client.RemoteAddr())
                         delete(s.clients, client)
+                        close(queue)
                 }
         }
 }
@@ -281,15 +403,131 @@ func (s *WebServer) handleOpportunitiesAPI(w http.ResponseWriter, r *http.Reques
 func (s *WebServer) handleMarketAPI(w http.ResponseWriter, r *http.Request) {
         w.Header().Set("Content-Type", "application/json")
 
-        s.orderBookMutex.RLock()
-        marketData := make(map[string]*models.OrderBook)
-        for k, v := range s.orderBooks {
-                marketData[k] = v
-        }
-        s.orderBookMutex.RUnlock()
+        marketData := s.orderBooks.SnapshotMap()
 
         if err := json.NewEncoder(w).Encode(marketData); err != nil {
                 log.Errorf("Failed to encode market data: %v", err)
                 http.Error(w, "Error encoding response", http.StatusInternalServerError)
         }
-}
\ No newline at end of file
+}
+
+// handlePaperAPI handles API requests for the paper trading ledger
+func (s *WebServer) handlePaperAPI(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+
+        if s.paperTrader == nil {
+                http.Error(w, "Paper trading is disabled", http.StatusNotFound)
+                return
+        }
+
+        if err := json.NewEncoder(w).Encode(s.paperTrader.Ledger()); err != nil {
+                log.Errorf("Failed to encode paper trading ledger: %v", err)
+                http.Error(w, "Error encoding response", http.StatusInternalServerError)
+        }
+}
+
+// handleExecutionsAPI handles API requests for the live executions, newest first
+func (s *WebServer) handleExecutionsAPI(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+
+        if s.executor == nil {
+                http.Error(w, "Live execution is disabled", http.StatusNotFound)
+                return
+        }
+
+        if err := json.NewEncoder(w).Encode(s.executor.Executions()); err != nil {
+                log.Errorf("Failed to encode executions: %v", err)
+                http.Error(w, "Error encoding response", http.StatusInternalServerError)
+        }
+}
+
+// handleRiskAPI handles API requests for the risk limits, kill switch and exposure
+func (s *WebServer) handleRiskAPI(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+
+        if s.riskManager == nil {
+                http.Error(w, "Risk manager is not configured", http.StatusNotFound)
+                return
+        }
+
+        if err := json.NewEncoder(w).Encode(s.riskManager.Status()); err != nil {
+                log.Errorf("Failed to encode risk status: %v", err)
+                http.Error(w, "Error encoding response", http.StatusInternalServerError)
+        }
+}
+
+// handleRebalanceAPI handles API requests for the transfers that restore the target allocations
+func (s *WebServer) handleRebalanceAPI(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+
+        if s.rebalancer == nil {
+                http.Error(w, "No balances to rebalance", http.StatusNotFound)
+                return
+        }
+
+        if err := json.NewEncoder(w).Encode(s.rebalancer.Plan()); err != nil {
+                log.Errorf("Failed to encode rebalance plan: %v", err)
+                http.Error(w, "Error encoding response", http.StatusInternalServerError)
+        }
+}
+
+// handleKillSwitchAPI engages or releases the kill switch with a POST of {"engaged": true|false}
+func (s *WebServer) handleKillSwitchAPI(w http.ResponseWriter, r *http.Request) {
+        if s.riskManager == nil {
+                http.Error(w, "Risk manager is not configured", http.StatusNotFound)
+                return
+        }
+        if r.Method != http.MethodPost {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        var request struct {
+                Engaged *bool  `json:"engaged"`
+                Reason  string `json:"reason"`
+        }
+        if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Engaged == nil {
+                http.Error(w, `Expected {"engaged": true|false}`, http.StatusBadRequest)
+                return
+        }
+
+        // Anyone may stop trading, but resuming it must come from the operator
+        if !*request.Engaged {
+                if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+                        http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+                        return
+                }
+                if !s.mayReleaseKillSwitch(r) {
+                        log.Warnf("Refused to release the kill switch for %s", r.RemoteAddr)
+                        http.Error(w, "Not authorized to release the kill switch", http.StatusForbidden)
+                        return
+                }
+        }
+        reason := request.Reason
+        if reason == "" {
+                reason = "API request from " + r.RemoteAddr
+        }
+        s.riskManager.SetKillSwitch(*request.Engaged, reason)
+
+        s.handleRiskAPI(w, r)
+}
+
+// mayReleaseKillSwitch checks that a request carries the kill switch token, or comes from the
+// local machine when no token is configured
+func (s *WebServer) mayReleaseKillSwitch(r *http.Request) bool {
+        if s.killSwitchToken != "" {
+                const prefix = "Bearer "
+                header := r.Header.Get("Authorization")
+                if len(header) <= len(prefix) || header[:len(prefix)] != prefix {
+                        return false
+                }
+                return subtle.ConstantTimeCompare([]byte(header[len(prefix):]), []byte(s.killSwitchToken)) == 1
+        }
+
+        host, _, err := net.SplitHostPort(r.RemoteAddr)
+        if err != nil {
+                return false
+        }
+        ip := net.ParseIP(host)
+        return ip != nil && ip.IsLoopback()
+}
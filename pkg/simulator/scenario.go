@@ -0,0 +1,189 @@
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes how the simulated market behaves
+// @author VrushankPatel
+// @description Parameters of the price process, venue microstructure and market incidents, loaded from a scenario file
+type Scenario struct {
+	// Name of the scenario, used in logs
+	Name string `yaml:"name"`
+	// Seed for the random source; 0 seeds from the current time
+	Seed int64 `yaml:"seed"`
+	// Simulation time step between price updates
+	TickInterval time.Duration `yaml:"tickInterval"`
+	// Weight of the common market factor in asset returns (0 = independent, 1 = fully correlated)
+	Correlation float64 `yaml:"correlation"`
+	// Starting price (in USD) and volatility per asset
+	Assets map[string]AssetParams `yaml:"assets"`
+	// Settings used for assets not listed in Assets
+	DefaultAsset AssetParams `yaml:"defaultAsset"`
+	// Microstructure per exchange name
+	Venues map[string]VenueParams `yaml:"venues"`
+	// Settings used for exchanges not listed in Venues
+	DefaultVenue VenueParams `yaml:"defaultVenue"`
+	// Temporary price dislocations on a single venue and pair
+	Dislocations DislocationParams `yaml:"dislocations"`
+	// Periods during which a venue stops publishing data
+	Outages OutageParams `yaml:"outages"`
+}
+
+// AssetParams configures the geometric Brownian motion of one asset
+type AssetParams struct {
+	// Starting price in USD
+	Price float64 `yaml:"price"`
+	// Annualized volatility as a decimal (e.g., 0.6 = 60%)
+	Volatility float64 `yaml:"volatility"`
+	// Annualized drift as a decimal
+	Drift float64 `yaml:"drift"`
+}
+
+// VenueParams configures how one exchange quotes the market
+type VenueParams struct {
+	// Half of the bid/ask spread in basis points
+	HalfSpreadBps float64 `yaml:"halfSpreadBps"`
+	// Standard deviation of venue-specific price noise in basis points
+	NoiseBps float64 `yaml:"noiseBps"`
+	// How far behind the reference price the venue quotes
+	Lag time.Duration `yaml:"lag"`
+	// Number of depth levels per side
+	DepthLevels int `yaml:"depthLevels"`
+	// Price distance between depth levels in basis points
+	LevelStepBps float64 `yaml:"levelStepBps"`
+	// Average quantity per level, expressed in quote currency notional
+	LevelNotional float64 `yaml:"levelNotional"`
+}
+
+// DislocationParams configures temporary mispricings on a venue
+type DislocationParams struct {
+	// Probability per tick and venue/pair that a dislocation starts
+	Probability float64 `yaml:"probability"`
+	// Size range of the dislocation in basis points
+	MinBps float64 `yaml:"minBps"`
+	MaxBps float64 `yaml:"maxBps"`
+	// How long a dislocation lasts
+	Duration time.Duration `yaml:"duration"`
+}
+
+// OutageParams configures venue outages
+type OutageParams struct {
+	// Probability per tick and venue that an outage starts
+	Probability float64 `yaml:"probability"`
+	// How long an outage lasts
+	Duration time.Duration `yaml:"duration"`
+}
+
+// DefaultScenario returns the built-in scenario used when no scenario file is configured
+// @author VrushankPatel
+// @description Moderately volatile, correlated crypto market with occasional dislocations and rare outages
+// @return The default scenario
+func DefaultScenario() Scenario {
+	return Scenario{
+		Name:         "default",
+		TickInterval: 250 * time.Millisecond,
+		Correlation:  0.7,
+		Assets: map[string]AssetParams{
+			"BTC":  {Price: 70000, Volatility: 0.6},
+			"ETH":  {Price: 3500, Volatility: 0.75},
+			"SOL":  {Price: 150, Volatility: 1.0},
+			"USDT": {Price: 1},
+			"USDC": {Price: 1},
+			"USD":  {Price: 1},
+		},
+		DefaultAsset: AssetParams{Price: 100, Volatility: 0.9},
+		Venues: map[string]VenueParams{
+			"Binance":  {HalfSpreadBps: 0.5, NoiseBps: 0.5, DepthLevels: 10, LevelStepBps: 0.5, LevelNotional: 150000},
+			"Kraken":   {HalfSpreadBps: 1.5, NoiseBps: 1.5, Lag: 300 * time.Millisecond, DepthLevels: 10, LevelStepBps: 1, LevelNotional: 60000},
+			"Coinbase": {HalfSpreadBps: 1, NoiseBps: 1, Lag: 150 * time.Millisecond, DepthLevels: 10, LevelStepBps: 1, LevelNotional: 90000},
+		},
+		DefaultVenue: VenueParams{HalfSpreadBps: 2, NoiseBps: 2, Lag: 500 * time.Millisecond, DepthLevels: 10, LevelStepBps: 1, LevelNotional: 50000},
+		Dislocations: DislocationParams{Probability: 0.002, MinBps: 30, MaxBps: 120, Duration: 3 * time.Second},
+		Outages:      OutageParams{Probability: 0.0002, Duration: 15 * time.Second},
+	}
+}
+
+// LoadScenario reads a scenario file, filling unset fields from the default scenario
+// @author VrushankPatel
+// @description Parses a YAML scenario file and validates it
+// @param path Path to the scenario file
+// @return The loaded scenario and an error if the file can't be read or is invalid
+func LoadScenario(path string) (Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("failed to read scenario file: %v", err)
+	}
+
+	scenario := DefaultScenario()
+	// Replace the default maps so the file fully defines assets and venues it lists
+	scenario.Assets = nil
+	scenario.Venues = nil
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return Scenario{}, fmt.Errorf("failed to parse scenario file: %v", err)
+	}
+	if scenario.Assets == nil {
+		scenario.Assets = DefaultScenario().Assets
+	}
+	if scenario.Venues == nil {
+		scenario.Venues = DefaultScenario().Venues
+	}
+
+	if err := scenario.validate(); err != nil {
+		return Scenario{}, fmt.Errorf("invalid scenario %s: %v", path, err)
+	}
+	return scenario, nil
+}
+
+// validate checks that the scenario parameters are usable
+func (s Scenario) validate() error {
+	if s.TickInterval <= 0 {
+		return fmt.Errorf("tickInterval must be positive")
+	}
+	if s.Correlation < 0 || s.Correlation > 1 {
+		return fmt.Errorf("correlation must be between 0 and 1")
+	}
+	for asset, params := range s.Assets {
+		if params.Price <= 0 {
+			return fmt.Errorf("asset %s must have a positive price", asset)
+		}
+		if params.Volatility < 0 {
+			return fmt.Errorf("asset %s must have a non-negative volatility", asset)
+		}
+	}
+	if s.DefaultAsset.Price <= 0 {
+		return fmt.Errorf("defaultAsset must have a positive price")
+	}
+	if s.Dislocations.MinBps > s.Dislocations.MaxBps {
+		return fmt.Errorf("dislocations minBps must not exceed maxBps")
+	}
+	if s.Dislocations.Probability < 0 || s.Dislocations.Probability > 1 ||
+		s.Outages.Probability < 0 || s.Outages.Probability > 1 {
+		return fmt.Errorf("probabilities must be between 0 and 1")
+	}
+	return nil
+}
+
+// venue returns the parameters of an exchange, falling back to the default venue
+func (s Scenario) venue(name string) VenueParams {
+	params, exists := s.Venues[name]
+	if !exists {
+		params = s.DefaultVenue
+	}
+	if params.DepthLevels <= 0 {
+		params.DepthLevels = 1
+	}
+	return params
+}
+
+// asset returns the parameters of an asset, falling back to the default asset
+func (s Scenario) asset(name string) AssetParams {
+	if params, exists := s.Assets[name]; exists {
+		return params
+	}
+	return s.DefaultAsset
+}
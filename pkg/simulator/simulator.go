@@ -0,0 +1,268 @@
+package simulator
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"apex-arbitrage/pkg/models"
+)
+
+// secondsPerYear converts annualized volatility and drift to per-step values
+const secondsPerYear = 365 * 24 * 60 * 60
+
+// Simulator generates correlated order books for several venues from one reference market
+// @author VrushankPatel
+// @description Drives asset prices with correlated geometric Brownian motion and derives per-venue
+// quotes with spread, noise, latency lag, dislocations and outages. The simulation advances lazily
+// in fixed steps up to the time passed to Quote, so it is safe to share between exchange clients.
+type Simulator struct {
+	mu        sync.Mutex
+	scenario  Scenario
+	rng       *rand.Rand
+	now       time.Time
+	prices    map[string]float64
+	history   []priceSnapshot
+	maxLag    time.Duration
+	incidents map[string]*venueIncidents
+	// Assets, venues and pairs in the order they were first quoted, so a seeded
+	// scenario draws random numbers in a reproducible order
+	assets []string
+	venues []string
+	pairs  []models.TradingPair
+}
+
+// priceSnapshot records the reference prices at one simulation step
+type priceSnapshot struct {
+	at     time.Time
+	prices map[string]float64
+}
+
+// venueIncidents tracks the outage and dislocations currently affecting one venue
+type venueIncidents struct {
+	outageUntil  time.Time
+	dislocations map[models.TradingPair]dislocation
+}
+
+// dislocation is a temporary offset of one venue's prices for a pair
+type dislocation struct {
+	offset float64
+	until  time.Time
+}
+
+// New creates a simulator for the given scenario starting at the given time
+// @author VrushankPatel
+// @description Creates and initializes a new Simulator
+// @param scenario The scenario describing the market
+// @param start The simulation start time
+// @return A pointer to the newly created Simulator
+func New(scenario Scenario, start time.Time) *Simulator {
+	seed := scenario.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	s := &Simulator{
+		scenario:  scenario,
+		rng:       rand.New(rand.NewSource(seed)),
+		now:       start,
+		prices:    make(map[string]float64),
+		incidents: make(map[string]*venueIncidents),
+	}
+	for _, params := range scenario.Venues {
+		if params.Lag > s.maxLag {
+			s.maxLag = params.Lag
+		}
+	}
+	if scenario.DefaultVenue.Lag > s.maxLag {
+		s.maxLag = scenario.DefaultVenue.Lag
+	}
+	return s
+}
+
+// Scenario returns the scenario the simulator runs
+func (s *Simulator) Scenario() Scenario {
+	return s.scenario
+}
+
+// Quote returns the depth a venue publishes for a pair at the given time
+// @author VrushankPatel
+// @description Advances the simulation to now and builds the venue's lagged, noisy, possibly dislocated book
+// @param venue The exchange name
+// @param pair The trading pair
+// @param now The time to quote at
+// @return Bid and ask levels best first, and false while the venue is in an outage
+func (s *Simulator) Quote(venue string, pair models.TradingPair, now time.Time) ([]models.PriceLevel, []models.PriceLevel, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.advanceTo(now)
+
+	params := s.scenario.venue(venue)
+	incidents := s.venueIncidents(venue)
+	s.trackPair(pair)
+	if now.Before(incidents.outageUntil) {
+		return nil, nil, false
+	}
+
+	// The venue sees the reference market as it was Lag ago
+	prices := s.pricesAt(now.Add(-params.Lag))
+	base := s.ensureAsset(pair.BaseCurrency, prices)
+	quote := s.ensureAsset(pair.QuoteCurrency, prices)
+	mid := base / quote
+
+	offset := s.rng.NormFloat64() * params.NoiseBps / 10000
+	if d, active := incidents.dislocations[pair]; active && now.Before(d.until) {
+		offset += d.offset
+	}
+	mid *= 1 + offset
+
+	bids := make([]models.PriceLevel, 0, params.DepthLevels)
+	asks := make([]models.PriceLevel, 0, params.DepthLevels)
+	for level := 0; level < params.DepthLevels; level++ {
+		distance := (params.HalfSpreadBps + float64(level)*params.LevelStepBps) / 10000
+		bidPrice := mid * (1 - distance)
+		askPrice := mid * (1 + distance)
+
+		// Deeper levels hold more size, with some randomness per level
+		notional := params.LevelNotional * (0.5 + s.rng.Float64()) * (1 + float64(level)*0.25)
+		bids = append(bids, models.PriceLevel{Price: bidPrice, Quantity: notional / bidPrice})
+		notional = params.LevelNotional * (0.5 + s.rng.Float64()) * (1 + float64(level)*0.25)
+		asks = append(asks, models.PriceLevel{Price: askPrice, Quantity: notional / askPrice})
+	}
+
+	return bids, asks, true
+}
+
+// advanceTo steps the simulation forward in TickInterval increments until it reaches t
+func (s *Simulator) advanceTo(t time.Time) {
+	step := s.scenario.TickInterval
+	for !s.now.Add(step).After(t) {
+		s.now = s.now.Add(step)
+		s.step(step)
+	}
+}
+
+// step moves every asset along its geometric Brownian motion and rolls incidents
+func (s *Simulator) step(step time.Duration) {
+	dt := step.Seconds() / secondsPerYear
+	market := s.rng.NormFloat64()
+	rho := s.scenario.Correlation
+
+	for _, asset := range s.assets {
+		price := s.prices[asset]
+		params := s.scenario.asset(asset)
+		if params.Volatility == 0 && params.Drift == 0 {
+			continue
+		}
+		// Correlated shock: common market factor plus an idiosyncratic component
+		shock := math.Sqrt(rho)*market + math.Sqrt(1-rho)*s.rng.NormFloat64()
+		sigma := params.Volatility
+		s.prices[asset] = price * math.Exp((params.Drift-sigma*sigma/2)*dt+sigma*math.Sqrt(dt)*shock)
+	}
+
+	s.record()
+	s.rollIncidents()
+}
+
+// record appends the current prices to the history, keeping enough to serve the largest lag
+func (s *Simulator) record() {
+	prices := make(map[string]float64, len(s.prices))
+	for asset, price := range s.prices {
+		prices[asset] = price
+	}
+	s.history = append(s.history, priceSnapshot{at: s.now, prices: prices})
+
+	cutoff := s.now.Add(-s.maxLag - s.scenario.TickInterval)
+	trim := 0
+	for trim < len(s.history)-1 && s.history[trim].at.Before(cutoff) {
+		trim++
+	}
+	s.history = s.history[trim:]
+}
+
+// pricesAt returns the most recent recorded prices at or before t
+func (s *Simulator) pricesAt(t time.Time) map[string]float64 {
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if !s.history[i].at.After(t) {
+			return s.history[i].prices
+		}
+	}
+	if len(s.history) > 0 {
+		return s.history[0].prices
+	}
+	return s.prices
+}
+
+// ensureAsset returns an asset's price from prices, starting the asset's price process if
+// this is the first time it is quoted
+func (s *Simulator) ensureAsset(asset string, prices map[string]float64) float64 {
+	if price, exists := prices[asset]; exists {
+		return price
+	}
+	if price, exists := s.prices[asset]; exists {
+		return price
+	}
+	price := s.scenario.asset(asset).Price
+	s.prices[asset] = price
+	s.assets = append(s.assets, asset)
+	return price
+}
+
+// venueIncidents returns the incident state of a venue, creating it on first use
+func (s *Simulator) venueIncidents(venue string) *venueIncidents {
+	incidents, exists := s.incidents[venue]
+	if !exists {
+		incidents = &venueIncidents{dislocations: make(map[models.TradingPair]dislocation)}
+		s.incidents[venue] = incidents
+		s.venues = append(s.venues, venue)
+	}
+	return incidents
+}
+
+// rollIncidents randomly starts outages and dislocations and expires finished ones
+func (s *Simulator) rollIncidents() {
+	d := s.scenario.Dislocations
+	o := s.scenario.Outages
+
+	for _, venue := range s.venues {
+		incidents := s.incidents[venue]
+		if o.Probability > 0 && !s.now.Before(incidents.outageUntil) && s.rng.Float64() < o.Probability {
+			incidents.outageUntil = s.now.Add(o.Duration)
+		}
+
+		for pair, current := range incidents.dislocations {
+			if !s.now.Before(current.until) {
+				delete(incidents.dislocations, pair)
+			}
+		}
+	}
+
+	if d.Probability <= 0 {
+		return
+	}
+	for _, venue := range s.venues {
+		incidents := s.incidents[venue]
+		for _, pair := range s.pairs {
+			if _, active := incidents.dislocations[pair]; active || s.rng.Float64() >= d.Probability {
+				continue
+			}
+			size := (d.MinBps + s.rng.Float64()*(d.MaxBps-d.MinBps)) / 10000
+			if s.rng.Intn(2) == 0 {
+				size = -size
+			}
+			incidents.dislocations[pair] = dislocation{offset: size, until: s.now.Add(d.Duration)}
+		}
+	}
+}
+
+// trackPair remembers a pair so dislocations can be rolled for it
+func (s *Simulator) trackPair(pair models.TradingPair) {
+	for _, known := range s.pairs {
+		if known == pair {
+			return
+		}
+	}
+	s.pairs = append(s.pairs, pair)
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -8,35 +9,47 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// InitLogger sets up the logger with the desired configuration
-func InitLogger() {
-	// Ensure the data directory exists
-	if err := os.MkdirAll("data", 0755); err != nil {
-		log.Fatal("Failed to create data directory: ", err)
-	}
-
-	// Create log file
-	logFile, err := os.OpenFile(
-		filepath.Join("data", "arbitrage.log"),
-		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
-		0644,
-	)
+// InitLogger sets up the logger with the desired level and format ("text" or "json").
+// Logs go to stdout and, when file is set, are appended to that file as well.
+func InitLogger(level, file, format string) error {
+	parsedLevel, err := log.ParseLevel(level)
 	if err != nil {
-		log.Fatal("Failed to open log file: ", err)
+		return err
 	}
 
-	// Configure logrus to write to both file and stdout
-	mw := io.MultiWriter(os.Stdout, logFile)
-	log.SetOutput(mw)
-
-	// Set log level to info by default
-	log.SetLevel(log.InfoLevel)
+	var output io.Writer = os.Stdout
+	if file != "" {
+		// Ensure the log directory exists
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return fmt.Errorf("failed to create log directory: %v", err)
+		}
+
+		// Create log file
+		logFile, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %v", err)
+		}
+
+		// Configure logrus to write to both file and stdout
+		output = io.MultiWriter(os.Stdout, logFile)
+	}
 
 	// Set up formatter
-	log.SetFormatter(&log.TextFormatter{
-		FullTimestamp:   true,
-		TimestampFormat: "2006-01-02 15:04:05",
-	})
+	switch format {
+	case "", "text":
+		log.SetFormatter(&log.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: "2006-01-02 15:04:05",
+		})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+
+	log.SetOutput(output)
+	log.SetLevel(parsedLevel)
 
 	log.Info("Logger initialized")
+	return nil
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"apex-arbitrage/pkg/clock"
+	"apex-arbitrage/pkg/config"
+	"apex-arbitrage/pkg/detector"
+	"apex-arbitrage/pkg/exchanges"
+	"apex-arbitrage/pkg/execution"
+	"apex-arbitrage/pkg/models"
+	"apex-arbitrage/pkg/paper"
+	"apex-arbitrage/pkg/server"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reloader applies configuration changes to the running components. The profit threshold,
+// the taker fees and the trading pairs change live; every other setting needs a restart.
+type reloader struct {
+	mu       sync.Mutex
+	cfg      *config.Config // Configuration in effect
+	clients  []exchanges.Exchange
+	arb      *detector.APEX
+	trader   *paper.Engine       // nil without paper trading
+	executor *execution.Executor // nil without live execution
+	web      *server.WebServer
+	clock    clock.Clock
+}
+
+// reload loads the configuration again and applies what changed. A configuration that fails
+// to load or validate is rejected as a whole, keeping the one in effect.
+func (r *reloader) reload(trigger string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log.Infof("[Config] Reloading configuration (%s)", trigger)
+	next, err := config.LoadConfig()
+	if err != nil {
+		log.Errorf("[Config] Reload failed, keeping the current configuration: %v", err)
+		return
+	}
+	if err := next.Validate(); err != nil {
+		log.Errorf("[Config] Reload rejected, keeping the current configuration: %v", err)
+		return
+	}
+
+	applied, changes, restart := r.cfg.Reload(next)
+	if len(restart) > 0 {
+		log.Warnf("[Config] Changes to %s take effect after a restart", strings.Join(restart, ", "))
+	}
+	if len(changes) == 0 {
+		log.Info("[Config] No settings to apply")
+		return
+	}
+	for _, change := range changes {
+		log.WithFields(log.Fields{"old": change.Old, "new": change.New}).Infof("[Config] %s changed", change.Setting)
+	}
+
+	// Exchanges only resubscribe when their pairs changed
+	pairs := modelPairs(applied)
+	fees := takerFees(applied)
+	for _, client := range r.clients {
+		client.SetTradingPairs(pairs)
+		client.SetTakerFee(fees[client.Name()])
+	}
+	r.arb.UpdateSettings(detector.Settings{
+		MinProfitThreshold: applied.MinProfitThreshold,
+		ExchangeFees:       fees,
+	})
+	if r.trader != nil {
+		r.trader.SetFees(fees)
+	}
+	if r.executor != nil {
+		r.executor.SetFees(fees)
+	}
+
+	r.cfg = applied
+	r.publish(changes, restart)
+	log.Infof("[Config] Applied %d changes", len(changes))
+}
+
+// publish sends the settings in effect to the web clients
+func (r *reloader) publish(changes []config.Change, restart []string) {
+	pairs := make([]string, 0, len(r.cfg.TradingPairs))
+	for _, pair := range r.cfg.TradingPairs {
+		pairs = append(pairs, pair.BaseCurrency+"/"+pair.QuoteCurrency)
+	}
+	r.web.PublishConfig(server.ConfigUpdate{
+		MinProfitThreshold: r.cfg.MinProfitThreshold,
+		ExchangeFees:       takerFees(r.cfg),
+		TradingPairs:       pairs,
+		Changes:            changes,
+		RestartRequired:    restart,
+	})
+}
+
+// watch reloads the configuration whenever the configuration file or the .env file is
+// written, created or removed, comparing their modification times every interval
+func (r *reloader) watch(ctx context.Context, interval time.Duration) {
+	files := []string{r.cfg.ConfigFile, ".env"}
+	ticker := r.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := modTimes(files)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if current := modTimes(files); current != last {
+				last = current
+				r.reload("file changed")
+			}
+		}
+	}
+}
+
+// modTimes identifies the state of files on disk by their modification times
+func modTimes(files []string) string {
+	times := make([]string, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			times = append(times, file+":missing")
+			continue
+		}
+		times = append(times, fmt.Sprintf("%s:%d:%d", file, info.ModTime().UnixNano(), info.Size()))
+	}
+	return strings.Join(times, ",")
+}
+
+// modelPairs converts the configured trading pairs to the form exchanges stream
+func modelPairs(cfg *config.Config) []models.TradingPair {
+	pairs := make([]models.TradingPair, 0, len(cfg.TradingPairs))
+	for _, pair := range cfg.TradingPairs {
+		pairs = append(pairs, models.TradingPair{
+			BaseCurrency:  pair.BaseCurrency,
+			QuoteCurrency: pair.QuoteCurrency,
+		})
+	}
+	return pairs
+}
+
+// takerFees returns the taker fee of every enabled exchange
+func takerFees(cfg *config.Config) map[string]float64 {
+	fees := make(map[string]float64)
+	for _, exchange := range enabledExchanges(cfg) {
+		fees[exchange.name] = exchange.cfg.TakerFee
+	}
+	return fees
+}
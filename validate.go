@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"apex-arbitrage/pkg/config"
+)
+
+// runValidateConfig implements the validate-config command, which loads the configuration,
+// prints every problem found and a summary of the settings. It returns the process exit code.
+func runValidateConfig(args []string) int {
+	flags := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	path := flags.String("config", "", "configuration file to check (default: CONFIG_FILE or config.yaml)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.LoadConfigFile(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	pairs := []string{}
+	for _, pair := range cfg.TradingPairs {
+		pairs = append(pairs, pair.BaseCurrency+"/"+pair.QuoteCurrency)
+	}
+	exchanges := []string{}
+	for _, exchange := range enabledExchanges(cfg) {
+		exchanges = append(exchanges, fmt.Sprintf("%s (taker fee %.4f%%)", exchange.name, exchange.cfg.TakerFee*100))
+	}
+
+	fmt.Printf("Configuration is valid (%s)\n", cfg.ConfigFile)
+	fmt.Printf("  Simulation mode:      %t\n", cfg.SimulationMode)
+	fmt.Printf("  Min profit threshold: %.4f%%\n", cfg.MinProfitThreshold*100)
+	fmt.Printf("  Trading pairs:        %s\n", strings.Join(pairs, ", "))
+	fmt.Printf("  Exchanges:            %s\n", strings.Join(exchanges, ", "))
+	return 0
+}
@@ -241,6 +241,25 @@ function processData(data) {
             updateStats();
             updateOpportunitiesTable();
             break;
+        case 'opportunity_updated':
+        case 'opportunity_closed': {
+            // Replace the entry of the same opportunity with its latest state
+            const index = opportunities.findIndex(opp =>
+                opp.id === data.data.id && opp.opened_at === data.data.opened_at);
+            if (index !== -1) {
+                opportunities[index] = data.data;
+                updateStats();
+                updateOpportunitiesTable();
+            }
+            break;
+        }
+        case 'config':
+            // Announce settings applied by a configuration reload
+            if (data.data.changes && data.data.changes.length > 0) {
+                showMessage('Configuration reloaded: ' +
+                    data.data.changes.map(change => `${change.setting} ${change.old} → ${change.new}`).join(', '));
+            }
+            break;
         default:
             console.log('Unknown message type:', data.type);
     }
@@ -257,6 +276,11 @@ function showNotification(opportunity) {
         Profit: ${opportunity.ProfitPercentage.toFixed(4)}% (${formatCurrency(opportunity.NetProfit)})
     `;
     
+    showMessage(message);
+}
+
+// Show a message in the notification popup
+function showMessage(message) {
     notificationMessage.textContent = message;
     notification.classList.remove('hidden');
     